@@ -0,0 +1,108 @@
+package iradix
+
+// aggregator computes per-node aggregates of a tree created with
+// WithAggregator. Aggregates are boxed so that neither Node nor the options
+// depend on the aggregate type, and stored in the node's extension so that
+// trees without an aggregator don't pay for them.
+type aggregator[T any] interface {
+	// aggregate returns the aggregate of a node from its own value, if any,
+	// and the aggregates of its children.
+	aggregate(leaf *T, children func(yield func(agg any))) any
+}
+
+type aggregatorFuncs[A, T any] struct {
+	zero    A
+	combine func(A, T) A
+	merge   func(A, A) A
+}
+
+func (a *aggregatorFuncs[A, T]) aggregate(leaf *T, children func(yield func(agg any))) any {
+	acc := a.zero
+	if leaf != nil {
+		acc = a.combine(acc, *leaf)
+	}
+	children(func(agg any) {
+		acc = a.merge(acc, agg.(A))
+	})
+	return acc
+}
+
+// WithAggregator makes transactions maintain an aggregate of all values stored
+// under every node, such as their sum or maximum, available through
+// Node.Aggregate and Tree.AggregatePrefix. The aggregate of a node is its own
+// value, if any, combined into zero, merged with the aggregates of its
+// children in key order. Only nodes that are copied anyway are updated, but
+// every update calls combine and merge for the node and its children. The
+// value type T must match the tree's, otherwise New panics.
+func WithAggregator[A, T any](zero A, combine func(A, T) A, merge func(A, A) A) Option {
+	return func(o *options) {
+		o.aggregator = &aggregatorFuncs[A, T]{zero: zero, combine: combine, merge: merge}
+	}
+}
+
+// aggregate recomputes the aggregate of n, which must be writable, from its
+// leaf and children. It's a no-op if the tree has no aggregator.
+func (t *Txn[K, T]) aggregate(n *Node[K, T]) {
+	if t.aggregator == nil {
+		return
+	}
+	n.ext = &nodeExt[K]{
+		cmp: n.comparator(),
+		agg: computeAggregate(t.aggregator.(aggregator[T]), n),
+	}
+}
+
+func computeAggregate[K keyT, T any](a aggregator[T], n *Node[K, T]) any {
+	var leaf *T
+	if n.leaf != nil {
+		leaf = &n.leaf.val
+	}
+	return a.aggregate(leaf, func(yield func(agg any)) {
+		for _, e := range n.edges {
+			yield(e.node.Aggregate())
+		}
+	})
+}
+
+// Aggregate returns the aggregate of all values under n maintained by a tree
+// created with WithAggregator, which has the type of the aggregator's zero
+// value. It returns nil for trees without an aggregator. This is the untyped
+// accessor, AggregateOf returns the aggregate as A.
+func (n *Node[K, T]) Aggregate() any {
+	if n.ext == nil {
+		return nil
+	}
+	return n.ext.agg
+}
+
+// AggregatePrefix returns the aggregate of all values whose keys start with
+// prefix in a tree created with WithAggregator, in O(len(prefix)). It returns
+// the aggregator's zero value if there are no such keys, and nil for trees
+// without an aggregator.
+func (t *Tree[K, T]) AggregatePrefix(prefix []K) any {
+	if t.aggregator == nil {
+		return nil
+	}
+	it := t.root.Iterator()
+	it.SeekPrefix(prefix)
+	if it.node == nil {
+		return computeAggregate(t.aggregator.(aggregator[T]), &Node[K, T]{})
+	}
+	return it.node.Aggregate()
+}
+
+// AggregateOf returns the aggregate of all values under n like Node.Aggregate,
+// as the aggregator's type A. It returns false for trees without an aggregator
+// or with an aggregate of another type.
+func AggregateOf[A any, K keyT, T any](n *Node[K, T]) (A, bool) {
+	agg, ok := n.Aggregate().(A)
+	return agg, ok
+}
+
+// AggregatePrefixOf returns the aggregate of all values whose keys start with
+// prefix like Tree.AggregatePrefix, as the aggregator's type A. It returns
+// false for trees without an aggregator or with an aggregate of another type.
+func AggregatePrefixOf[A any, K keyT, T any](t *Tree[K, T], prefix []K) (A, bool) {
+	agg, ok := t.AggregatePrefix(prefix).(A)
+	return agg, ok
+}
@@ -0,0 +1,120 @@
+package iradix
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkAggregates(t *testing.T, r *Tree[byte, int], keys map[string]int) {
+	t.Helper()
+	// Every prefix of every key, plus a few missing ones.
+	prefixes := map[string]struct{}{"": {}, "zzz": {}, "0g": {}}
+	for k := range keys {
+		for i := 0; i <= len(k); i++ {
+			prefixes[k[:i]] = struct{}{}
+		}
+	}
+	for p := range prefixes {
+		sum := 0
+		for k, v := range keys {
+			if strings.HasPrefix(k, p) {
+				sum += v
+			}
+		}
+		if agg := r.AggregatePrefix([]byte(p)); agg != sum {
+			t.Fatalf("prefix %q: got aggregate %v, want %d", p, agg, sum)
+		}
+	}
+	if agg := r.Root().Aggregate(); agg != r.AggregatePrefix(nil) {
+		t.Fatalf("bad root aggregate: %v", agg)
+	}
+	if agg, ok := AggregateOf[int](r.Root()); !ok || agg != r.AggregatePrefix(nil) {
+		t.Fatalf("bad typed root aggregate: %v %v", agg, ok)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	sum := WithAggregator(0, func(acc, v int) int { return acc + v }, func(a, b int) int { return a + b })
+	seedRand()
+	keys := make(map[string]int)
+	r := New[byte, int](sum)
+	checkAggregates(t, r, keys)
+
+	for round := 0; round < 50; round++ {
+		txn := r.Txn()
+		for i := 0; i < 20; i++ {
+			k := randomHexString(2)[:rng.Intn(5)]
+			switch rng.Intn(4) {
+			case 0:
+				txn.Delete([]byte(k))
+				delete(keys, k)
+			case 1:
+				p := k[:len(k)/2]
+				txn.DeletePrefix([]byte(p))
+				for key := range keys {
+					if strings.HasPrefix(key, p) {
+						delete(keys, key)
+					}
+				}
+			default:
+				v := rng.Intn(100)
+				txn.Insert([]byte(k), v)
+				keys[k] = v
+			}
+		}
+		r = txn.Commit()
+		checkAggregates(t, r, keys)
+	}
+
+	// Single operations on the tree.
+	r, _, _ = r.Insert([]byte("foobar"), 7)
+	keys["foobar"] = 7
+	checkAggregates(t, r, keys)
+	r, _, _ = r.Delete([]byte("foobar"))
+	delete(keys, "foobar")
+	checkAggregates(t, r, keys)
+}
+
+func TestAggregator_Max(t *testing.T) {
+	maxAgg := WithAggregator(-1, func(acc, v int) int { return maxInt(acc, v) }, maxInt)
+	r := New[byte, int](maxAgg)
+	for k, v := range map[string]int{"a": 1, "ab": 5, "abc": 2, "b": 3} {
+		r, _, _ = r.Insert([]byte(k), v)
+	}
+	for p, want := range map[string]int{"": 5, "a": 5, "abc": 2, "b": 3, "c": -1} {
+		if got := r.AggregatePrefix([]byte(p)); got != want {
+			t.Fatalf("prefix %q: got %v, want %d", p, got, want)
+		}
+	}
+
+	if got, ok := AggregatePrefixOf[int](r, []byte("a")); !ok || got != 5 {
+		t.Fatalf("bad typed aggregate: %v %v", got, ok)
+	}
+	if _, ok := AggregatePrefixOf[float64](r, []byte("a")); ok {
+		t.Fatalf("expected type mismatch")
+	}
+
+	// Trees without an aggregator.
+	if agg := New[byte, int]().AggregatePrefix(nil); agg != nil {
+		t.Fatalf("expected nil aggregate, got %v", agg)
+	}
+	if _, ok := AggregateOf[int](New[byte, int]().Root()); ok {
+		t.Fatalf("expected no aggregate")
+	}
+}
+
+func TestAggregator_TypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	New[byte, string](WithAggregator(0, func(acc, v int) int { return acc + v }, func(a, b int) int { return a + b }))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,240 @@
+package iradix
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrKeyOrder is returned by Builder.Append for keys that don't sort after
+// the previous one.
+var ErrKeyOrder = errors.New("iradix: key out of order")
+
+// InsertBatch inserts all entries into the transaction, with the same result
+// as inserting them one by one in the given order, so the last of several
+// entries with the same key wins. The entries are sorted by key once, without
+// modifying the given slice. If the transaction's tree is empty, it's built
+// bottom-up in a single pass, creating every node exactly once instead of
+// copying the nodes on the path of every insert. Otherwise the entries are
+// inserted in key order, which keeps the nodes written by consecutive inserts
+// together.
+func (t *Txn[K, T]) InsertBatch(entries []Entry[K, T]) {
+	t.checkWritable()
+	if len(entries) == 0 {
+		return
+	}
+	for _, e := range entries {
+		if err := t.validateKey(e.Key); err != nil {
+			panic(err)
+		}
+	}
+
+	// A stable sort keeps duplicates in their original order, so the last
+	// one can be picked.
+	sorted := slices.Clone(entries)
+	if t.cloneKeys {
+		for i := range sorted {
+			sorted[i].Key = slices.Clone(sorted[i].Key)
+		}
+	}
+	slices.SortStableFunc(sorted, func(a, b Entry[K, T]) int {
+		return t.root.compareKeys(a.Key, b.Key)
+	})
+	unique := sorted[:0]
+	for i, e := range sorted {
+		if i+1 < len(sorted) && keyEqual(e.Key, sorted[i+1].Key) {
+			continue
+		}
+		unique = append(unique, e)
+	}
+
+	if t.root.leaf != nil || len(t.root.edges) > 0 {
+		for _, e := range unique {
+			t.Insert(e.Key, e.Value)
+		}
+		return
+	}
+
+	if t.bloom != nil {
+		for _, e := range unique {
+			t.bloom.add(e.Key)
+		}
+	}
+	root := t.writeNode(t.root, false)
+	t.buildNode(root, unique, 0)
+	t.root = root
+	t.size = len(unique)
+}
+
+// buildNode fills n with the given entries, which are sorted, unique and share
+// the first depth elements of their keys, which is the effective path of n.
+func (t *Txn[K, T]) buildNode(n *Node[K, T], entries []Entry[K, T], depth int) {
+	i := 0
+	if len(entries[0].Key) == depth {
+		n.leaf = &leafNode[K, T]{
+			mutateCh: t.newWatch(),
+			key:      entries[0].Key,
+			val:      entries[0].Value,
+			ext:      t.newLeafExt(),
+		}
+		i++
+	}
+	for i < len(entries) {
+		// Group the entries continuing with the same element. Since they're
+		// sorted, the prefix shared by the first and last of the group is
+		// shared by all of them.
+		label := entries[i].Key[depth]
+		j := i + 1
+		for j < len(entries) && entries[j].Key[depth] == label {
+			j++
+		}
+		first, last := entries[i].Key, entries[j-1].Key
+		end := depth + longestPrefix(first[depth:], last[depth:])
+
+		child := &Node[K, T]{
+			mutateCh: t.newWatch(),
+			ext:      n.childExt(),
+			prefix:   first[depth:end],
+		}
+		t.buildNode(child, entries[i:j], end)
+		n.edges = append(n.edges, edge[K, T]{label: label, node: child})
+		i = j
+	}
+	t.indexEdges(n)
+	n.size = len(entries)
+	t.aggregate(n)
+}
+
+// Builder builds a tree from entries appended in strictly increasing key
+// order, e.g. when loading a sorted export. Since every key sorts after the
+// previous one, it only ever extends the path of the previous key: the nodes
+// on that path are kept open, and those below the element where the new key
+// diverges are complete and final. So every node is created exactly once,
+// without descending the tree or copying nodes for every key, and without
+// buffering the entries either, unlike InsertBatch.
+type Builder[K keyT, T any] struct {
+	txn *Txn[K, T]
+
+	// path holds the open nodes on the path of the last key, starting with
+	// the root.
+	path []builderNode[K, T]
+	last []K
+}
+
+// builderNode is an open node of a Builder, whose effective path ends at
+// depth.
+type builderNode[K keyT, T any] struct {
+	n     *Node[K, T]
+	depth int
+}
+
+// NewBuilder returns a Builder of a tree with the given options.
+func NewBuilder[K keyT, T any](opts ...Option) *Builder[K, T] {
+	b := &Builder[K, T]{}
+	b.reset(New[K, T](opts...))
+	return b
+}
+
+// reset starts building a new tree from the empty tree t.
+func (b *Builder[K, T]) reset(t *Tree[K, T]) {
+	b.txn = t.Txn()
+	b.txn.root = b.txn.writeNode(b.txn.root, false)
+	b.path = append(b.path[:0], builderNode[K, T]{n: b.txn.root})
+	b.last = nil
+}
+
+// Append adds an entry to the tree being built. It returns an error wrapping
+// ErrKeyOrder if k doesn't sort after the previous key, including if it's
+// the same key, and the error of InsertErr for keys invalid for the tree. The
+// entry isn't added on error, so appending can continue with a valid key.
+func (b *Builder[K, T]) Append(k []K, v T) error {
+	t := b.txn
+	if err := t.validateKey(k); err != nil {
+		return err
+	}
+	depth := 0
+	if t.size > 0 {
+		if t.root.compareKeys(b.last, k) >= 0 {
+			return fmt.Errorf("%w: %v after %v", ErrKeyOrder, k, b.last)
+		}
+		depth = longestPrefix(b.last, k)
+	}
+	if t.cloneKeys {
+		k = slices.Clone(k)
+	}
+	if t.bloom != nil {
+		t.bloom.add(k)
+	}
+
+	// Nodes below the shared prefix of the last and the new key can't get
+	// any more keys.
+	var done *Node[K, T]
+	for b.path[len(b.path)-1].depth > depth {
+		done = b.path[len(b.path)-1].n
+		b.finish(done)
+		b.path = b.path[:len(b.path)-1]
+	}
+
+	// If the keys diverge within the prefix of the last node finished, it's
+	// split where they diverge.
+	parent := b.path[len(b.path)-1]
+	if done != nil && parent.depth < depth {
+		split := &Node[K, T]{
+			mutateCh: t.newWatch(),
+			ext:      t.root.childExt(),
+			prefix:   k[parent.depth:depth],
+		}
+		done.prefix = done.prefix[depth-parent.depth:]
+		split.edges = edges[K, T]{{label: done.prefix[0], node: done}}
+		parent.n.edges[len(parent.n.edges)-1].node = split
+		parent = builderNode[K, T]{n: split, depth: depth}
+		b.path = append(b.path, parent)
+	}
+
+	leaf := &leafNode[K, T]{
+		mutateCh: t.newWatch(),
+		key:      k,
+		val:      v,
+		ext:      t.newLeafExt(),
+	}
+	if len(k) == depth {
+		// Only the empty key, as the first key, ends at an open node.
+		parent.n.leaf = leaf
+	} else {
+		child := &Node[K, T]{
+			mutateCh: t.newWatch(),
+			ext:      t.root.childExt(),
+			prefix:   k[depth:],
+			leaf:     leaf,
+		}
+		parent.n.edges = append(parent.n.edges, edge[K, T]{label: k[depth], node: child})
+		b.path = append(b.path, builderNode[K, T]{n: child, depth: len(k)})
+	}
+	b.last = k
+	t.size++
+	return nil
+}
+
+// finish completes a node that gets no more edges.
+func (b *Builder[K, T]) finish(n *Node[K, T]) {
+	b.txn.indexEdges(n)
+	n.size = 0
+	if n.leaf != nil {
+		n.size = 1
+	}
+	for _, e := range n.edges {
+		n.size += e.node.size
+	}
+	b.txn.aggregate(n)
+}
+
+// Build returns the tree holding all entries appended so far, and resets the
+// builder to build another tree with the same options.
+func (b *Builder[K, T]) Build() *Tree[K, T] {
+	for i := len(b.path) - 1; i >= 0; i-- {
+		b.finish(b.path[i].n)
+	}
+	t := b.txn.Commit()
+	b.reset(newTree[K, T](t.options))
+	return t
+}
@@ -0,0 +1,196 @@
+package iradix
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// treeShape describes every node of a tree in pre-order, to compare the
+// structure of trees built in different ways.
+func treeShape[K keyT, T any](r *Tree[K, T]) []string {
+	var out []string
+	for iter := r.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		n := iter.Front()
+		desc := fmt.Sprintf("%v edges=%d size=%d agg=%v", iter.Path(), len(n.edges), n.size, n.Aggregate())
+		if n.leaf != nil {
+			desc += fmt.Sprintf(" leaf=%v:%v", n.leaf.key, n.leaf.val)
+		}
+		out = append(out, desc)
+	}
+	return out
+}
+
+func TestInsertBatch(t *testing.T) {
+	seedRand()
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("seed: %d", seed)
+		}
+	})
+
+	sum := WithAggregator(0, func(acc, v int) int { return acc + v }, func(a, b int) int { return a + b })
+	for _, n := range []int{1, 2, 10, 1000} {
+		var entries []Entry[byte, int]
+		for i := 0; i < n; i++ {
+			// Short keys make shared prefixes, prefix keys and duplicates
+			// likely.
+			k := []byte(randomHexString(rng.Intn(3)))
+			entries = append(entries, Entry[byte, int]{Key: k, Value: i})
+		}
+
+		seq := New[byte, int](sum).Txn()
+		for _, e := range entries {
+			seq.Insert(e.Key, e.Value)
+		}
+		expected := seq.Commit()
+
+		batch := New[byte, int](sum).Txn()
+		batch.InsertBatch(entries)
+		r := batch.Commit()
+
+		if r.Len() != expected.Len() {
+			t.Fatalf("n=%d: got len %d, want %d", n, r.Len(), expected.Len())
+		}
+		got, want := treeShape(r), treeShape(expected)
+		if len(got) != len(want) {
+			t.Fatalf("n=%d: got %d nodes, want %d", n, len(got), len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("n=%d: got node %s, want %s", n, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestInsertBatch_NonEmpty(t *testing.T) {
+	entries := []Entry[byte, int]{
+		{Key: []byte("foo"), Value: 1},
+		{Key: []byte("bar"), Value: 2},
+		{Key: []byte("foo"), Value: 3},
+	}
+
+	// Batches into a non-empty tree fall back to individual inserts.
+	r, _, _ := New[byte, int]().Insert([]byte("foobar"), 0)
+	txn := r.Txn()
+	txn.InsertBatch(entries)
+	r = txn.Commit()
+	if r.Len() != 3 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	if v, _ := r.Get([]byte("foo")); v != 3 {
+		t.Fatalf("last duplicate should win: %d", v)
+	}
+
+	// The given slice isn't reordered, and the built tree can be written
+	// further in the same transaction.
+	txn = New[byte, int]().Txn()
+	txn.InsertBatch(entries)
+	if string(entries[0].Key) != "foo" || string(entries[1].Key) != "bar" {
+		t.Fatalf("entries were modified")
+	}
+	txn.Insert([]byte("foo"), 4)
+	txn.Delete([]byte("bar"))
+	r = txn.Commit()
+	if v, _ := r.Get([]byte("foo")); v != 4 || r.Len() != 1 {
+		t.Fatalf("bad: %d %d", v, r.Len())
+	}
+}
+
+func TestInsertBatch_Notify(t *testing.T) {
+	r := New[byte, int]()
+	watch, _, _ := r.Root().GetWatch([]byte("foo"))
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.InsertBatch([]Entry[byte, int]{{Key: []byte("foo"), Value: 1}})
+	txn.Commit()
+	select {
+	case <-watch:
+	default:
+		t.Fatalf("watch was not triggered")
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	seedRand()
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("seed: %d", seed)
+		}
+	})
+
+	sum := WithAggregator(0, func(acc, v int) int { return acc + v }, func(a, b int) int { return a + b })
+	for _, n := range []int{0, 1, 2, 10, 1000} {
+		seq := New[byte, int](sum).Txn()
+		for i := 0; i < n; i++ {
+			seq.Insert([]byte(randomHexString(rng.Intn(4))), i)
+		}
+		expected := seq.Commit()
+
+		b := NewBuilder[byte, int](sum)
+		expected.Root().Walk(func(k []byte, v int) bool {
+			if err := b.Append(k, v); err != nil {
+				t.Fatalf("n=%d: unexpected error: %v", n, err)
+			}
+			return true
+		})
+		r := b.Build()
+
+		if r.Len() != expected.Len() {
+			t.Fatalf("n=%d: got len %d, want %d", n, r.Len(), expected.Len())
+		}
+		got, want := treeShape(r), treeShape(expected)
+		if len(got) != len(want) {
+			t.Fatalf("n=%d: got %d nodes, want %d", n, len(got), len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("n=%d: got node %s, want %s", n, got[i], want[i])
+			}
+		}
+	}
+
+	// Wide nodes are indexed once complete.
+	b := NewBuilder[byte, int](WithDenseEdgeIndex())
+	for i := 0; i < 256; i++ {
+		for _, k := range [][]byte{{byte(i)}, {byte(i), 1}, {byte(i), 2}} {
+			if err := b.Append(k, i); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+	checkDenseIndexes(t, b.Build())
+
+	// Keys must be strictly increasing in the tree's order, and rejected
+	// keys don't stop the build.
+	reverse := WithComparator(func(a, b byte) int { return int(b) - int(a) })
+	b = NewBuilder[byte, int](reverse, WithRejectEmptyKey())
+	for _, k := range []string{"b", "a", "ab"} {
+		if err := b.Append([]byte(k), 1); err != nil {
+			t.Fatalf("%q: unexpected error: %v", k, err)
+		}
+	}
+	for _, k := range []string{"a", "ab", "b"} {
+		if err := b.Append([]byte(k), 2); !errors.Is(err, ErrKeyOrder) {
+			t.Fatalf("%q: expected ErrKeyOrder, got %v", k, err)
+		}
+	}
+	if err := b.Append(nil, 2); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("expected ErrEmptyKey, got %v", err)
+	}
+	r := b.Build()
+	verifyTree(t, []string{"b", "a", "ab"}, r)
+
+	// The builder starts over after Build, and the built tree is
+	// independent of it.
+	if err := b.Append([]byte("a"), 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r2 := b.Build(); r2.Len() != 1 || r.Len() != 3 {
+		t.Fatalf("bad trees after reuse: %d %d", r2.Len(), r.Len())
+	}
+	if v, _ := r.Get([]byte("a")); v != 1 {
+		t.Fatalf("built tree was modified")
+	}
+}
@@ -1,6 +1,10 @@
 package benchmark
 
 import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"slices"
 	"testing"
 	"time"
 
@@ -31,6 +35,13 @@ var profiles = []Profile{
 		Seed:        0,
 		MakeTree:    NewGenericRadix,
 	},
+	{
+		Name:        "generic-lazy",
+		Depth:       16,
+		Cardinality: 256,
+		Seed:        0,
+		MakeTree:    NewGenericRadixWithLazyChannels,
+	},
 }
 
 func NewHashicorpRadix(keys [][]byte) Txn {
@@ -55,6 +66,19 @@ func NewGenericRadix(keys [][]byte) Txn {
 	return tree.Txn()
 }
 
+func NewGenericRadixWithLazyChannels(keys [][]byte) Txn {
+	tree := iradix.New[byte, struct{}](
+		iradix.WithCacheProvider(iradix.MapCache(0)),
+		iradix.WithLazyChannels(),
+	)
+	txn := tree.Txn()
+	for _, key := range keys {
+		txn.Insert(key, struct{}{})
+	}
+	tree = txn.Commit()
+	return tree.Txn()
+}
+
 func NewGenericRadixWithLRU(keys [][]byte) Txn {
 	tree := iradix.New[byte, struct{}](
 		iradix.WithCacheProvider(NewLRU),
@@ -100,3 +124,255 @@ func BenchmarkRadix(b *testing.B) {
 		Run(b, profile)
 	}
 }
+
+// BenchmarkZipfGet compares lookups following a Zipf distribution, where a few
+// keys dominate the reads, with and without the frequency hint.
+func BenchmarkZipfGet(b *testing.B) {
+	for _, tc := range []struct {
+		name string
+		opts []iradix.Option
+	}{
+		{"generic", nil},
+		{"generic-hint", []iradix.Option{iradix.WithFrequencyHint()}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			rng := rand.New(rand.NewSource(0))
+			keys := makeKeys(rng, 256, 16, 100000)
+			tree := iradix.New[byte, struct{}](tc.opts...)
+			txn := tree.Txn()
+			for _, key := range keys {
+				txn.Insert(key, struct{}{})
+			}
+			tree = txn.Commit()
+
+			zipf := rand.NewZipf(rng, 1.2, 1, uint64(len(keys)-1))
+			reads := make([][]byte, b.N)
+			for i := range reads {
+				reads[i] = keys[zipf.Uint64()]
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Get(reads[i])
+			}
+		})
+	}
+}
+
+// BenchmarkAbsentGet compares lookups of mostly absent keys with and without
+// the Bloom prefilter.
+func BenchmarkAbsentGet(b *testing.B) {
+	for _, tc := range []struct {
+		name string
+		opts []iradix.Option
+	}{
+		{"generic", nil},
+		{"generic-bloom", []iradix.Option{iradix.WithBloomPrefilter(100000, 0.01)}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			rng := rand.New(rand.NewSource(0))
+			keys := makeKeys(rng, 256, 16, 100000)
+			tree := iradix.New[byte, struct{}](tc.opts...)
+			txn := tree.Txn()
+			for _, key := range keys[:len(keys)/2] {
+				txn.Insert(key, struct{}{})
+			}
+			tree = txn.Commit()
+
+			// Nine out of ten reads are for keys that were never inserted.
+			reads := make([][]byte, b.N)
+			for i := range reads {
+				if rng.Intn(10) == 0 {
+					reads[i] = keys[rng.Intn(len(keys)/2)]
+				} else {
+					reads[i] = keys[len(keys)/2+rng.Intn(len(keys)/2)]
+				}
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Get(reads[i])
+			}
+		})
+	}
+}
+
+// BenchmarkBuild compares building a tree from unsorted keys by inserting
+// them one by one and with a single batch insert, and from sorted keys with a
+// batch insert and a Builder.
+func BenchmarkBuild(b *testing.B) {
+	rng := rand.New(rand.NewSource(0))
+	keys := makeKeys(rng, 256, 16, 100000)
+	entries := make([]iradix.Entry[byte, struct{}], len(keys))
+	for i, key := range keys {
+		entries[i] = iradix.Entry[byte, struct{}]{Key: key}
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			txn := iradix.New[byte, struct{}]().Txn()
+			for _, key := range keys {
+				txn.Insert(key, struct{}{})
+			}
+			txn.Commit()
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			txn := iradix.New[byte, struct{}]().Txn()
+			txn.InsertBatch(entries)
+			txn.Commit()
+		}
+	})
+
+	// Sorted input lets the builder skip sorting and deduplication.
+	sorted := slices.Clone(entries)
+	slices.SortFunc(sorted, func(a, b iradix.Entry[byte, struct{}]) int {
+		return bytes.Compare(a.Key, b.Key)
+	})
+	sorted = slices.CompactFunc(sorted, func(a, b iradix.Entry[byte, struct{}]) bool {
+		return bytes.Equal(a.Key, b.Key)
+	})
+	b.Run("batch-sorted", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			txn := iradix.New[byte, struct{}]().Txn()
+			txn.InsertBatch(sorted)
+			txn.Commit()
+		}
+	})
+	b.Run("builder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			builder := iradix.NewBuilder[byte, struct{}]()
+			for _, e := range sorted {
+				if err := builder.Append(e.Key, e.Value); err != nil {
+					b.Fatal(err)
+				}
+			}
+			builder.Build()
+		}
+	})
+}
+
+// BenchmarkWideGet compares lookups in a tree where every node has 256 edges,
+// with and without the dense edge index.
+func BenchmarkWideGet(b *testing.B) {
+	for _, tc := range []struct {
+		name string
+		opts []iradix.Option
+	}{
+		{"generic", nil},
+		{"generic-dense", []iradix.Option{iradix.WithDenseEdgeIndex()}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			var keys [][]byte
+			for i := 0; i < 1<<16; i++ {
+				keys = append(keys, []byte{byte(i >> 8), byte(i), 0})
+			}
+			tree := iradix.New[byte, struct{}](tc.opts...)
+			txn := tree.Txn()
+			for _, key := range keys {
+				txn.Insert(key, struct{}{})
+			}
+			tree = txn.Commit()
+
+			rng := rand.New(rand.NewSource(0))
+			reads := make([][]byte, b.N)
+			for i := range reads {
+				reads[i] = keys[rng.Intn(len(keys))]
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Get(reads[i])
+			}
+		})
+	}
+}
+
+// BenchmarkHasMany compares checking the membership of many keys one by one
+// and with a single sorted sweep, for random keys and for keys sharing long
+// prefixes, given in random and in sorted order.
+func BenchmarkHasMany(b *testing.B) {
+	rng := rand.New(rand.NewSource(0))
+	var clustered [][]byte
+	for i := 0; i < 100000; i++ {
+		clustered = append(clustered, []byte(fmt.Sprintf("tenants/%03d/objects/%08d", i%100, i)))
+	}
+	for _, tc := range []struct {
+		name string
+		keys [][]byte
+	}{
+		{"random", makeKeys(rng, 256, 16, 100000)},
+		{"clustered", clustered},
+	} {
+		tree := iradix.New[byte, struct{}]()
+		txn := tree.Txn()
+		for _, key := range tc.keys[:len(tc.keys)/2] {
+			txn.Insert(key, struct{}{})
+		}
+		tree = txn.Commit()
+
+		queries := make([][]byte, 10000)
+		for i := range queries {
+			queries[i] = tc.keys[rng.Intn(len(tc.keys))]
+		}
+
+		b.Run(tc.name+"/has", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, q := range queries {
+					tree.Has(q)
+				}
+			}
+		})
+		b.Run(tc.name+"/hasmany", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tree.HasMany(queries)
+			}
+		})
+		sorted := slices.Clone(queries)
+		slices.SortFunc(sorted, bytes.Compare)
+		b.Run(tc.name+"/hasmany-sorted", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tree.HasMany(sorted)
+			}
+		})
+	}
+}
+
+// BenchmarkIterate compares iterating a tree whose keys are shared with the
+// caller and one created with WithCloneKeys, which copies every key returned.
+func BenchmarkIterate(b *testing.B) {
+	rng := rand.New(rand.NewSource(0))
+	keys := makeKeys(rng, 256, 16, 100000)
+	for _, tc := range []struct {
+		name string
+		opts []iradix.Option
+	}{
+		{"generic", nil},
+		{"generic-clone", []iradix.Option{iradix.WithCloneKeys()}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			tree := iradix.New[byte, struct{}](tc.opts...)
+			txn := tree.Txn()
+			for _, key := range keys {
+				txn.Insert(key, struct{}{})
+			}
+			tree = txn.Commit()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				iter := tree.Root().Iterator()
+				for _, _, ok := iter.Next(); ok; _, _, ok = iter.Next() {
+				}
+			}
+		})
+	}
+}
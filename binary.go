@@ -0,0 +1,117 @@
+package iradix
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// valueCodec encodes values for MarshalBinary and UnmarshalBinary in trees
+// created with WithValueCodec.
+type valueCodec[T any] struct {
+	encode func(T) ([]byte, error)
+	decode func([]byte) (T, error)
+}
+
+// WithValueCodec sets how Tree.MarshalBinary and Tree.UnmarshalBinary encode
+// values, for value types gob can't handle or that have a better encoding.
+// Without it values are encoded with gob. The value type T must match the
+// tree's, otherwise New panics.
+func WithValueCodec[T any](encode func(T) ([]byte, error), decode func([]byte) (T, error)) Option {
+	return func(o *options) {
+		o.valueCodec = &valueCodec[T]{encode: encode, decode: decode}
+	}
+}
+
+// binaryEntries is what MarshalBinary encodes with gob: all keys in key order
+// and their values, either as is or encoded by the value codec.
+type binaryEntries[K keyT, V any] struct {
+	Keys   [][]K
+	Values []V
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It encodes all keys and
+// values of the tree with gob, using the value codec set with WithValueCodec
+// for values if any. Only the entries are encoded, not the nodes holding
+// them, and neither are watch channels or options.
+func (t *Tree[K, T]) MarshalBinary() ([]byte, error) {
+	keys := make([][]K, 0, t.size)
+	values := make([]T, 0, t.size)
+	t.root.Walk(func(k []K, v T) bool {
+		keys = append(keys, k)
+		values = append(values, v)
+		return true
+	})
+
+	var buf bytes.Buffer
+	if t.valueCodec == nil {
+		err := gob.NewEncoder(&buf).Encode(binaryEntries[K, T]{Keys: keys, Values: values})
+		return buf.Bytes(), err
+	}
+	codec := t.valueCodec.(*valueCodec[T])
+	encoded := make([][]byte, len(values))
+	for i, v := range values {
+		b, err := codec.encode(v)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = b
+	}
+	err := gob.NewEncoder(&buf).Encode(binaryEntries[K, []byte]{Keys: keys, Values: encoded})
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It replaces the
+// contents of t with the entries encoded by MarshalBinary, inserted in a
+// single transaction with Txn.InsertBatch. The options of t are kept, so the
+// tree should be created with the same value codec it was marshaled with; a
+// zero Tree gets the default options. The new nodes have new watch channels,
+// and watches on the old contents of t aren't notified. Like all decoding
+// into an existing value, this modifies t in place, so it must not be called
+// on a tree shared with other goroutines.
+func (t *Tree[K, T]) UnmarshalBinary(data []byte) error {
+	if t.root == nil {
+		*t = *New[K, T]()
+	}
+
+	var keys [][]K
+	var values []T
+	if t.valueCodec == nil {
+		var entries binaryEntries[K, T]
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+			return err
+		}
+		keys, values = entries.Keys, entries.Values
+	} else {
+		var entries binaryEntries[K, []byte]
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+			return err
+		}
+		codec := t.valueCodec.(*valueCodec[T])
+		values = make([]T, len(entries.Values))
+		for i, b := range entries.Values {
+			v, err := codec.decode(b)
+			if err != nil {
+				return err
+			}
+			values[i] = v
+		}
+		keys = entries.Keys
+	}
+	if len(keys) != len(values) {
+		return fmt.Errorf("%w: %d keys for %d values", ErrInvalidEncoding, len(keys), len(values))
+	}
+
+	o := t.options
+	txn := New[K, T](func(opts *options) { *opts = o }).Txn()
+	entries := make([]Entry[K, T], len(keys))
+	for i, k := range keys {
+		if err := txn.validateKey(k); err != nil {
+			return err
+		}
+		entries[i] = Entry[K, T]{Key: k, Value: values[i]}
+	}
+	txn.InsertBatch(entries)
+	*t = *txn.Commit()
+	return nil
+}
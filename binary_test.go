@@ -0,0 +1,84 @@
+package iradix
+
+import (
+	"encoding"
+	"errors"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Tree[byte, int])(nil)
+	_ encoding.BinaryUnmarshaler = (*Tree[byte, int])(nil)
+)
+
+func TestBinaryMarshaler(t *testing.T) {
+	type point struct{ X, Y int }
+	r := New[byte, point]()
+	for i, k := range []string{"", "foo", "foobar", "zip"} {
+		r, _, _ = r.Insert([]byte(k), point{i, -i})
+	}
+	data, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A zero tree gets the default options.
+	var out Tree[byte, point]
+	if err := out.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(r, func(a, b point) bool { return a == b }) {
+		t.Fatalf("bad round trip")
+	}
+	if out, _, _ := out.Insert([]byte("new"), point{}); out.Len() != 5 {
+		t.Fatalf("bad len: %d", out.Len())
+	}
+
+	// Options of the target tree are kept.
+	into := New[byte, point](WithLazyChannels())
+	if err := into.UnmarshalBinary(data); err != nil || into.Len() != 4 || !into.lazyChannels {
+		t.Fatalf("bad: %v", err)
+	}
+
+	if err := out.UnmarshalBinary(data[:len(data)/2]); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestBinaryMarshaler_ValueCodec(t *testing.T) {
+	boom := errors.New("boom")
+	codec := WithValueCodec(func(v int) ([]byte, error) {
+		if v < 0 {
+			return nil, boom
+		}
+		return encInt(v), nil
+	}, decInt)
+
+	r := New[string, int](codec)
+	for i, k := range [][]string{{"a", "b"}, {"a"}, {"c", ""}} {
+		r, _, _ = r.Insert(k, i)
+	}
+	data, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := New[string, int](codec)
+	if err := out.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(r, func(a, b int) bool { return a == b }) {
+		t.Fatalf("bad round trip")
+	}
+
+	r, _, _ = r.Insert([]string{"x"}, -1)
+	if _, err := r.MarshalBinary(); err != boom {
+		t.Fatalf("expected codec error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	New[string, string](codec)
+}
@@ -0,0 +1,164 @@
+package iradix
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// bloomFilter is a Bloom filter of keys. It is shared by all versions of a
+// tree created with WithBloomPrefilter, so it holds the keys of all of them
+// and is safe for concurrent use. Keys are only ever added, which keeps it a
+// superset of the keys of every version.
+type bloomFilter[K keyT] struct {
+	bits   []atomic.Uint64
+	hashes int
+
+	// kind is the kind of the key elements. Strings have to be hashed by
+	// content rather than by their memory, and floats by value, since -0 and
+	// +0 are equal but differ in memory.
+	kind reflect.Kind
+}
+
+// newBloomFilter returns a filter sized for n keys with the false positive
+// rate fp.
+func newBloomFilter[K keyT](n int, fp float64) *bloomFilter[K] {
+	n = max(n, 1)
+	if fp <= 0 || fp >= 1 {
+		fp = 0.01
+	}
+	m := math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2))
+	words := max(int(math.Ceil(m/64)), 1)
+	hashes := max(int(math.Round(m/float64(n)*math.Ln2)), 1)
+
+	var zero K
+	return &bloomFilter[K]{
+		bits:   make([]atomic.Uint64, words),
+		hashes: hashes,
+		kind:   reflect.TypeOf(zero).Kind(),
+	}
+}
+
+// hash returns two independent 64-bit hashes of k, combined into the bit
+// positions with double hashing.
+func (f *bloomFilter[K]) hash(k []K) (uint64, uint64) {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset)
+	add := func(b []byte) {
+		for _, c := range b {
+			h ^= uint64(c)
+			h *= prime
+		}
+	}
+	switch f.kind {
+	case reflect.String:
+		for i := range k {
+			s := *(*string)(unsafe.Pointer(&k[i]))
+			add(unsafe.Slice(unsafe.StringData(s), len(s)))
+			// Separate the elements, so that "ab", "c" and "a", "bc" differ.
+			add([]byte{0})
+		}
+	case reflect.Float32:
+		var buf [4]byte
+		for i := range k {
+			v := *(*float32)(unsafe.Pointer(&k[i]))
+			if v == 0 {
+				v = 0 // Turn -0 into +0.
+			}
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(v))
+			add(buf[:])
+		}
+	case reflect.Float64:
+		var buf [8]byte
+		for i := range k {
+			v := *(*float64)(unsafe.Pointer(&k[i]))
+			if v == 0 {
+				v = 0 // Turn -0 into +0.
+			}
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+			add(buf[:])
+		}
+	default:
+		if len(k) > 0 {
+			add(unsafe.Slice((*byte)(unsafe.Pointer(&k[0])), len(k)*int(unsafe.Sizeof(k[0]))))
+		}
+	}
+
+	// Derive the second hash by mixing the first, as in splitmix64.
+	h2 := h
+	h2 ^= h2 >> 30
+	h2 *= 0xbf58476d1ce4e5b9
+	h2 ^= h2 >> 27
+	h2 *= 0x94d049bb133111eb
+	h2 ^= h2 >> 31
+	return h, h2 | 1
+}
+
+// add adds k to the filter.
+func (f *bloomFilter[K]) add(k []K) {
+	h1, h2 := f.hash(k)
+	m := uint64(len(f.bits)) * 64
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		w := &f.bits[bit/64]
+		mask := uint64(1) << (bit % 64)
+		for {
+			old := w.Load()
+			if old&mask != 0 || w.CompareAndSwap(old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+// mayContain returns false if k was definitely never added to the filter.
+func (f *bloomFilter[K]) mayContain(k []K) bool {
+	h1, h2 := f.hash(k)
+	m := uint64(len(f.bits)) * 64
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		if f.bits[bit/64].Load()&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WithBloomPrefilter makes the tree maintain a Bloom filter of inserted keys,
+// sized for expectedN keys with a false positive rate of fp, which Tree.Get
+// and Tree.Has consult to answer for most absent keys without descending the
+// tree. This pays off when most lookups are for absent keys. The filter is
+// shared by all versions of the tree, and keys can't be removed from it, so
+// deleted keys, keys of other versions and keys inserted by transactions that
+// were never committed only add false positives, which are answered by
+// searching the tree as usual; there are never false negatives. Use
+// Tree.RebuildFilter to get rid of them, or when the tree has grown well past
+// expectedN.
+func WithBloomPrefilter(expectedN int, fp float64) Option {
+	return func(o *options) {
+		o.bloomN = expectedN
+		o.bloomFP = fp
+	}
+}
+
+// RebuildFilter returns a copy of a tree created with WithBloomPrefilter with
+// a new filter holding only its own keys, sized for at least its current
+// size. Other versions of the tree keep using the old filter. It returns the
+// tree itself for trees without the filter.
+func (t *Tree[K, T]) RebuildFilter() *Tree[K, T] {
+	if t.bloom == nil {
+		return t
+	}
+	nt := *t
+	nt.bloom = newBloomFilter[K](max(t.bloomN, t.size), t.bloomFP)
+	t.root.Walk(func(k []K, _ T) bool {
+		nt.bloom.add(k)
+		return true
+	})
+	return &nt
+}
@@ -0,0 +1,99 @@
+package iradix
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestBloomPrefilter(t *testing.T) {
+	r := New[byte, int](WithBloomPrefilter(1000, 0.01))
+	txn := r.Txn()
+	for i := 0; i < 1000; i++ {
+		txn.Insert([]byte(fmt.Sprintf("key-%d", i)), i)
+	}
+	r = txn.Commit()
+
+	// No false negatives.
+	for i := 0; i < 1000; i++ {
+		k := []byte(fmt.Sprintf("key-%d", i))
+		if v, ok := r.Get(k); !ok || v != i || !r.Has(k) {
+			t.Fatalf("missing key %q", k)
+		}
+	}
+
+	// Absent keys are mostly filtered out.
+	positives := 0
+	for i := 0; i < 10000; i++ {
+		k := []byte(fmt.Sprintf("absent-%d", i))
+		if r.Has(k) {
+			t.Fatalf("unexpected key %q", k)
+		}
+		if r.bloom.mayContain(k) {
+			positives++
+		}
+	}
+	if positives > 300 {
+		t.Fatalf("too many false positives: %d", positives)
+	}
+
+	// Deleted keys become false positives, rebuilding drops them.
+	r, _, _ = r.Delete([]byte("key-1"))
+	if r.Has([]byte("key-1")) || !r.bloom.mayContain([]byte("key-1")) {
+		t.Fatalf("expected deleted key to remain in the filter")
+	}
+	old := r
+	r = r.RebuildFilter()
+	if r.bloom == old.bloom || old.Len() != r.Len() {
+		t.Fatalf("filter was not rebuilt")
+	}
+	for i := 2; i < 1000; i++ {
+		if !r.Has([]byte(fmt.Sprintf("key-%d", i))) {
+			t.Fatalf("missing key %d after rebuild", i)
+		}
+	}
+
+	// New versions share the rebuilt filter, and keep working.
+	r2, _, _ := r.Insert([]byte("new"), 1)
+	if r2.bloom != r.bloom || !r2.Has([]byte("new")) {
+		t.Fatalf("bad filter after insert")
+	}
+
+	// Trees without the filter.
+	plain := New[byte, int]()
+	if plain.RebuildFilter() != plain || plain.Has([]byte("x")) {
+		t.Fatalf("bad plain tree")
+	}
+}
+
+func TestBloomPrefilter_KeyTypes(t *testing.T) {
+	strs := New[string, int](WithBloomPrefilter(10, 0.001))
+	strs, _, _ = strs.Insert([]string{"ab", "c"}, 1)
+	if !strs.Has([]string{"ab", "c"}) || strs.Has([]string{"a", "bc"}) {
+		t.Fatalf("bad string keys")
+	}
+	h1, _ := strs.bloom.hash([]string{"ab", "c"})
+	h2, _ := strs.bloom.hash([]string{"a", "bc"})
+	if h1 == h2 {
+		t.Fatalf("string elements aren't separated")
+	}
+
+	ints := New[int64, int](WithBloomPrefilter(10, 0.001))
+	ints, _, _ = ints.Insert([]int64{1, -2, 3}, 1)
+	if !ints.Has([]int64{1, -2, 3}) || ints.Has([]int64{1, 2, 3}) {
+		t.Fatalf("bad int keys")
+	}
+
+	// -0 and +0 are the same key, so they must hash alike.
+	negZero := math.Copysign(0, -1)
+	floats := New[float64, int](WithBloomPrefilter(10, 0.001))
+	floats, _, _ = floats.Insert([]float64{1.5, negZero}, 1)
+	if !floats.Has([]float64{1.5, 0}) || !floats.Has([]float64{1.5, negZero}) || floats.Has([]float64{1.5, 1}) {
+		t.Fatalf("bad float keys")
+	}
+	floats32 := New[float32, int](WithBloomPrefilter(10, 0.001))
+	floats32, _, _ = floats32.Insert([]float32{0}, 1)
+	if !floats32.Has([]float32{float32(negZero)}) {
+		t.Fatalf("bad float32 keys")
+	}
+}
@@ -0,0 +1,186 @@
+package iradix
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrPrefixNotFound is returned by BrowsePrefix if no key starts with the
+// given prefix.
+var ErrPrefixNotFound = errors.New("iradix: no keys with the given prefix")
+
+// NestedValueKey is the reserved key under which ToNestedMap stores the value
+// of a key that is also a prefix of other keys. A key segment equal to
+// NestedValueKey is indistinguishable from such a value.
+const NestedValueKey = "@value"
+
+// ToStringMap returns a map holding all entries of a byte-keyed tree, with
+// keys converted to strings.
+func ToStringMap[T any](t *Tree[byte, T]) map[string]T {
+	out := make(map[string]T, t.size)
+	it := t.root.Iterator()
+	for k, v, ok := it.Next(); ok; k, v, ok = it.Next() {
+		out[string(k)] = v
+	}
+	return out
+}
+
+// FromStringMap builds a byte-keyed tree, created with the given options,
+// holding all entries of m. The entries are inserted in a single transaction
+// in key order, which keeps the nodes written by consecutive inserts together.
+func FromStringMap[T any](m map[string]T, opts ...Option) *Tree[byte, T] {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	txn := New[byte, T](opts...).Txn()
+	for _, k := range keys {
+		txn.Insert([]byte(k), m[k])
+	}
+	return txn.Commit()
+}
+
+// ToNestedMap converts a byte-keyed tree into nested maps by splitting keys on
+// sep. Every segment but the last one maps to a nested map[string]any, and the
+// last segment maps to the value. If a key is both a value and a prefix of
+// other keys, e.g. "a" and "a/b", its value is stored in the nested map under
+// NestedValueKey:
+//
+//	{"a": {"@value": <a>, "b": <a/b>}}
+func ToNestedMap[T any](t *Tree[byte, T], sep byte) map[string]any {
+	root := newNestedLevel()
+	t.root.Walk(func(k []byte, v T) bool {
+		// Only the maps made here are levels. Values may be maps too, which
+		// must be neither descended into nor written to.
+		level := root
+		segments := bytes.Split(k, []byte{sep})
+		for _, s := range segments[:len(segments)-1] {
+			seg := string(s)
+			child, ok := level.children[seg]
+			if !ok {
+				// Either there is nothing under this segment yet, or it holds a
+				// value which we move down to make room for the nested keys.
+				child = newNestedLevel()
+				if v, ok := level.m[seg]; ok {
+					child.m[NestedValueKey] = v
+				}
+				level.m[seg] = child.m
+				level.children[seg] = child
+			}
+			level = child
+		}
+		last := string(segments[len(segments)-1])
+		if child, ok := level.children[last]; ok {
+			child.m[NestedValueKey] = v
+		} else {
+			level.m[last] = v
+		}
+		return true
+	})
+	return root.m
+}
+
+// nestedLevel is a map built by ToNestedMap, along with the levels nested in
+// it.
+type nestedLevel struct {
+	m        map[string]any
+	children map[string]*nestedLevel
+}
+
+func newNestedLevel() *nestedLevel {
+	return &nestedLevel{m: make(map[string]any), children: make(map[string]*nestedLevel)}
+}
+
+// BrowsePrefix returns what's one level below prefix in a byte-keyed tree,
+// which is what an interactive key browser needs to drill down. value points
+// at the value of prefix if it's a key itself, and is nil otherwise; as with
+// Tree.GetPtr, it must never be written through. children are the
+// continuations of prefix in key order, each running up to the next point
+// where keys fork or a key ends, so appending one of them to prefix gives the
+// next prefix to browse. If prefix ends in the middle of such a run, the rest
+// of the run is the only child. ErrPrefixNotFound is returned if no key starts
+// with prefix.
+func BrowsePrefix[T any](t *Tree[byte, T], prefix []byte) (value *T, children []string, err error) {
+	n := t.root
+	search := prefix
+	for len(search) > 0 {
+		_, child := n.getEdge(search[0])
+		switch {
+		case child == nil:
+			return nil, nil, ErrPrefixNotFound
+		case bytes.HasPrefix(search, child.prefix):
+			search = search[len(child.prefix):]
+			n = child
+		case bytes.HasPrefix(child.prefix, search):
+			return nil, []string{string(child.prefix[len(search):])}, nil
+		default:
+			return nil, nil, ErrPrefixNotFound
+		}
+	}
+
+	if n.leaf == nil && len(n.edges) == 0 {
+		// Only the root of an empty tree has neither.
+		return nil, nil, ErrPrefixNotFound
+	}
+	if n.leaf != nil {
+		value = &n.leaf.val
+	}
+	for _, e := range n.edges {
+		children = append(children, string(e.node.prefix))
+	}
+	return value, children, nil
+}
+
+// WalkGlob walks all keys of a byte-keyed tree that match a shell-style glob
+// pattern, in key order. A '*' matches any sequence of bytes, including an
+// empty one, and a '?' matches exactly one byte. All other bytes match
+// themselves. Only the subtree under the literal prefix of the pattern, i.e.
+// everything before the first wildcard, is visited, so patterns starting with
+// a literal are much cheaper than a full scan.
+func WalkGlob[T any](t *Tree[byte, T], pattern string, fn WalkFn[byte, T]) {
+	t.root.WalkPrefix([]byte(globLiteralPrefix(pattern)), func(k []byte, v T) bool {
+		if globMatch(pattern, k) {
+			return fn(k, v)
+		}
+		return true
+	})
+}
+
+// globLiteralPrefix returns the part of the pattern before the first wildcard.
+func globLiteralPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// globMatch reports whether the key matches the glob pattern. It backtracks to
+// the last '*' on mismatch, which keeps it linear in the common case.
+func globMatch(pattern string, key []byte) bool {
+	p, k := 0, 0
+	star, starKey := -1, 0
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, starKey = p, k
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]):
+			p++
+			k++
+		case star >= 0:
+			// Let the last star consume one more byte and retry.
+			starKey++
+			p, k = star+1, starKey
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,189 @@
+package iradix
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestToNestedMap(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"a/b", "a/c", "a", "a/c/d", "b", "c/d/e"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	expected := map[string]any{
+		"a": map[string]any{
+			NestedValueKey: 2,
+			"b":            0,
+			"c": map[string]any{
+				NestedValueKey: 1,
+				"d":            3,
+			},
+		},
+		"b": 4,
+		"c": map[string]any{
+			"d": map[string]any{
+				"e": 5,
+			},
+		},
+	}
+	out := ToNestedMap(r, '/')
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("bad nested map:\n got: %v\nwant: %v", out, expected)
+	}
+
+	if out := ToNestedMap(New[byte, int](), '/'); len(out) != 0 {
+		t.Fatalf("expected empty map, got %v", out)
+	}
+
+	// Map values are values, not levels, and are left alone.
+	value := map[string]any{"x": 1}
+	maps := New[byte, any]()
+	maps, _, _ = maps.Insert([]byte("a"), value)
+	maps, _, _ = maps.Insert([]byte("a/b"), 2)
+	maps, _, _ = maps.Insert([]byte("c"), value)
+	expected = map[string]any{
+		"a": map[string]any{
+			NestedValueKey: value,
+			"b":            2,
+		},
+		"c": value,
+	}
+	if out := ToNestedMap(maps, '/'); !reflect.DeepEqual(out, expected) {
+		t.Fatalf("bad nested map:\n got: %v\nwant: %v", out, expected)
+	}
+	if len(value) != 1 {
+		t.Fatalf("value was modified: %v", value)
+	}
+}
+
+func TestStringMap(t *testing.T) {
+	m := map[string]int{"": 0, "a": 1, "ab": 2, "abc": 3, "b": 4, "zip": 5}
+	r := FromStringMap(m, WithLazyChannels())
+	if r.Len() != len(m) || !r.lazyChannels {
+		t.Fatalf("bad tree: %d", r.Len())
+	}
+	for k, v := range m {
+		if got, ok := r.Get([]byte(k)); !ok || got != v {
+			t.Fatalf("bad value for %q: %v %v", k, got, ok)
+		}
+	}
+	if out := ToStringMap(r); !reflect.DeepEqual(out, m) {
+		t.Fatalf("bad round trip: %v", out)
+	}
+
+	if r := FromStringMap[int](nil); r.Len() != 0 {
+		t.Fatalf("expected empty tree")
+	}
+	if out := ToStringMap(New[byte, int]()); out == nil || len(out) != 0 {
+		t.Fatalf("expected empty map, got %v", out)
+	}
+}
+
+func TestWalkGlob(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{
+		"abc",
+		"abcxyz",
+		"abc/xyz",
+		"abc/def/xyz",
+		"abd/xyz",
+		"xyz",
+		"zabcxyz",
+		"a?c",
+	}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		pattern string
+		out     []string
+	}{
+		{"abc*xyz", []string{"abc/def/xyz", "abc/xyz", "abcxyz"}},
+		{"abc", []string{"abc"}},
+		{"ab?/xyz", []string{"abc/xyz", "abd/xyz"}},
+		{"abd*", []string{"abd/xyz"}},
+		{"a?c", []string{"a?c", "abc"}},
+		{"*xyz", []string{"abc/def/xyz", "abc/xyz", "abcxyz", "abd/xyz", "xyz", "zabcxyz"}},
+		{"*abc*", []string{"abc", "abc/def/xyz", "abc/xyz", "abcxyz", "zabcxyz"}},
+		{"abc/*/xyz", []string{"abc/def/xyz"}},
+		{"???", []string{"a?c", "abc", "xyz"}},
+		{"*", keys},
+		{"nope*", nil},
+		{"", nil},
+	}
+	for _, tc := range cases {
+		var out []string
+		WalkGlob(r, tc.pattern, func(k []byte, _ int) bool {
+			out = append(out, string(k))
+			return true
+		})
+		want := slices.Clone(tc.out)
+		slices.Sort(want)
+		if !slices.Equal(out, want) {
+			t.Fatalf("pattern %q: got %v, want %v", tc.pattern, out, want)
+		}
+	}
+}
+
+func TestGlobLiteralPrefix(t *testing.T) {
+	cases := map[string]string{
+		"abc*xyz": "abc",
+		"abc?":    "abc",
+		"abc":     "abc",
+		"*abc":    "",
+		"":        "",
+	}
+	for pattern, prefix := range cases {
+		if got := globLiteralPrefix(pattern); got != prefix {
+			t.Fatalf("pattern %q: got prefix %q, want %q", pattern, got, prefix)
+		}
+	}
+}
+
+func TestBrowsePrefix(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"app/api/v1", "app/api/v2", "app/web", "app", "lib/core", "lib/util"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		prefix   string
+		value    int
+		hasValue bool
+		children []string
+	}{
+		{"", 0, false, []string{"app", "lib/"}},
+		{"app", 3, true, []string{"/"}},
+		{"app/", 0, false, []string{"api/v", "web"}},
+		{"app/api/v", 0, false, []string{"1", "2"}},
+		{"app/api/v1", 0, true, nil},
+		{"ap", 0, false, []string{"p"}},
+		{"lib/", 0, false, []string{"core", "util"}},
+		{"lib/co", 0, false, []string{"re"}},
+	}
+	for _, tc := range cases {
+		value, children, err := BrowsePrefix(r, []byte(tc.prefix))
+		if err != nil {
+			t.Fatalf("prefix %q: %v", tc.prefix, err)
+		}
+		if (value != nil) != tc.hasValue || value != nil && *value != tc.value {
+			t.Fatalf("prefix %q: bad value %v", tc.prefix, value)
+		}
+		if !slices.Equal(children, tc.children) {
+			t.Fatalf("prefix %q: got children %q, want %q", tc.prefix, children, tc.children)
+		}
+	}
+
+	for _, prefix := range []string{"b", "apx", "app/api/v3", "lib/core/x"} {
+		if _, _, err := BrowsePrefix(r, []byte(prefix)); err != ErrPrefixNotFound {
+			t.Fatalf("prefix %q: expected ErrPrefixNotFound, got %v", prefix, err)
+		}
+	}
+	if _, _, err := BrowsePrefix(New[byte, int](), nil); err != ErrPrefixNotFound {
+		t.Fatalf("expected ErrPrefixNotFound for empty tree, got %v", err)
+	}
+}
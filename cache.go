@@ -1,5 +1,10 @@
 package iradix
 
+import (
+	"container/list"
+	"sync/atomic"
+)
+
 // CacheProvider can be used as an option to cache nodes during transaction execution.
 type CacheProvider func() Cache
 
@@ -46,3 +51,119 @@ func (m mapCache) Has(ptr CacheableNode) bool {
 func (m mapCache) Clear() {
 	clear(m)
 }
+
+// LRUCache returns a provider of caches holding up to maxEntries nodes, evicting
+// the least recently set or checked node when full. This bounds the memory a
+// large transaction spends on tracking writable nodes. Evicting a node only
+// means it's copied again the next time it's written, instead of being
+// modified in place, so a small cache costs extra copies but never affects
+// correctness. A maxEntries below 1 is treated as 1.
+func LRUCache(maxEntries int) CacheProvider {
+	maxEntries = max(maxEntries, 1)
+	return func() Cache {
+		return &lruCache{
+			maxEntries: maxEntries,
+			entries:    make(map[CacheableNode]*list.Element),
+			order:      list.New(),
+		}
+	}
+}
+
+// lruCache keeps its nodes in order, most recently used first.
+type lruCache struct {
+	maxEntries int
+	entries    map[CacheableNode]*list.Element
+	order      *list.List
+}
+
+func (c *lruCache) Set(ptr CacheableNode) {
+	if e, ok := c.entries[ptr]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	if c.order.Len() >= c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(CacheableNode))
+	}
+	c.entries[ptr] = c.order.PushFront(ptr)
+}
+
+func (c *lruCache) Has(ptr CacheableNode) bool {
+	e, ok := c.entries[ptr]
+	if ok {
+		c.order.MoveToFront(e)
+	}
+	return ok
+}
+
+func (c *lruCache) Clear() {
+	clear(c.entries)
+	c.order.Init()
+}
+
+// CacheStats are the counts of calls to the caches provided by a StatsCache.
+type CacheStats struct {
+	// Sets is the number of nodes marked writable.
+	Sets uint64
+	// Hits is the number of checks finding the node writable, so it was
+	// modified in place.
+	Hits uint64
+	// Misses is the number of checks not finding the node, so it was
+	// copied, whether it's a node of an earlier version of the tree or one
+	// evicted from the cache.
+	Misses uint64
+}
+
+// StatsCache counts the use of caches, for comparing cache providers on a
+// workload. Pass its Provider to WithCacheProvider. It's safe for concurrent
+// use, and counts the calls to all caches it provides, that is of all
+// transactions on trees created with it, without changing their behavior.
+type StatsCache struct {
+	inner              CacheProvider
+	sets, hits, misses atomic.Uint64
+}
+
+// NewStatsCache returns a StatsCache counting the use of the caches provided
+// by inner.
+func NewStatsCache(inner CacheProvider) *StatsCache {
+	return &StatsCache{inner: inner}
+}
+
+// Provider returns the provider of counting caches to pass to
+// WithCacheProvider.
+func (s *StatsCache) Provider() CacheProvider {
+	return func() Cache {
+		return &statsCache{Cache: s.inner(), stats: s}
+	}
+}
+
+// Stats returns the counts so far.
+func (s *StatsCache) Stats() CacheStats {
+	return CacheStats{
+		Sets:   s.sets.Load(),
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+	}
+}
+
+// statsCache is a cache provided by a StatsCache.
+type statsCache struct {
+	Cache
+	stats *StatsCache
+}
+
+func (c *statsCache) Set(ptr CacheableNode) {
+	c.stats.sets.Add(1)
+	c.Cache.Set(ptr)
+}
+
+func (c *statsCache) Has(ptr CacheableNode) bool {
+	ok := c.Cache.Has(ptr)
+	if ok {
+		c.stats.hits.Add(1)
+	} else {
+		c.stats.misses.Add(1)
+	}
+	return ok
+}
@@ -0,0 +1,101 @@
+package iradix
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := LRUCache(2)()
+	a, b, d := &Node[byte, int]{}, &Node[byte, int]{}, &Node[byte, int]{}
+	c.Set(a)
+	c.Set(b)
+	if !c.Has(a) || !c.Has(b) {
+		t.Fatalf("missing nodes")
+	}
+
+	// a was checked last, so b is evicted.
+	c.Has(a)
+	c.Set(d)
+	if !c.Has(a) || c.Has(b) || !c.Has(d) {
+		t.Fatalf("bad eviction")
+	}
+
+	c.Clear()
+	if c.Has(a) || c.Has(d) {
+		t.Fatalf("cache wasn't cleared")
+	}
+	c.Set(b)
+	if !c.Has(b) {
+		t.Fatalf("missing node after clear")
+	}
+}
+
+func TestLRUCache_Txn(t *testing.T) {
+	// A tiny cache makes the transaction copy nodes it wrote before again,
+	// which costs copies but gives the same tree.
+	expected := New[byte, int]().Txn()
+	small := New[byte, int](WithCacheProvider(LRUCache(1))).Txn()
+	for _, txn := range []*Txn[byte, int]{expected, small} {
+		for i := 0; i < 1000; i++ {
+			txn.Insert([]byte(fmt.Sprintf("key/%d", i%300)), i)
+			if i%7 == 0 {
+				txn.Delete([]byte(fmt.Sprintf("key/%d", i%100)))
+			}
+		}
+	}
+	if small.NodesCopied() <= expected.NodesCopied() {
+		t.Fatalf("expected more copies with a small cache: %d vs %d", small.NodesCopied(), expected.NodesCopied())
+	}
+	got, want := treeShape(small.Commit()), treeShape(expected.Commit())
+	if len(got) != len(want) {
+		t.Fatalf("got %d nodes, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got node %s, want %s", got[i], want[i])
+		}
+	}
+}
+
+func TestStatsCache(t *testing.T) {
+	build := func(provider CacheProvider) (*Tree[byte, int], int) {
+		txn := New[byte, int](WithCacheProvider(provider)).Txn()
+		for i := 0; i < 100; i++ {
+			txn.Insert([]byte(fmt.Sprintf("key/%d", i%30)), i)
+		}
+		return txn.Commit(), txn.NodesCopied()
+	}
+	expected, _ := build(MapCache(16))
+
+	for _, tc := range []struct {
+		name  string
+		inner CacheProvider
+	}{
+		{"map", MapCache(16)},
+		{"lru", LRUCache(4)},
+		{"none", NoCache},
+	} {
+		stats := NewStatsCache(tc.inner)
+		r, copied := build(stats.Provider())
+		if got, want := treeShape(r), treeShape(expected); !slices.Equal(got, want) {
+			t.Fatalf("%s: the cache changed the tree", tc.name)
+		}
+
+		s := stats.Stats()
+		if s.Sets == 0 || s.Misses == 0 {
+			t.Fatalf("%s: bad stats: %+v", tc.name, s)
+		}
+		// Every copy follows a miss.
+		if s.Misses < uint64(copied) {
+			t.Fatalf("%s: %d misses but %d nodes copied", tc.name, s.Misses, copied)
+		}
+		if tc.name == "none" && s.Hits != 0 {
+			t.Fatalf("%s: unexpected hits: %+v", tc.name, s)
+		}
+		if tc.name == "map" && s.Hits == 0 {
+			t.Fatalf("%s: expected hits: %+v", tc.name, s)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package iradix
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// byteReader returns r if it can read single bytes, which the decoder needs,
+// and wraps it in a buffered reader otherwise.
+func byteReader(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return bufio.NewReader(r)
+}
+
+// encoder writes the parts of deltas and marshaled trees, remembering the
+// first error.
+type encoder[K keyT] struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *encoder[K]) writeByte(b byte) {
+	if e.err == nil {
+		e.err = e.w.WriteByte(b)
+	}
+}
+
+func (e *encoder[K]) writeUvarint(v uint64) {
+	if e.err == nil {
+		_, e.err = e.w.Write(binary.AppendUvarint(e.buf[:0], v))
+	}
+}
+
+func (e *encoder[K]) writeBytes(b []byte) {
+	e.writeUvarint(uint64(len(b)))
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+// writeKey writes the number of elements of k followed by the elements.
+func (e *encoder[K]) writeKey(k []K) {
+	e.writeUvarint(uint64(len(k)))
+	for _, elem := range k {
+		if e.err != nil {
+			return
+		}
+		v := reflect.ValueOf(elem)
+		switch v.Kind() {
+		case reflect.Uint8:
+			e.err = e.w.WriteByte(byte(v.Uint()))
+		case reflect.String:
+			e.writeBytes([]byte(v.String()))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			_, e.err = e.w.Write(binary.AppendVarint(e.buf[:0], v.Int()))
+		case reflect.Float32, reflect.Float64:
+			e.writeUvarint(math.Float64bits(v.Float()))
+		default:
+			e.writeUvarint(v.Uint())
+		}
+	}
+}
+
+// decoder reads the parts of deltas and marshaled trees. Running out of input
+// is reported as the invalid error, since the end of both is implied by the
+// data.
+type decoder[K keyT] struct {
+	r       io.ByteReader
+	invalid error
+}
+
+func (d *decoder[K]) wrap(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end", d.invalid)
+	}
+	return err
+}
+
+func (d *decoder[K]) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	return b, d.wrap(err)
+}
+
+func (d *decoder[K]) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(d.r)
+	return v, d.wrap(err)
+}
+
+func (d *decoder[K]) readBytes() ([]byte, error) {
+	n, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	// Don't trust the length for the allocation, a corrupt one would make
+	// us allocate arbitrary amounts of memory.
+	var b []byte
+	for i := uint64(0); i < n; i++ {
+		c, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, c)
+	}
+	return b, nil
+}
+
+func (d *decoder[K]) readKey() ([]K, error) {
+	n, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	var k []K
+	for i := uint64(0); i < n; i++ {
+		var elem K
+		v := reflect.ValueOf(&elem).Elem()
+		switch v.Kind() {
+		case reflect.Uint8:
+			var b byte
+			b, err = d.readByte()
+			v.SetUint(uint64(b))
+		case reflect.String:
+			var b []byte
+			b, err = d.readBytes()
+			v.SetString(string(b))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var x int64
+			x, err = binary.ReadVarint(d.r)
+			err = d.wrap(err)
+			v.SetInt(x)
+		case reflect.Float32, reflect.Float64:
+			var x uint64
+			x, err = d.readUvarint()
+			v.SetFloat(math.Float64frombits(x))
+		default:
+			var x uint64
+			x, err = d.readUvarint()
+			v.SetUint(x)
+		}
+		if err != nil {
+			return nil, err
+		}
+		k = append(k, elem)
+	}
+	return k, nil
+}
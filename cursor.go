@@ -0,0 +1,77 @@
+package iradix
+
+// Cursor moves forward and backward through the keys of a tree from a shared
+// position, e.g. to show the entries around a key. The position is between two
+// keys: Next returns the key after it and moves past it, and Prev returns the
+// key before it and moves before it, so Prev after Next returns the same key
+// again. Moving in the same direction takes the usual iterator steps, and
+// turning around seeks again from the last key returned.
+type Cursor[K keyT, T any] struct {
+	node *Node[K, T]
+
+	// bound is the key next to the position, which is right before bound if
+	// before is set, and right after it otherwise.
+	bound  []K
+	before bool
+
+	// Only one of the iterators is in use at a time, and it's positioned at
+	// the cursor's position.
+	iter *Iterator[K, T]
+	rev  *ReverseIterator[K, T]
+}
+
+// CursorAt returns a cursor positioned before key, so that Next returns the
+// smallest key greater than or equal to key, and Prev the largest key less
+// than it. key doesn't need to be stored in the tree.
+func (n *Node[K, T]) CursorAt(key []K) *Cursor[K, T] {
+	return &Cursor[K, T]{node: n, bound: key, before: true}
+}
+
+// Next returns the key after the cursor's position and its value, and moves
+// the position past it. It returns false if there are no more keys, and the
+// position doesn't move then.
+func (c *Cursor[K, T]) Next() ([]K, T, bool) {
+	if c.iter == nil {
+		c.rev = nil
+		c.iter = c.node.Iterator()
+		c.iter.SeekLowerBound(c.bound)
+		if !c.before {
+			// The bound itself is before the position, so skip it.
+			if k, v, ok := c.iter.Next(); !ok || !keyEqual(k, c.bound) {
+				return c.moved(k, v, ok, false)
+			}
+		}
+	}
+	k, v, ok := c.iter.Next()
+	return c.moved(k, v, ok, false)
+}
+
+// Prev returns the key before the cursor's position and its value, and moves
+// the position before it. It returns false if there are no more keys, and the
+// position doesn't move then.
+func (c *Cursor[K, T]) Prev() ([]K, T, bool) {
+	if c.rev == nil {
+		c.iter = nil
+		c.rev = c.node.ReverseIterator()
+		c.rev.SeekReverseLowerBound(c.bound)
+		if c.before {
+			// The bound itself is after the position, so skip it.
+			if k, v, ok := c.rev.Previous(); !ok || !keyEqual(k, c.bound) {
+				return c.moved(k, v, ok, true)
+			}
+		}
+	}
+	k, v, ok := c.rev.Previous()
+	return c.moved(k, v, ok, true)
+}
+
+// moved records the key returned by a move as the new bound, with the
+// position right before it if the cursor moved backward, and right after it
+// otherwise.
+func (c *Cursor[K, T]) moved(k []K, v T, ok, backward bool) ([]K, T, bool) {
+	if ok {
+		c.bound = k
+		c.before = backward
+	}
+	return k, v, ok
+}
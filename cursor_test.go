@@ -0,0 +1,108 @@
+package iradix
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"", "a", "ab", "abc", "b", "ba"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	// moves applies a sequence of moves, n for Next and p for Prev, and
+	// returns the keys returned, with - for no key.
+	moves := func(c *Cursor[byte, int], seq string) string {
+		var out []string
+		for _, m := range seq {
+			var k []byte
+			var v int
+			var ok bool
+			if m == 'n' {
+				k, v, ok = c.Next()
+			} else {
+				k, v, ok = c.Prev()
+			}
+			switch {
+			case !ok:
+				out = append(out, "-")
+			case keys[v] != string(k):
+				t.Fatalf("bad value %d for %q", v, k)
+			default:
+				out = append(out, fmt.Sprintf("%q", k))
+			}
+		}
+		return fmt.Sprint(out)
+	}
+	cases := []struct {
+		key  string
+		seq  string
+		want string
+	}{
+		{"ab", "nnn", `["ab" "abc" "b"]`},
+		{"ab", "ppp", `["a" "" -]`},
+		{"ab", "npnp", `["ab" "ab" "ab" "ab"]`},
+		{"ab", "nnpp", `["ab" "abc" "abc" "ab"]`},
+		{"ab", "pnpn", `["a" "a" "a" "a"]`},
+		{"aa", "nppn", `["ab" "ab" "a" "a"]`},
+		{"", "pn", `[- ""]`},
+		{"z", "npp", `[- "ba" "b"]`},
+		{"b", "nnnpp", `["b" "ba" - "ba" "b"]`},
+		{"", "ppnnp", `[- - "" "a" "a"]`},
+	}
+	for _, tc := range cases {
+		if got := moves(r.Root().CursorAt([]byte(tc.key)), tc.seq); got != tc.want {
+			t.Fatalf("%q %s: got %s, want %s", tc.key, tc.seq, got, tc.want)
+		}
+	}
+}
+
+func TestCursor_Random(t *testing.T) {
+	seedRand()
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("seed: %d", seed)
+		}
+	})
+
+	r := New[byte, int]()
+	var keys []string
+	for i := 0; i < 200; i++ {
+		k := randomHexString(1 + rng.Intn(3))
+		var ok bool
+		if r, _, ok = r.Insert([]byte(k), 0); !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	// The position is the index of the key after it in the sorted keys.
+	for round := 0; round < 100; round++ {
+		start := randomHexString(1 + rng.Intn(3))
+		pos, _ := slices.BinarySearch(keys, start)
+		c := r.Root().CursorAt([]byte(start))
+		for i := 0; i < 50; i++ {
+			if rng.Intn(2) == 0 {
+				k, _, ok := c.Next()
+				if ok != (pos < len(keys)) || ok && string(k) != keys[pos] {
+					t.Fatalf("%q: bad next %q %v at %d", start, k, ok, pos)
+				}
+				if ok {
+					pos++
+				}
+			} else {
+				k, _, ok := c.Prev()
+				if ok != (pos > 0) || ok && string(k) != keys[pos-1] {
+					t.Fatalf("%q: bad prev %q %v at %d", start, k, ok, pos)
+				}
+				if ok {
+					pos--
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package iradix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidDelta is returned by ApplyDelta if the delta is malformed, or
+// doesn't apply to the given tree.
+var ErrInvalidDelta = errors.New("iradix: invalid delta")
+
+// deltaVersion is the first byte of every delta, bumped on format changes.
+const deltaVersion = 1
+
+// Delta operations. Every operation is followed by the key, and added and
+// changed keys by the length of the encoded value and the value itself.
+const (
+	deltaEnd byte = iota
+	deltaAdd
+	deltaChange
+	deltaDelete
+)
+
+// EncodeDelta writes a compact binary patch to w which turns old into newer
+// when applied with ApplyDelta. It holds an operation for every key that was
+// added, changed or deleted, in key order, with values encoded by encVal.
+// Values are compared by their encoding, so only keys whose encoded value
+// differs are written. The trees are compared with a lockstep walk which
+// skips subtrees they share, so encoding the delta between a tree and a
+// recent ancestor is proportional to the size of the change.
+//
+// Key elements are encoded portably: strings and byte elements as is,
+// integers as varints and floats as their IEEE 754 bits, so a delta can be
+// applied on another platform.
+func EncodeDelta[K keyT, T any](old, newer *Tree[K, T], w io.Writer, encVal func(T) []byte) error {
+	bw := bufio.NewWriter(w)
+	enc := encoder[K]{w: bw}
+	enc.writeByte(deltaVersion)
+	walkDiff(old.root, newer.root, func(a, b T) bool { return false }, func(k []K, oldVal, newVal T, kind ChangeKind) bool {
+		switch kind {
+		case ChangeAdded:
+			enc.writeByte(deltaAdd)
+			enc.writeKey(k)
+			enc.writeBytes(encVal(newVal))
+		case ChangeModified:
+			v := encVal(newVal)
+			if string(v) == string(encVal(oldVal)) {
+				return true
+			}
+			enc.writeByte(deltaChange)
+			enc.writeKey(k)
+			enc.writeBytes(v)
+		case ChangeDeleted:
+			enc.writeByte(deltaDelete)
+			enc.writeKey(k)
+		}
+		return enc.err == nil
+	})
+	enc.writeByte(deltaEnd)
+	if enc.err != nil {
+		return enc.err
+	}
+	return bw.Flush()
+}
+
+// ApplyDelta reads a delta written by EncodeDelta from r and applies it to
+// old in a single transaction, decoding values with decVal. It returns the
+// new tree, which holds the same entries as the tree the delta was encoded
+// for. ErrInvalidDelta is returned if the delta is malformed, or adds a key
+// already in old, or changes or deletes a key not in it, which means it was
+// encoded against another tree. It's also returned, wrapping the error of
+// Txn.InsertErr, for keys old rejects. old is never modified.
+func ApplyDelta[K keyT, T any](old *Tree[K, T], r io.Reader, decVal func([]byte) (T, error)) (*Tree[K, T], error) {
+	dec := decoder[K]{r: byteReader(r), invalid: ErrInvalidDelta}
+	if v, err := dec.readByte(); err != nil {
+		return nil, err
+	} else if v != deltaVersion {
+		return nil, fmt.Errorf("%w: unknown version %d", ErrInvalidDelta, v)
+	}
+
+	txn := old.Txn()
+	for {
+		op, err := dec.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if op == deltaEnd {
+			return txn.Commit(), nil
+		}
+		if op > deltaDelete {
+			return nil, fmt.Errorf("%w: unknown operation %d", ErrInvalidDelta, op)
+		}
+		k, err := dec.readKey()
+		if err != nil {
+			return nil, err
+		}
+		if op == deltaDelete {
+			if _, ok := txn.Delete(k); !ok {
+				return nil, fmt.Errorf("%w: deleted key %v not found", ErrInvalidDelta, k)
+			}
+			continue
+		}
+
+		b, err := dec.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		v, err := decVal(b)
+		if err != nil {
+			return nil, err
+		}
+		_, exists, err := txn.InsertErr(k, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+		}
+		if exists != (op == deltaChange) {
+			return nil, fmt.Errorf("%w: unexpected presence of key %v", ErrInvalidDelta, k)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package iradix
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func encInt(v int) []byte {
+	return []byte(strconv.Itoa(v))
+}
+
+func decInt(b []byte) (int, error) {
+	return strconv.Atoi(string(b))
+}
+
+func TestDelta(t *testing.T) {
+	old := New[byte, int]()
+	txn := old.Txn()
+	for i := 0; i < 1000; i++ {
+		txn.Insert([]byte("key/"+strconv.Itoa(i)), i)
+	}
+	txn.Insert(nil, -1)
+	old = txn.Commit()
+
+	txn = old.Txn()
+	txn.Insert([]byte("key/10"), 100)   // changed
+	txn.Insert([]byte("key/11"), 11)    // same value
+	txn.Insert([]byte("key/1000"), 1e3) // added
+	txn.Insert([]byte("new"), 1)        // added
+	txn.Delete([]byte("key/500"))       // deleted
+	txn.Delete(nil)                     // deleted
+	cur := txn.Commit()
+
+	var delta bytes.Buffer
+	if err := EncodeDelta(old, cur, &delta, encInt); err != nil {
+		t.Fatal(err)
+	}
+	var full bytes.Buffer
+	if err := EncodeDelta(New[byte, int](), cur, &full, encInt); err != nil {
+		t.Fatal(err)
+	}
+	if delta.Len()*100 > full.Len() {
+		t.Fatalf("delta is too large: %d bytes, full encoding is %d", delta.Len(), full.Len())
+	}
+
+	// Applying the delta, or the full encoding to an empty tree, gives the
+	// new tree.
+	eq := func(a, b int) bool { return a == b }
+	r, err := ApplyDelta(old, bytes.NewReader(delta.Bytes()), decInt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Equal(cur, eq) {
+		t.Fatalf("bad round trip")
+	}
+	if r, err = ApplyDelta(New[byte, int](), &full, decInt); err != nil || !r.Equal(cur, eq) {
+		t.Fatalf("bad round trip of the full encoding: %v", err)
+	}
+	if v, _ := old.Get([]byte("key/10")); v != 10 {
+		t.Fatalf("old tree was modified")
+	}
+
+	// A delta doesn't apply to other trees.
+	if _, err := ApplyDelta(cur, bytes.NewReader(delta.Bytes()), decInt); !errors.Is(err, ErrInvalidDelta) {
+		t.Fatalf("expected ErrInvalidDelta, got %v", err)
+	}
+
+	// Truncated and corrupt deltas are rejected.
+	for _, b := range [][]byte{nil, delta.Bytes()[:delta.Len()-1], {deltaVersion + 1}, {deltaVersion, 42}} {
+		if _, err := ApplyDelta(old, bytes.NewReader(b), decInt); !errors.Is(err, ErrInvalidDelta) {
+			t.Fatalf("%v: expected ErrInvalidDelta, got %v", b, err)
+		}
+	}
+
+	// Keys the target tree rejects fail the delta instead of panicking.
+	full.Reset()
+	if err := EncodeDelta(New[byte, int](), old, &full, encInt); err != nil {
+		t.Fatal(err)
+	}
+	strict := New[byte, int](WithRejectEmptyKey())
+	if _, err := ApplyDelta(strict, &full, decInt); !errors.Is(err, ErrInvalidDelta) || !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("expected ErrInvalidDelta and ErrEmptyKey, got %v", err)
+	}
+}
+
+func TestDelta_KeyTypes(t *testing.T) {
+	testDeltaKeys(t, [][]string{{"foo", "bar"}, {"foo"}, {"", "x"}})
+	testDeltaKeys(t, [][]int{{1, -2, 3}, {1}, {-1 << 40}})
+	testDeltaKeys(t, [][]uint16{{1, 65535}, {1}})
+	testDeltaKeys(t, [][]float32{{1.5, -0.25}, {1.5}})
+}
+
+func testDeltaKeys[K keyT](t *testing.T, keys [][]K) {
+	t.Helper()
+	cur := New[K, int]()
+	for i, k := range keys {
+		cur, _, _ = cur.Insert(k, i)
+	}
+	var delta bytes.Buffer
+	if err := EncodeDelta(New[K, int](), cur, &delta, encInt); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ApplyDelta(New[K, int](), &delta, decInt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Equal(cur, func(a, b int) bool { return a == b }) {
+		t.Fatalf("bad round trip of %v", keys)
+	}
+}
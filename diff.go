@@ -0,0 +1,253 @@
+package iradix
+
+import "slices"
+
+// ChangeKind describes how a key differs between two versions of a tree.
+type ChangeKind int
+
+const (
+	// ChangeAdded means the key is present only in the newer tree.
+	ChangeAdded ChangeKind = iota
+	// ChangeModified means the key is present in both trees with different values.
+	ChangeModified
+	// ChangeDeleted means the key is present only in the base tree.
+	ChangeDeleted
+)
+
+// String returns a human-readable name of the change kind.
+func (c ChangeKind) String() string {
+	switch c {
+	case ChangeAdded:
+		return "added"
+	case ChangeModified:
+		return "modified"
+	case ChangeDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// WalkChangedFrom walks all keys that differ between base and t in key order.
+// Added and modified keys are reported with their value in t, deleted keys
+// carry their value in base. Values of keys present in both trees are
+// compared with eq. Subtrees shared by both trees are skipped entirely, so
+// comparing a tree with a recent ancestor is proportional to the size of the
+// change. Returning false from fn stops the walk.
+func (t *Tree[K, T]) WalkChangedFrom(base *Tree[K, T], eq func(a, b T) bool, fn func(k []K, v T, kind ChangeKind) bool) {
+	walkChanged(base.root, t.root, eq, fn)
+}
+
+// Equal returns true if t and other hold exactly the same keys, and eq
+// returns true for the values of every key. Both trees are walked in lockstep,
+// skipping subtrees they share, and the walk stops at the first difference.
+func (t *Tree[K, T]) Equal(other *Tree[K, T], eq func(a, b T) bool) bool {
+	if t.size != other.size {
+		return false
+	}
+	return walkChanged(t.root, other.root, eq, func([]K, T, ChangeKind) bool {
+		return false
+	})
+}
+
+// DiffPrefix compares only the keys starting with prefix in two trees,
+// returning the entries that were added, removed or changed from oldTree to
+// newTree, in key order. Added and changed entries carry their value in
+// newTree, removed ones their value in oldTree, and values are compared with
+// eq. Both trees are navigated to the subtree holding the prefix first, so
+// the rest of the trees is never looked at, and shared subtrees are skipped
+// as in Tree.WalkChangedFrom.
+func DiffPrefix[K keyT, T any](oldTree, newTree *Tree[K, T], prefix []K, eq func(a, b T) bool) (added, removed, changed []Entry[K, T]) {
+	oldRoot := oldTree.root.prefixSubtree(prefix)
+	newRoot := newTree.root.prefixSubtree(prefix)
+	walkChanged(oldRoot, newRoot, eq, func(k []K, v T, kind ChangeKind) bool {
+		e := Entry[K, T]{Key: k, Value: v}
+		switch kind {
+		case ChangeAdded:
+			added = append(added, e)
+		case ChangeDeleted:
+			removed = append(removed, e)
+		case ChangeModified:
+			changed = append(changed, e)
+		}
+		return true
+	})
+	return added, removed, changed
+}
+
+// DiffEntry is a key that differs between two trees, along with its values in
+// both. For added keys Old is the zero value, for removed keys New is.
+type DiffEntry[K keyT, T any] struct {
+	Key []K
+	Old T
+	New T
+}
+
+// Diff compares two trees and returns the entries that were added, removed or
+// changed from old to newer, in key order, with values compared by eq. Both
+// trees are walked in lockstep and subtrees they share are skipped, so
+// diffing a tree against a recent ancestor is proportional to the size of the
+// change.
+func Diff[K keyT, T any](old, newer *Tree[K, T], eq func(a, b T) bool) (added, removed, changed []DiffEntry[K, T]) {
+	walkDiff(old.root, newer.root, eq, func(k []K, oldVal, newVal T, kind ChangeKind) bool {
+		e := DiffEntry[K, T]{Key: k, Old: oldVal, New: newVal}
+		switch kind {
+		case ChangeAdded:
+			added = append(added, e)
+		case ChangeDeleted:
+			removed = append(removed, e)
+		case ChangeModified:
+			changed = append(changed, e)
+		}
+		return true
+	})
+	return added, removed, changed
+}
+
+// ChangedPrefixes returns prefixes covering all keys that differ between old
+// and newer, in key order, for invalidating caches by prefix. Both trees are
+// descended in lockstep, skipping subtrees they share, and the prefixes are
+// the paths of the shallowest nodes where they differ: a node whose own key
+// was added, removed or changed according to eq covers all keys under it, as
+// does a node present in only one of the trees. Where a node was split or
+// merged, the prefix is the part of the path both versions have in common.
+// Every returned prefix has a changed key under it, and no prefix is under
+// another one, so the result is empty if and only if the trees are equal.
+func ChangedPrefixes[K keyT, T any](old, newer *Tree[K, T], eq func(a, b T) bool) [][]K {
+	var out [][]K
+	changedPrefixes(old.root, newer.root, nil, eq, &out)
+	return out
+}
+
+// changedPrefixes appends the prefixes of the differences between oldNode and
+// newNode, which have the same effective path, to out.
+func changedPrefixes[K keyT, T any](oldNode, newNode *Node[K, T], path []K, eq func(a, b T) bool, out *[][]K) {
+	if oldNode == newNode {
+		return
+	}
+	oldLeaf, newLeaf := oldNode.leaf, newNode.leaf
+	if (oldLeaf == nil) != (newLeaf == nil) || oldLeaf != newLeaf && oldLeaf != nil && !eq(oldLeaf.val, newLeaf.val) {
+		*out = append(*out, path)
+		return
+	}
+
+	// Edges are sorted by label in both nodes, so they're merged in order.
+	i, j := 0, 0
+	for i < len(oldNode.edges) || j < len(newNode.edges) {
+		var c int
+		switch {
+		case i == len(oldNode.edges):
+			c = 1
+		case j == len(newNode.edges):
+			c = -1
+		default:
+			c = compareKeysFunc(newNode.comparator(), oldNode.edges[i].node.prefix[:1], newNode.edges[j].node.prefix[:1])
+		}
+		switch {
+		case c < 0:
+			*out = append(*out, append(slices.Clip(path), oldNode.edges[i].node.prefix...))
+			i++
+		case c > 0:
+			*out = append(*out, append(slices.Clip(path), newNode.edges[j].node.prefix...))
+			j++
+		default:
+			oldChild, newChild := oldNode.edges[i].node, newNode.edges[j].node
+			if keyEqual(oldChild.prefix, newChild.prefix) {
+				changedPrefixes(oldChild, newChild, append(slices.Clip(path), newChild.prefix...), eq, out)
+			} else {
+				common := newChild.prefix[:longestPrefix(oldChild.prefix, newChild.prefix)]
+				*out = append(*out, append(slices.Clip(path), common...))
+			}
+			i++
+			j++
+		}
+	}
+}
+
+// walkChanged does a lockstep walk of two raw iterators, reporting leaf
+// differences to fn with the value in the tree holding the key, or in the new
+// tree if both do. Returns false if the walk was aborted.
+func walkChanged[K keyT, T any](oldRoot, newRoot *Node[K, T], eq func(a, b T) bool, fn func(k []K, v T, kind ChangeKind) bool) bool {
+	return walkDiff(oldRoot, newRoot, eq, func(k []K, oldVal, newVal T, kind ChangeKind) bool {
+		if kind == ChangeDeleted {
+			return fn(k, oldVal, kind)
+		}
+		return fn(k, newVal, kind)
+	})
+}
+
+// walkDiff does a lockstep walk of two raw iterators, reporting leaf
+// differences to fn with the values in both trees. Returns false if the walk
+// was aborted.
+func walkDiff[K keyT, T any](oldRoot, newRoot *Node[K, T], eq func(a, b T) bool, fn func(k []K, oldVal, newVal T, kind ChangeKind) bool) bool {
+	var zero T
+	oldIter := oldRoot.rawIterator()
+	newIter := newRoot.rawIterator()
+	for oldIter.Front() != nil || newIter.Front() != nil {
+		oldElem := oldIter.Front()
+		newElem := newIter.Front()
+
+		// If either side is exhausted, everything that remains on the other
+		// side is a change.
+		if newElem == nil {
+			if oldElem.isLeaf() && !fn(oldElem.leaf.publicKey(), oldElem.leaf.val, zero, ChangeDeleted) {
+				return false
+			}
+			oldIter.Next()
+			continue
+		}
+		if oldElem == nil {
+			if newElem.isLeaf() && !fn(newElem.leaf.publicKey(), zero, newElem.leaf.val, ChangeAdded) {
+				return false
+			}
+			newIter.Next()
+			continue
+		}
+
+		cmp := newRoot.compareKeys(oldIter.Path(), newIter.Path())
+
+		// If the old tree is behind, this node was deleted.
+		if cmp < 0 {
+			if oldElem.isLeaf() && !fn(oldElem.leaf.publicKey(), oldElem.leaf.val, zero, ChangeDeleted) {
+				return false
+			}
+			oldIter.Next()
+			continue
+		}
+
+		// If the old tree is ahead, this node was added.
+		if cmp > 0 {
+			if newElem.isLeaf() && !fn(newElem.leaf.publicKey(), zero, newElem.leaf.val, ChangeAdded) {
+				return false
+			}
+			newIter.Next()
+			continue
+		}
+
+		// Same path. Identical nodes mean identical subtrees, which we don't
+		// need to descend into.
+		if oldElem == newElem {
+			oldIter.SkipChildren()
+			newIter.SkipChildren()
+		} else {
+			switch {
+			case oldElem.isLeaf() && newElem.isLeaf():
+				changed := oldElem.leaf != newElem.leaf && !eq(oldElem.leaf.val, newElem.leaf.val)
+				if changed && !fn(newElem.leaf.publicKey(), oldElem.leaf.val, newElem.leaf.val, ChangeModified) {
+					return false
+				}
+			case oldElem.isLeaf():
+				if !fn(oldElem.leaf.publicKey(), oldElem.leaf.val, zero, ChangeDeleted) {
+					return false
+				}
+			case newElem.isLeaf():
+				if !fn(newElem.leaf.publicKey(), zero, newElem.leaf.val, ChangeAdded) {
+					return false
+				}
+			}
+		}
+		oldIter.Next()
+		newIter.Next()
+	}
+	return true
+}
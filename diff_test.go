@@ -0,0 +1,356 @@
+package iradix
+
+import (
+	"reflect"
+	"testing"
+)
+
+type changeRecord struct {
+	key  string
+	val  int
+	kind ChangeKind
+}
+
+func TestWalkChangedFrom(t *testing.T) {
+	base := New[byte, int]()
+	txn := base.Txn()
+	for i, k := range []string{"a", "ab", "abc", "b", "ba", "c", "d/x", "d/y"} {
+		txn.Insert([]byte(k), i)
+	}
+	base = txn.Commit()
+
+	txn = base.Txn()
+	txn.Insert([]byte("aa"), 100) // added
+	txn.Delete([]byte("ab"))      // deleted, "abc" remains
+	txn.Insert([]byte("abc"), 2)  // same value, not a change
+	txn.Insert([]byte("b"), 103)  // modified
+	txn.Delete([]byte("ba"))      // deleted
+	txn.Insert([]byte("bb"), 104) // added
+	txn.Insert([]byte("d/y"), 107)
+	txn.Insert([]byte("e"), 108)
+	cur := txn.Commit()
+
+	eq := func(a, b int) bool { return a == b }
+
+	var out []changeRecord
+	cur.WalkChangedFrom(base, eq, func(k []byte, v int, kind ChangeKind) bool {
+		out = append(out, changeRecord{string(k), v, kind})
+		return true
+	})
+	expected := []changeRecord{
+		{"aa", 100, ChangeAdded},
+		{"ab", 1, ChangeDeleted},
+		{"b", 103, ChangeModified},
+		{"ba", 4, ChangeDeleted},
+		{"bb", 104, ChangeAdded},
+		{"d/y", 107, ChangeModified},
+		{"e", 108, ChangeAdded},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("bad changes:\n got: %v\nwant: %v", out, expected)
+	}
+
+	// Walking the other way around swaps additions and deletions.
+	out = nil
+	base.WalkChangedFrom(cur, eq, func(k []byte, v int, kind ChangeKind) bool {
+		out = append(out, changeRecord{string(k), v, kind})
+		return true
+	})
+	expected = []changeRecord{
+		{"aa", 100, ChangeDeleted},
+		{"ab", 1, ChangeAdded},
+		{"b", 3, ChangeModified},
+		{"ba", 4, ChangeAdded},
+		{"bb", 104, ChangeDeleted},
+		{"d/y", 7, ChangeModified},
+		{"e", 108, ChangeDeleted},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("bad reverse changes:\n got: %v\nwant: %v", out, expected)
+	}
+
+	// Identical trees have no changes.
+	cur.WalkChangedFrom(cur, eq, func(k []byte, _ int, _ ChangeKind) bool {
+		t.Fatalf("unexpected change: %s", k)
+		return true
+	})
+
+	// Early termination.
+	count := 0
+	cur.WalkChangedFrom(base, eq, func([]byte, int, ChangeKind) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Fatalf("expected walk to stop after 3 changes, got %d", count)
+	}
+}
+
+func TestWalkChangedFrom_Empty(t *testing.T) {
+	empty := New[byte, int]()
+	full := empty
+	for i, k := range []string{"", "foo", "foobar"} {
+		full, _, _ = full.Insert([]byte(k), i)
+	}
+	eq := func(a, b int) bool { return a == b }
+
+	var kinds []ChangeKind
+	full.WalkChangedFrom(empty, eq, func(_ []byte, _ int, kind ChangeKind) bool {
+		kinds = append(kinds, kind)
+		return true
+	})
+	if !reflect.DeepEqual(kinds, []ChangeKind{ChangeAdded, ChangeAdded, ChangeAdded}) {
+		t.Fatalf("bad: %v", kinds)
+	}
+
+	kinds = nil
+	empty.WalkChangedFrom(full, eq, func(_ []byte, _ int, kind ChangeKind) bool {
+		kinds = append(kinds, kind)
+		return true
+	})
+	if !reflect.DeepEqual(kinds, []ChangeKind{ChangeDeleted, ChangeDeleted, ChangeDeleted}) {
+		t.Fatalf("bad: %v", kinds)
+	}
+}
+
+func TestDiffPrefix(t *testing.T) {
+	base := New[byte, int]()
+	for i, k := range []string{"ns1/a", "ns1/b", "ns2/a", "ns2/b", "ns2/c", "ns3/a"} {
+		base, _, _ = base.Insert([]byte(k), i)
+	}
+
+	txn := base.Txn()
+	txn.Insert([]byte("ns1/a"), 100) // outside
+	txn.Delete([]byte("ns3/a"))      // outside
+	txn.Insert([]byte("ns22"), 101)  // outside, shares "ns2"
+	txn.Insert([]byte("ns2/a"), 102) // changed
+	txn.Delete([]byte("ns2/b"))      // removed
+	txn.Insert([]byte("ns2/c"), 4)   // same value
+	txn.Insert([]byte("ns2/d"), 103) // added
+	cur := txn.Commit()
+
+	eq := func(a, b int) bool { return a == b }
+	entries := func(kv ...any) []Entry[byte, int] {
+		var out []Entry[byte, int]
+		for i := 0; i < len(kv); i += 2 {
+			out = append(out, Entry[byte, int]{Key: []byte(kv[i].(string)), Value: kv[i+1].(int)})
+		}
+		return out
+	}
+
+	for _, prefix := range []string{"ns2/", "ns2/a", "ns2/b", "ns4"} {
+		added, removed, changed := DiffPrefix(base, cur, []byte(prefix), eq)
+		var wantAdded, wantRemoved, wantChanged []Entry[byte, int]
+		switch prefix {
+		case "ns2/":
+			wantAdded, wantRemoved, wantChanged = entries("ns2/d", 103), entries("ns2/b", 3), entries("ns2/a", 102)
+		case "ns2/a":
+			wantChanged = entries("ns2/a", 102)
+		case "ns2/b":
+			wantRemoved = entries("ns2/b", 3)
+		}
+		if !reflect.DeepEqual(added, wantAdded) || !reflect.DeepEqual(removed, wantRemoved) || !reflect.DeepEqual(changed, wantChanged) {
+			t.Fatalf("prefix %q: got %v %v %v", prefix, added, removed, changed)
+		}
+	}
+
+	// The prefix ends in the middle of an edge in one tree and at a node in
+	// the other.
+	added, removed, changed := DiffPrefix(base, cur, []byte("ns2"), eq)
+	if !reflect.DeepEqual(added, entries("ns2/d", 103, "ns22", 101)) || len(removed) != 1 || len(changed) != 1 {
+		t.Fatalf("got %v %v %v", added, removed, changed)
+	}
+	added, removed, changed = DiffPrefix(cur, base, []byte("ns2"), eq)
+	if !reflect.DeepEqual(removed, entries("ns2/d", 103, "ns22", 101)) || len(added) != 1 || len(changed) != 1 {
+		t.Fatalf("got %v %v %v", added, removed, changed)
+	}
+
+	// Identical trees.
+	if added, removed, changed := DiffPrefix(cur, cur, []byte("ns"), eq); added != nil || removed != nil || changed != nil {
+		t.Fatalf("got %v %v %v", added, removed, changed)
+	}
+}
+
+func TestTreeEqual(t *testing.T) {
+	build := func(keys ...string) *Tree[byte, int] {
+		r := New[byte, int]()
+		for _, k := range keys {
+			r, _, _ = r.Insert([]byte(k), len(k))
+		}
+		return r
+	}
+	eq := func(a, b int) bool { return a == b }
+
+	a := build("foo", "foobar", "zip")
+	cases := []struct {
+		name  string
+		other *Tree[byte, int]
+		equal bool
+	}{
+		{"itself", a, true},
+		{"built in another order", build("zip", "foobar", "foo"), true},
+		{"missing key", build("foo", "foobar"), false},
+		{"extra key", build("foo", "foobar", "zip", "zap"), false},
+		{"same size, different key", build("foo", "foobaz", "zip"), false},
+		{"prefix instead of key", build("fo", "foobar", "zip"), false},
+		{"empty", New[byte, int](), false},
+	}
+	for _, tc := range cases {
+		if a.Equal(tc.other, eq) != tc.equal || tc.other.Equal(a, eq) != tc.equal {
+			t.Fatalf("%s: expected %v", tc.name, tc.equal)
+		}
+	}
+
+	// Values are compared with eq.
+	b, _, _ := a.Insert([]byte("zip"), 100)
+	if a.Equal(b, eq) {
+		t.Fatalf("expected different values")
+	}
+	if !a.Equal(b, func(int, int) bool { return true }) {
+		t.Fatalf("expected equal trees")
+	}
+	if !New[byte, int]().Equal(New[byte, int](), eq) {
+		t.Fatalf("expected empty trees to be equal")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := New[byte, int]()
+	for i, k := range []string{"a", "ab", "abc", "b", "ba", "c"} {
+		base, _, _ = base.Insert([]byte(k), i)
+	}
+
+	txn := base.Txn()
+	txn.Insert([]byte("aa"), 100) // added
+	txn.Delete([]byte("ab"))      // removed, "abc" remains
+	txn.Insert([]byte("abc"), 2)  // same value
+	txn.Insert([]byte("b"), 103)  // changed
+	txn.Delete([]byte("ba"))      // removed
+	txn.Insert([]byte("d"), 105)  // added
+	cur := txn.Commit()
+
+	eq := func(a, b int) bool { return a == b }
+	added, removed, changed := Diff(base, cur, eq)
+	wantAdded := []DiffEntry[byte, int]{{Key: []byte("aa"), New: 100}, {Key: []byte("d"), New: 105}}
+	wantRemoved := []DiffEntry[byte, int]{{Key: []byte("ab"), Old: 1}, {Key: []byte("ba"), Old: 4}}
+	wantChanged := []DiffEntry[byte, int]{{Key: []byte("b"), Old: 3, New: 103}}
+	if !reflect.DeepEqual(added, wantAdded) || !reflect.DeepEqual(removed, wantRemoved) || !reflect.DeepEqual(changed, wantChanged) {
+		t.Fatalf("got %v %v %v", added, removed, changed)
+	}
+
+	// Diffing the other way swaps added and removed, and old and new values.
+	added, removed, changed = Diff(cur, base, eq)
+	if len(added) != 2 || len(removed) != 2 || len(changed) != 1 || changed[0].Old != 103 || changed[0].New != 3 {
+		t.Fatalf("got %v %v %v", added, removed, changed)
+	}
+
+	if added, removed, changed := Diff(cur, cur, eq); added != nil || removed != nil || changed != nil {
+		t.Fatalf("got %v %v %v", added, removed, changed)
+	}
+	added, _, _ = Diff(New[byte, int](), cur, eq)
+	if len(added) != cur.Len() {
+		t.Fatalf("bad: %v", added)
+	}
+}
+
+func TestChangedPrefixes(t *testing.T) {
+	base := New[byte, int]()
+	for i, k := range []string{"a/1", "a/2", "b/1", "b/2", "c/x/1", "c/x/2", "d"} {
+		base, _, _ = base.Insert([]byte(k), i)
+	}
+
+	txn := base.Txn()
+	txn.Insert([]byte("ab"), 10)    // splits the node of "a/"
+	txn.Insert([]byte("b/2"), 11)   // changed
+	txn.Insert([]byte("c/x/2"), 5)  // same value, not a change
+	txn.Insert([]byte("c/x/3"), 12) // added
+	txn.Delete([]byte("d"))         // deleted
+	cur := txn.Commit()
+
+	eqCalls := 0
+	eq := func(a, b int) bool {
+		eqCalls++
+		return a == b
+	}
+	var out []string
+	for _, p := range ChangedPrefixes(base, cur, eq) {
+		out = append(out, string(p))
+	}
+	if expected := []string{"a", "b/2", "c/x/3", "d"}; !reflect.DeepEqual(out, expected) {
+		t.Fatalf("bad prefixes: %q", out)
+	}
+	// Only the rewritten values were compared, the rest of the trees is
+	// shared and skipped.
+	if eqCalls != 2 {
+		t.Fatalf("bad eq calls: %d", eqCalls)
+	}
+
+	if p := ChangedPrefixes(cur, cur, eq); len(p) != 0 {
+		t.Fatalf("unexpected prefixes: %q", p)
+	}
+	// Everything is new in a tree built from scratch.
+	empty := New[byte, int]()
+	if p := ChangedPrefixes(empty, cur, eq); len(p) != 3 {
+		t.Fatalf("bad prefixes: %q", p)
+	}
+	// A change of the empty key covers everything.
+	withEmpty, _, _ := cur.Insert(nil, 0)
+	if p := ChangedPrefixes(cur, withEmpty, eq); len(p) != 1 || len(p[0]) != 0 {
+		t.Fatalf("bad prefixes: %q", p)
+	}
+}
+
+func TestChangedPrefixes_Random(t *testing.T) {
+	seedRand()
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("seed: %d", seed)
+		}
+	})
+
+	eq := func(a, b int) bool { return a == b }
+	for round := 0; round < 50; round++ {
+		txn := New[byte, int]().Txn()
+		for i := 0; i < 200; i++ {
+			txn.Insert([]byte(randomHexString(rng.Intn(4))), rng.Intn(3))
+		}
+		base := txn.Commit()
+		txn = base.Txn()
+		for i := 0; i < rng.Intn(10); i++ {
+			k := []byte(randomHexString(rng.Intn(4)))
+			if rng.Intn(2) == 0 {
+				txn.Delete(k)
+			} else {
+				txn.Insert(k, rng.Intn(3))
+			}
+		}
+		cur := txn.Commit()
+
+		prefixes := ChangedPrefixes(base, cur, eq)
+		for i := 1; i < len(prefixes); i++ {
+			if keyCompare(prefixes[i-1], prefixes[i]) >= 0 || keyHasPrefix(prefixes[i], prefixes[i-1]) {
+				t.Fatalf("prefixes out of order or nested: %q", prefixes)
+			}
+		}
+
+		// Every changed key is covered, and every prefix covers a change.
+		covers := make([]bool, len(prefixes))
+		cur.WalkChangedFrom(base, eq, func(k []byte, _ int, _ ChangeKind) bool {
+			covered := false
+			for i, p := range prefixes {
+				if keyHasPrefix(k, p) {
+					covered, covers[i] = true, true
+				}
+			}
+			if !covered {
+				t.Fatalf("changed key %q isn't covered by %q", k, prefixes)
+			}
+			return true
+		})
+		for i, ok := range covers {
+			if !ok {
+				t.Fatalf("prefix %q covers no change", prefixes[i])
+			}
+		}
+	}
+}
@@ -3,6 +3,44 @@
 
 package iradix
 
+import (
+	"unsafe"
+)
+
+// denseEdgeThreshold is the number of edges from which nodes of trees created
+// with WithDenseEdgeIndex get a dense index.
+const denseEdgeThreshold = 32
+
+// denseIndex maps every possible label of a single byte to the position of
+// its edge plus one, or zero if there's no such edge. Indexes are never
+// modified once built, so nodes can share them, and a node whose edges change
+// gets a new one.
+type denseIndex [256]uint16
+
+// denseLabel returns the byte of a label if labels are a single byte wide,
+// which is the only case dense indexes are built for.
+func denseLabel[K keyT](label K) (byte, bool) {
+	if unsafe.Sizeof(label) != 1 {
+		return 0, false
+	}
+	return *(*byte)(unsafe.Pointer(&label)), true
+}
+
+// newDenseIndex returns a dense index of the given edges, or nil if there are
+// too few of them to be worth it, or labels aren't single bytes.
+func newDenseIndex[K keyT, T any](edges []edge[K, T]) *denseIndex {
+	var zero K
+	if _, ok := denseLabel(zero); !ok || len(edges) < denseEdgeThreshold {
+		return nil
+	}
+	index := new(denseIndex)
+	for i, e := range edges {
+		b, _ := denseLabel(e.label)
+		index[b] = uint16(i + 1)
+	}
+	return index
+}
+
 type edges[K keyT, T any] []edge[K, T]
 
 func (e edges[K, T]) Len() int {
@@ -16,3 +54,12 @@ func (e edges[K, T]) Less(i, j int) bool {
 func (e edges[K, T]) Swap(i, j int) {
 	e[i], e[j] = e[j], e[i]
 }
+
+// indexEdges gives n a dense index if the tree was created with
+// WithDenseEdgeIndex and n has become wide enough. Nodes already indexed keep
+// their index up to date as edges are added and removed.
+func (t *Txn[K, T]) indexEdges(n *Node[K, T]) {
+	if t.denseEdges && n.dense == nil {
+		n.dense = newDenseIndex(n.edges)
+	}
+}
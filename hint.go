@@ -0,0 +1,180 @@
+package iradix
+
+import (
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	// hintMinEdges is the minimum number of edges for a node to be tracked.
+	// Searching narrower nodes is already cheap.
+	hintMinEdges = 8
+	// hintHotEdges is the number of hottest edges cached per node.
+	hintHotEdges = 4
+	// hintSampleRate is the number of lookups for each one whose edges are
+	// recorded.
+	hintSampleRate = 16
+	// hintRingSize is the number of recorded edges kept, which are the most
+	// recent ones. The hot edge cache is rebuilt automatically whenever as
+	// many new edges have been recorded.
+	hintRingSize = 1 << 12
+)
+
+// frequencyHint samples edge accesses of wide nodes and maintains a cache of
+// their hottest edges. It is shared by all versions of a tree created with
+// WithFrequencyHint, and safe for concurrent use without locking. Nodes are
+// only identified by their addresses, so neither the recorded edges nor the
+// cache keep nodes of old tree versions alive, and cached edges are checked
+// against the node before they're followed.
+type frequencyHint[K keyT, T any] struct {
+	// ring holds the most recently recorded edges, and pos counts all edges
+	// recorded so far.
+	ring [hintRingSize]atomic.Pointer[hintVisit[K]]
+	pos  atomic.Uint64
+
+	// rebalancing is set while the cache is being rebuilt, so that only one
+	// goroutine does it at a time.
+	rebalancing atomic.Bool
+
+	// hot maps the addresses of wide nodes to their hottest edges. It is
+	// replaced as a whole on rebalance, so readers never need a lock.
+	hot atomic.Pointer[map[uintptr][]hotEdge[K]]
+}
+
+// hotEdge points at an edge of a node by its label and position.
+type hotEdge[K keyT] struct {
+	label K
+	idx   int
+}
+
+// hintVisit is an edge followed by a lookup, at position idx of the node at
+// address node.
+type hintVisit[K keyT] struct {
+	node  uintptr
+	label K
+	idx   int
+}
+
+// nodeAddr returns the address identifying n in the hint.
+func nodeAddr[K keyT, T any](n *Node[K, T]) uintptr {
+	return uintptr(unsafe.Pointer(n))
+}
+
+// get looks up k under n like Node.Get, checking cached hot edges first and
+// recording the edges followed through wide nodes for a sample of lookups.
+func (h *frequencyHint[K, T]) get(n *Node[K, T], k []K) (T, bool) {
+	var hot map[uintptr][]hotEdge[K]
+	if p := h.hot.Load(); p != nil {
+		hot = *p
+	}
+	// The global source doesn't lock, so unlike a shared counter it doesn't
+	// make readers contend.
+	sample := rand.Uint32()%hintSampleRate == 0
+
+	// Most lookups visit only a handful of wide nodes, so this doesn't
+	// allocate.
+	var buf [8]hintVisit[K]
+	visits := buf[:0]
+
+	var zero T
+	search := k
+	for {
+		// Check for key exhaustion
+		if len(search) == 0 {
+			break
+		}
+
+		// Look for an edge, trying the hot ones first. The node at the
+		// address may have been collected and the address reused, so the
+		// edge must still be there.
+		label := search[0]
+		parent := n
+		n = nil
+		idx := -1
+		if len(parent.edges) >= hintMinEdges {
+			for _, e := range hot[nodeAddr(parent)] {
+				if e.label == label && e.idx < len(parent.edges) && parent.edges[e.idx].label == label {
+					idx, n = e.idx, parent.edges[e.idx].node
+					break
+				}
+			}
+		}
+		if n == nil {
+			idx, n = parent.getEdge(label)
+		}
+		if sample && n != nil && len(parent.edges) >= hintMinEdges {
+			visits = append(visits, hintVisit[K]{nodeAddr(parent), label, idx})
+		}
+		if n == nil || !keyHasPrefix(search, n.prefix) {
+			n = nil
+			break
+		}
+
+		// Consume the search prefix
+		search = search[len(n.prefix):]
+	}
+	if len(visits) > 0 {
+		h.record(visits)
+	}
+
+	if n != nil && n.isLeaf() {
+		return n.leaf.val, true
+	}
+	return zero, false
+}
+
+// record adds the visited edges to the ring, rebalancing whenever it has been
+// filled again.
+func (h *frequencyHint[K, T]) record(visits []hintVisit[K]) {
+	for _, v := range visits {
+		v := v
+		pos := h.pos.Add(1)
+		h.ring[pos%hintRingSize].Store(&v)
+		if pos%hintRingSize == 0 {
+			h.rebalance()
+		}
+	}
+}
+
+// rebalance rebuilds the hot edge cache from the edges in the ring, so that
+// the cache follows changes in the workload and nodes of old tree versions
+// drop out of it. It's skipped if another goroutine is already at it.
+func (h *frequencyHint[K, T]) rebalance() {
+	if !h.rebalancing.CompareAndSwap(false, true) {
+		return
+	}
+	defer h.rebalancing.Store(false)
+
+	counts := make(map[hintVisit[K]]int)
+	for i := range h.ring {
+		if v := h.ring[i].Load(); v != nil {
+			counts[*v]++
+		}
+	}
+	byNode := make(map[uintptr][]hotEdge[K])
+	for v := range counts {
+		byNode[v.node] = append(byNode[v.node], hotEdge[K]{label: v.label, idx: v.idx})
+	}
+	for node, edges := range byNode {
+		sort.Slice(edges, func(i, j int) bool {
+			return counts[hintVisit[K]{node, edges[i].label, edges[i].idx}] > counts[hintVisit[K]{node, edges[j].label, edges[j].idx}]
+		})
+		if len(edges) > hintHotEdges {
+			byNode[node] = edges[:hintHotEdges]
+		}
+	}
+	h.hot.Store(&byNode)
+}
+
+// Rebalance rebuilds the hot edge cache of a tree created with
+// WithFrequencyHint from the most recently sampled lookups. The cache is
+// shared by all versions of the tree. It's a no-op for trees without the
+// hint.
+func (t *Tree[K, T]) Rebalance() {
+	if t.hint == nil {
+		return
+	}
+	t.hint.rebalance()
+}
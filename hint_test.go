@@ -0,0 +1,65 @@
+package iradix
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFrequencyHint(t *testing.T) {
+	r := New[byte, int](WithFrequencyHint())
+	txn := r.Txn()
+	for i := 0; i < 64; i++ {
+		txn.Insert([]byte(fmt.Sprintf("%c/key", 'A'+i)), i)
+	}
+	r = txn.Commit()
+
+	check := func(r *Tree[byte, int]) {
+		t.Helper()
+		for i := 0; i < 64; i++ {
+			v, ok := r.Get([]byte(fmt.Sprintf("%c/key", 'A'+i)))
+			if !ok || v != i {
+				t.Fatalf("bad: %d %v %v", i, v, ok)
+			}
+		}
+		for _, k := range []string{"", "A", "A/", "A/keyz", "~"} {
+			if _, ok := r.Get([]byte(k)); ok {
+				t.Fatalf("unexpected key %q", k)
+			}
+		}
+	}
+	check(r)
+
+	// Make a few edges of the root hot. Only a sample of lookups is recorded,
+	// so it takes a few more of them.
+	for i := 0; i < 2000; i++ {
+		r.Get([]byte("Z/key"))
+		r.Get([]byte("a/key"))
+	}
+	r.Rebalance()
+
+	hot := *r.hint.hot.Load()
+	edges := hot[nodeAddr(r.root)]
+	if len(edges) < 2 || len(edges) > hintHotEdges {
+		t.Fatalf("expected up to %d hot edges, got %v", hintHotEdges, edges)
+	}
+	if edges[0].label != 'Z' && edges[0].label != 'a' || edges[1].label != 'Z' && edges[1].label != 'a' {
+		t.Fatalf("bad hot edges: %v", edges)
+	}
+	check(r)
+
+	// The hint is shared with new versions of the tree, which keep working
+	// while the cache still refers to the old root.
+	r2, _, _ := r.Insert([]byte("Z/key"), 1000)
+	if r2.hint != r.hint {
+		t.Fatalf("hint is not shared")
+	}
+	if v, _ := r2.Get([]byte("Z/key")); v != 1000 {
+		t.Fatalf("bad: %v", v)
+	}
+	if v, _ := r.Get([]byte("Z/key")); v != 'Z'-'A' {
+		t.Fatalf("bad: %v", v)
+	}
+
+	// Trees without the hint ignore Rebalance.
+	New[byte, int]().Rebalance()
+}
@@ -4,7 +4,10 @@
 package iradix
 
 import (
+	"maps"
+	"reflect"
 	"slices"
+	"time"
 )
 
 // Tree implements an immutable radix tree. This can be treated as a
@@ -12,10 +15,34 @@ import (
 // hash map is prefix-based lookups and ordered iteration. The immutability
 // means that it is safe to concurrently read from a Tree without any
 // coordination.
+//
+// The empty key is a valid key, unless the tree is created with
+// WithRejectEmptyKey. Its value is stored at the root. Since it's a prefix of
+// every key, LongestPrefix returns it when no longer key matches, and
+// WalkPrefix and DeletePrefix with the empty prefix cover it along with all
+// other keys, so DeletePrefix(nil) empties the tree. Prefix operations with a
+// non-empty prefix never touch it.
+//
+// Keys are shared, not copied: the tree stores the slices passed to Insert,
+// and hands them out as is from every API returning keys. They must not be
+// modified by the caller afterwards, unless the tree is created with
+// WithCloneKeys.
 type Tree[K keyT, T any] struct {
 	options
 	root *Node[K, T]
 	size int
+
+	// hint holds edge access statistics shared by all versions of the tree
+	// if it was created with WithFrequencyHint.
+	hint *frequencyHint[K, T]
+
+	// bloom is the filter of keys shared by all versions of the tree if it
+	// was created with WithBloomPrefilter.
+	bloom *bloomFilter[K]
+
+	// sealed is set on handles returned by Seal, which refuse to start
+	// transactions.
+	sealed bool
 }
 
 // New returns an empty Tree
@@ -24,14 +51,50 @@ func New[K keyT, T any](opts ...Option) *Tree[K, T] {
 	for _, opt := range opts {
 		opt(&o)
 	}
+	return newTree[K, T](o)
+}
 
+// newTree returns an empty Tree with the given options.
+func newTree[K keyT, T any](o options) *Tree[K, T] {
 	t := &Tree[K, T]{
-
 		options: o,
 		root: &Node[K, T]{
-			mutateCh: make(chan struct{}),
+			mutateCh: o.newWatch(),
 		},
 	}
+	if o.comparator != nil {
+		cmp, ok := o.comparator.(func(a, b K) int)
+		if !ok {
+			panic("iradix: comparator type doesn't match the tree's")
+		}
+		t.root.ext = &nodeExt[K]{cmp: cmp}
+	}
+	if o.frequencyHint {
+		t.hint = &frequencyHint[K, T]{}
+	}
+	if o.bloomN > 0 {
+		t.bloom = newBloomFilter[K](o.bloomN, o.bloomFP)
+	}
+	if o.keyRange != nil {
+		if _, ok := o.keyRange.(*keyRange[K]); !ok {
+			panic("iradix: key element range type doesn't match the tree's")
+		}
+	}
+	if o.valueCodec != nil {
+		if _, ok := o.valueCodec.(*valueCodec[T]); !ok {
+			panic("iradix: value codec type doesn't match the tree's")
+		}
+	}
+	if o.aggregator != nil {
+		a, ok := o.aggregator.(aggregator[T])
+		if !ok {
+			panic("iradix: aggregator value type doesn't match the tree's")
+		}
+		t.root.ext = &nodeExt[K]{
+			cmp: t.root.comparator(),
+			agg: computeAggregate(a, t.root),
+		}
+	}
 	return t
 }
 
@@ -63,6 +126,9 @@ type Txn[K keyT, T any] struct {
 	// up to defaultChannelLimit number of entries.
 	writable Cache
 
+	// nodesCopied counts the nodes copied by writeNode.
+	nodesCopied int
+
 	// trackChannels is used to hold channels that need to be notified to
 	// signal mutation of the tree. This will only hold up to
 	// defaultChannelLimit number of entries, after which we will set the
@@ -72,19 +138,62 @@ type Txn[K keyT, T any] struct {
 	trackChannels map[chan struct{}]struct{}
 	trackOverflow bool
 	trackMutate   bool
+
+	// trackLeavesOnly limits tracking to the channels of leaves, see
+	// TrackMutateLeavesOnly.
+	trackLeavesOnly bool
+
+	// hint and bloom are passed on to the committed tree.
+	hint  *frequencyHint[K, T]
+	bloom *bloomFilter[K]
+
+	// preCommit validates the root before the transaction is committed.
+	preCommit func(root *Node[K, T]) error
+
+	// aborted is set by Abort, after which writes and commits panic.
+	aborted bool
 }
 
 // Txn starts a new transaction that can be used to mutate the tree
 func (t *Tree[K, T]) Txn() *Txn[K, T] {
+	if t.sealed {
+		panic("iradix: Txn called on a sealed tree, fork it with Clone first")
+	}
 	txn := &Txn[K, T]{
 		options: t.options,
 		root:    t.root,
 		snap:    t.root,
 		size:    t.size,
+		hint:    t.hint,
+		bloom:   t.bloom,
 	}
 	return txn
 }
 
+// Seal returns a read-only handle to the tree, meant for trees handed to many
+// readers. Trees are immutable and safe to read concurrently either way, but
+// the sealed handle makes the intent explicit: Txn, and with it every
+// operation that derives a new tree, such as Insert and Delete, panics on it.
+// Readers that want to derive their own versions should fork it with Clone.
+func (t *Tree[K, T]) Seal() *Tree[K, T] {
+	sealed := *t
+	sealed.sealed = true
+	return &sealed
+}
+
+// Sealed reports whether the tree is a read-only handle returned by Seal.
+func (t *Tree[K, T]) Sealed() bool {
+	return t.sealed
+}
+
+// Clone returns a handle to the same tree that isn't sealed. No nodes are
+// copied, since they are never modified in place.
+func (t *Tree[K, T]) Clone() *Tree[K, T] {
+	clone := *t
+	clone.sealed = false
+	return &clone
+}
+
 // Clone makes an independent copy of the transaction. The new transaction
 // does not track any nodes and has TrackMutate turned off. The cloned transaction will contain any uncommitted writes in the original transaction but further mutations to either will be independent and result in different radix trees on Commit. A cloned transaction may be passed to another goroutine and mutated there independently however each transaction may only be mutated in a single thread.
 func (t *Txn[K, T]) Clone() *Txn[K, T] {
@@ -95,14 +204,112 @@ func (t *Txn[K, T]) Clone() *Txn[K, T] {
 	}
 
 	txn := &Txn[K, T]{
-		options: t.options,
-		root:    t.root,
-		snap:    t.snap,
-		size:    t.size,
+		options:   t.options,
+		root:      t.root,
+		snap:      t.snap,
+		size:      t.size,
+		hint:      t.hint,
+		bloom:     t.bloom,
+		preCommit: t.preCommit,
 	}
 	return txn
 }
 
+// NodesCopied returns the number of existing nodes the transaction has copied
+// so far. Nodes that are already writable are modified in place, so writing
+// the same part of the tree again doesn't add to this.
+func (t *Txn[K, T]) NodesCopied() int {
+	return t.nodesCopied
+}
+
+// Dirty returns whether any write modified the tree since the transaction
+// started, in which case the root differs from the one it started with.
+// Conditional writes that don't write anything, such as Update of a missing
+// key, and deletes of missing keys leave the transaction clean, so if it's
+// not dirty the original tree can be used instead of committing. Writes that
+// store a value equal to the old one still count, since values aren't
+// compared.
+func (t *Txn[K, T]) Dirty() bool {
+	return t.root != t.snap
+}
+
+// Abort discards the transaction, releasing the writable node cache and the
+// tracked watch channels right away instead of when the transaction is
+// garbage collected, which matters for long-lived services that start
+// transactions speculatively. Reads afterwards see the tree the transaction
+// started from, writes and commits panic, and Notify has nothing to notify,
+// since no tree was committed. Aborting twice is a no-op.
+func (t *Txn[K, T]) Abort() {
+	if t.writable != nil {
+		t.writable.Clear()
+		t.writable = nil
+	}
+	t.trackChannels = nil
+	t.trackOverflow = false
+	t.root = t.snap
+	t.size = t.snap.size
+	t.aborted = true
+}
+
+// checkWritable panics if the transaction was aborted.
+func (t *Txn[K, T]) checkWritable() {
+	if t.aborted {
+		panic("iradix: transaction was aborted")
+	}
+}
+
+// Savepoint is the state of a transaction captured by Txn.Savepoint, which
+// Txn.Rollback restores.
+type Savepoint[K keyT, T any] struct {
+	txn           *Txn[K, T]
+	root          *Node[K, T]
+	size          int
+	trackChannels map[chan struct{}]struct{}
+	trackOverflow bool
+}
+
+// Savepoint captures the current state of the transaction, so writes made
+// after it can be undone with Rollback, e.g. to try a speculative batch of
+// writes. Nodes the transaction already wrote are modified in place by later
+// writes, so this resets the writable node cache, and the first write after
+// it copies them again. If TrackMutate is enabled, the tracked channels are
+// copied too, which takes time proportional to their number.
+func (t *Txn[K, T]) Savepoint() Savepoint[K, T] {
+	t.checkWritable()
+	if t.writable != nil {
+		t.writable.Clear()
+		t.writable = nil
+	}
+	return Savepoint[K, T]{
+		txn:           t,
+		root:          t.root,
+		size:          t.size,
+		trackChannels: maps.Clone(t.trackChannels),
+		trackOverflow: t.trackOverflow,
+	}
+}
+
+// Rollback restores the state captured by Savepoint, undoing all writes made
+// since. Channels tracked since are dropped, since their nodes are either
+// discarded or back in the tree, so only the writes up to the savepoint are
+// notified on commit. Every savepoint of the transaction stays valid, even
+// ones taken after sp, and can be rolled back to any number of times. It
+// panics if the savepoint was taken on another transaction.
+func (t *Txn[K, T]) Rollback(sp Savepoint[K, T]) {
+	t.checkWritable()
+	if sp.txn != t {
+		panic("iradix: rollback to a savepoint of another transaction")
+	}
+	if t.writable != nil {
+		t.writable.Clear()
+		t.writable = nil
+	}
+	t.root = sp.root
+	t.size = sp.size
+	t.trackChannels = maps.Clone(sp.trackChannels)
+	t.trackOverflow = sp.trackOverflow
+}
+
 // TrackMutate can be used to toggle if mutations are tracked. If this is enabled
 // then notifications will be issued for affected internal nodes and leaves when
 // the transaction is committed.
@@ -110,13 +317,34 @@ func (t *Txn[K, T]) TrackMutate(track bool) {
 	t.trackMutate = track
 }
 
+// TrackMutateLeavesOnly can be used to toggle if mutation tracking is limited
+// to leaves. If this is enabled along with TrackMutate, notifications are only
+// issued for leaves that were updated or deleted, which are the channels
+// GetWatch returns for existing keys, and not for internal nodes that were
+// restructured. This saves wakeups of watchers only interested in values,
+// but channels of internal nodes, such as those returned by SeekPrefixWatch,
+// WatchPrefix, or GetWatch for missing keys, are never closed by the
+// transaction, even if keys below them change.
+func (t *Txn[K, T]) TrackMutateLeavesOnly(leavesOnly bool) {
+	t.trackLeavesOnly = leavesOnly
+}
+
+// trackNode tracks the mutation channel of the internal node n, unless
+// tracking is disabled or limited to leaves.
+func (t *Txn[K, T]) trackNode(n *Node[K, T]) {
+	if t.trackMutate && !t.trackLeavesOnly {
+		t.trackChannel(n.watch())
+	}
+}
+
 // trackChannel safely attempts to track the given mutation channel, setting the
 // overflow flag if we can no longer track any more. This limits the amount of
 // state that will accumulate during a transaction and we have a slower algorithm
 // to switch to if we overflow.
 func (t *Txn[K, T]) trackChannel(ch chan struct{}) {
-	// In overflow, make sure we don't store any more objects.
-	if t.trackOverflow {
+	// In overflow, make sure we don't store any more objects. The shared
+	// closed channel never needs closing.
+	if t.trackOverflow || ch == closedCh {
 		return
 	}
 
@@ -159,27 +387,30 @@ func (t *Txn[K, T]) writeNode(n *Node[K, T], forLeafUpdate bool) *Node[K, T] {
 	// update the leaf.
 	if t.writable.Has(n) {
 		if t.trackMutate && forLeafUpdate && n.leaf != nil {
-			t.trackChannel(n.leaf.mutateCh)
+			t.trackChannel(n.leaf.watch())
 		}
 		return n
 	}
 
-	if t.trackMutate {
-		t.trackChannel(n.mutateCh)
-		if forLeafUpdate && n.leaf != nil {
-			t.trackChannel(n.leaf.mutateCh)
-		}
+	t.trackNode(n)
+	if t.trackMutate && forLeafUpdate && n.leaf != nil {
+		t.trackChannel(n.leaf.watch())
 	}
 
+	t.nodesCopied++
+
 	// Copy the existing node. If you have set forLeafUpdate it will be
 	// safe to replace this leaf with another after you get your node for
 	// writing. You MUST replace it, because the channel associated with
 	// this leaf will be closed when this transaction is committed.
 	nc := &Node[K, T]{
-		mutateCh: make(chan struct{}),
+		mutateCh: t.newWatch(),
 		leaf:     n.leaf,
 		prefix:   slices.Clone(n.prefix),
 		edges:    slices.Clone(n.edges),
+		size:     n.size,
+		ext:      n.ext,
+		dense:    n.dense,
 	}
 
 	// Mark this node as writable.
@@ -196,13 +427,11 @@ func (t *Txn[K, T]) trackChannelsAndCount(n *Node[K, T]) int {
 		leaves = 1
 	}
 	// Mark this node as being mutated.
-	if t.trackMutate {
-		t.trackChannel(n.mutateCh)
-	}
+	t.trackNode(n)
 
 	// Mark its leaf as being mutated, if appropriate.
 	if t.trackMutate && n.leaf != nil {
-		t.trackChannel(n.leaf.mutateCh)
+		t.trackChannel(n.leaf.watch())
 	}
 
 	// Recurse on the children
@@ -220,18 +449,22 @@ func (t *Txn[K, T]) mergeChild(n *Node[K, T]) {
 	// is there.
 	e := n.edges[0]
 	child := e.node
-	if t.trackMutate {
-		t.trackChannel(child.mutateCh)
-	}
+	t.trackNode(child)
 
 	// Merge the nodes.
 	n.prefix = append(n.prefix, child.prefix...)
 	n.leaf = child.leaf
 	n.edges = slices.Clone(child.edges)
+	n.size = child.size
+	n.ext = child.ext
+	n.dense = child.dense
 }
 
-// insert does a recursive insertion
-func (t *Txn[K, T]) insert(n *Node[K, T], k, search []K, v T) (*Node[K, T], T, bool) {
+// insert does a recursive insertion. If update is not nil, the value stored is
+// what it returns for the old value and whether the key exists, instead of v,
+// and nothing is written if it returns false. If ancestor is not nil, it's set
+// to the leaf of the longest proper prefix of k before update is called.
+func (t *Txn[K, T]) insert(n *Node[K, T], k, search []K, v T, update func(old T, exists bool) (T, bool), ancestor **leafNode[K, T]) (*Node[K, T], T, bool) {
 	var zero T
 
 	// Handle key exhaustion
@@ -242,46 +475,84 @@ func (t *Txn[K, T]) insert(n *Node[K, T], k, search []K, v T) (*Node[K, T], T, b
 			oldVal = n.leaf.val
 			didUpdate = true
 		}
+		if update != nil {
+			var write bool
+			if v, write = update(oldVal, didUpdate); !write {
+				return nil, oldVal, didUpdate
+			}
+		}
 
 		nc := t.writeNode(n, true)
 		nc.leaf = &leafNode[K, T]{
-			mutateCh: make(chan struct{}),
+			mutateCh: t.newWatch(),
 			key:      k,
 			val:      v,
+			ext:      t.newLeafExt(),
 		}
+		if !didUpdate {
+			nc.size++
+		}
+		t.aggregate(nc)
 		return nc, oldVal, didUpdate
 	}
 
+	// Any leaf above the exhausted key is a proper prefix
+	if ancestor != nil && n.isLeaf() {
+		*ancestor = n.leaf
+	}
+
 	// Look for the edge
 	idx, child := n.getEdge(search[0])
 
+	// The key doesn't exist if there is no edge, or the edge has to be split
+	commonPrefix := 0
+	if child != nil {
+		commonPrefix = longestPrefix(search, child.prefix)
+	}
+	if (child == nil || commonPrefix < len(child.prefix)) && update != nil {
+		var write bool
+		if v, write = update(zero, false); !write {
+			return nil, zero, false
+		}
+	}
+
 	// No edge, create one
 	if child == nil {
 		e := edge[K, T]{
 			label: search[0],
 			node: &Node[K, T]{
-				mutateCh: make(chan struct{}),
+				mutateCh: t.newWatch(),
+				ext:      n.childExt(),
 				leaf: &leafNode[K, T]{
-					mutateCh: make(chan struct{}),
+					mutateCh: t.newWatch(),
 					key:      k,
 					val:      v,
+					ext:      t.newLeafExt(),
 				},
 				prefix: search,
 			},
 		}
 		nc := t.writeNode(n, false)
 		nc.addEdge(e)
+		t.indexEdges(nc)
+		e.node.size = 1
+		nc.size++
+		t.aggregate(e.node)
+		t.aggregate(nc)
 		return nc, zero, false
 	}
 
-	// Determine longest prefix of the search key on match
-	commonPrefix := longestPrefix(search, child.prefix)
+	// Recurse if the search key matches the whole edge
 	if commonPrefix == len(child.prefix) {
 		search = search[commonPrefix:]
-		newChild, oldVal, didUpdate := t.insert(child, k, search, v)
+		newChild, oldVal, didUpdate := t.insert(child, k, search, v, update, ancestor)
 		if newChild != nil {
 			nc := t.writeNode(n, false)
 			nc.edges[idx].node = newChild
+			if !didUpdate {
+				nc.size++
+			}
+			t.aggregate(nc)
 			return nc, oldVal, didUpdate
 		}
 		return nil, oldVal, didUpdate
@@ -290,7 +561,8 @@ func (t *Txn[K, T]) insert(n *Node[K, T], k, search []K, v T) (*Node[K, T], T, b
 	// Split the node
 	nc := t.writeNode(n, false)
 	splitNode := &Node[K, T]{
-		mutateCh: make(chan struct{}),
+		mutateCh: t.newWatch(),
+		ext:      n.childExt(),
 		prefix:   search[:commonPrefix],
 	}
 	nc.replaceEdge(edge[K, T]{
@@ -305,30 +577,41 @@ func (t *Txn[K, T]) insert(n *Node[K, T], k, search []K, v T) (*Node[K, T], T, b
 		node:  modChild,
 	})
 	modChild.prefix = modChild.prefix[commonPrefix:]
+	nc.size++
+	splitNode.size = modChild.size + 1
 
 	// Create a new leaf node
 	leaf := &leafNode[K, T]{
-		mutateCh: make(chan struct{}),
+		mutateCh: t.newWatch(),
 		key:      k,
 		val:      v,
+		ext:      t.newLeafExt(),
 	}
 
 	// If the new key is a subset, add to to this node
 	search = search[commonPrefix:]
 	if len(search) == 0 {
 		splitNode.leaf = leaf
+		t.aggregate(splitNode)
+		t.aggregate(nc)
 		return nc, zero, false
 	}
 
 	// Create a new edge for the node
+	newNode := &Node[K, T]{
+		mutateCh: t.newWatch(),
+		ext:      n.childExt(),
+		leaf:     leaf,
+		prefix:   search,
+	}
+	newNode.size = 1
 	splitNode.addEdge(edge[K, T]{
 		label: search[0],
-		node: &Node[K, T]{
-			mutateCh: make(chan struct{}),
-			leaf:     leaf,
-			prefix:   search,
-		},
+		node:  newNode,
 	})
+	t.aggregate(newNode)
+	t.aggregate(splitNode)
+	t.aggregate(nc)
 	return nc, zero, false
 }
 
@@ -348,10 +631,13 @@ func (t *Txn[K, T]) delete(n *Node[K, T], search []K) (*Node[K, T], *leafNode[K,
 		// Remove the leaf node
 		nc := t.writeNode(n, true)
 		nc.leaf = nil
+		nc.size--
 
 		// Check if this node should be merged
 		if n != t.root && len(nc.edges) == 1 {
 			t.mergeChild(nc)
+		} else {
+			t.aggregate(nc)
 		}
 		return nc, oldLeaf
 	}
@@ -375,6 +661,7 @@ func (t *Txn[K, T]) delete(n *Node[K, T], search []K) (*Node[K, T], *leafNode[K,
 	// the !nc.isLeaf() check in the logic just below. This is pretty subtle,
 	// so be careful if you change any of the logic here.
 	nc := t.writeNode(n, false)
+	nc.size--
 
 	// Delete the edge if the node has no edges
 	if newChild.leaf == nil && len(newChild.edges) == 0 {
@@ -385,6 +672,7 @@ func (t *Txn[K, T]) delete(n *Node[K, T], search []K) (*Node[K, T], *leafNode[K,
 	} else {
 		nc.edges[idx].node = newChild
 	}
+	t.aggregate(nc)
 	return nc, leaf
 }
 
@@ -392,12 +680,18 @@ func (t *Txn[K, T]) delete(n *Node[K, T], search []K) (*Node[K, T], *leafNode[K,
 func (t *Txn[K, T]) deletePrefix(n *Node[K, T], search []K) (*Node[K, T], int) {
 	// Check for key exhaustion
 	if len(search) == 0 {
+		// Count before clearing the node, as it may be modified in place if
+		// it's already writable.
+		numDeletions := t.trackChannelsAndCount(n)
 		nc := t.writeNode(n, true)
 		if n.isLeaf() {
 			nc.leaf = nil
 		}
 		nc.edges = nil
-		return nc, t.trackChannelsAndCount(n)
+		nc.dense = nil
+		nc.size = 0
+		t.aggregate(nc)
+		return nc, numDeletions
 	}
 
 	// Look for an edge
@@ -425,6 +719,7 @@ func (t *Txn[K, T]) deletePrefix(n *Node[K, T], search []K) (*Node[K, T], int) {
 	// so be careful if you change any of the logic here.
 
 	nc := t.writeNode(n, false)
+	nc.size -= numDeletions
 
 	// Delete the edge if the node has no edges
 	if newChild.leaf == nil && len(newChild.edges) == 0 {
@@ -435,25 +730,151 @@ func (t *Txn[K, T]) deletePrefix(n *Node[K, T], search []K) (*Node[K, T], int) {
 	} else {
 		nc.edges[idx].node = newChild
 	}
+	t.aggregate(nc)
 	return nc, numDeletions
 }
 
 // Insert is used to add or update a given key. The return provides
-// the previous value and a bool indicating if any was set.
+// the previous value and a bool indicating if any was set. It panics if the
+// key is invalid for the tree, see InsertErr.
 func (t *Txn[K, T]) Insert(k []K, v T) (T, bool) {
-	newRoot, oldVal, didUpdate := t.insert(t.root, k, k, v)
+	return t.upsert(k, v, nil)
+}
+
+// upsert inserts v under k in a single walk. If update is not nil, it stores
+// what update returns for the old value and whether k exists instead, or
+// leaves the tree untouched if update returns false. The return is the same
+// as for Insert. It panics if the key is invalid for the tree.
+func (t *Txn[K, T]) upsert(k []K, v T, update func(old T, exists bool) (T, bool)) (T, bool) {
+	if err := t.validateKey(k); err != nil {
+		panic(err)
+	}
+	return t.upsertValid(k, v, update, nil)
+}
+
+// upsertValid is like upsert for a key already validated for the tree. It
+// additionally sets ancestor, if not nil, to the leaf of the longest proper
+// prefix of k before update is called.
+func (t *Txn[K, T]) upsertValid(k []K, v T, update func(old T, exists bool) (T, bool), ancestor **leafNode[K, T]) (T, bool) {
+	t.checkWritable()
+	if t.tracer != nil {
+		start := time.Now()
+		defer func() { t.tracer.OnInsert(time.Since(start)) }()
+	}
+	if t.cloneKeys {
+		// The key is stored, and so is the part of it that becomes the
+		// prefix of a new node.
+		k = slices.Clone(k)
+	}
+	if t.bloom != nil {
+		t.bloom.add(k)
+	}
+	newRoot, oldVal, didUpdate := t.insert(t.root, k, k, v, update, ancestor)
 	if newRoot != nil {
 		t.root = newRoot
-	}
-	if !didUpdate {
-		t.size++
+		if !didUpdate {
+			t.size++
+		}
 	}
 	return oldVal, didUpdate
 }
 
+// GetOrInsert returns the value of k if it exists, and inserts v otherwise,
+// like sync.Map.LoadOrStore. It walks the tree once, and doesn't modify
+// anything if k exists. actual is the existing value if loaded is true, and
+// v otherwise.
+func (t *Txn[K, T]) GetOrInsert(k []K, v T) (actual T, loaded bool) {
+	old, loaded := t.upsert(k, v, func(old T, exists bool) (T, bool) {
+		return v, !exists
+	})
+	if loaded {
+		return old, true
+	}
+	return v, false
+}
+
+// InsertIfAbsent inserts v under k only if k doesn't exist. It returns the
+// existing value and false if it does, without writing anything, and the
+// zero value and true if v was inserted. It walks the tree once.
+func (t *Txn[K, T]) InsertIfAbsent(k []K, v T) (T, bool) {
+	old, exists := t.upsert(k, v, func(_ T, exists bool) (T, bool) {
+		return v, !exists
+	})
+	return old, !exists
+}
+
+// Update stores v under k only if k already exists. It returns the previous
+// value and true if it does, and the zero value and false otherwise, without
+// writing anything. It walks the tree once.
+func (t *Txn[K, T]) Update(k []K, v T) (T, bool) {
+	return t.upsert(k, v, func(_ T, exists bool) (T, bool) {
+		return v, exists
+	})
+}
+
+// InsertIfChangesInheritance stores v under k only if it differs from the
+// value k would otherwise inherit from its nearest ancestor, that is the
+// longest stored key that is a proper prefix of k. Values are compared with
+// eq. If k has no ancestor, v is always stored. If v equals the inherited
+// value and k holds an override, the override is deleted, so k inherits v
+// without storing it twice. Returns true if the tree was changed, either by
+// storing v or by deleting the override, and false if k already resolved to
+// v. It walks the tree once, and a second time to delete an override.
+func (t *Txn[K, T]) InsertIfChangesInheritance(k []K, v T, eq func(a, b T) bool) bool {
+	if err := t.validateKey(k); err != nil {
+		panic(err)
+	}
+	var ancestor *leafNode[K, T]
+	changed, redundant := false, false
+	t.upsertValid(k, v, func(old T, exists bool) (T, bool) {
+		if ancestor != nil && eq(ancestor.val, v) {
+			redundant = exists
+			return old, false
+		}
+		changed = !exists || !eq(old, v)
+		return v, changed
+	}, &ancestor)
+	if redundant {
+		t.Delete(k)
+		return true
+	}
+	return changed
+}
+
+// Index inserts v under the key derived from it by keyOf. Deriving keys in
+// one place keeps them consistent across inserts. The return is the same as
+// for Insert.
+func (t *Txn[K, T]) Index(v T, keyOf func(T) []K) (T, bool) {
+	return t.Insert(keyOf(v), v)
+}
+
+// Reindex rebuilds the tree with every value stored under the key derived
+// from it by keyOf, for when the key function changes. Values are reinserted
+// in the order of their old keys, so if several of them map to the same key,
+// the one with the greatest old key wins.
+func (t *Txn[K, T]) Reindex(keyOf func(T) []K) {
+	// Clear replaces the root rather than emptying it, so nodes the
+	// transaction already wrote, which would be modified in place, stay
+	// intact for the walk.
+	old := t.root
+	t.Clear()
+	old.Walk(func(_ []K, v T) bool {
+		t.Insert(keyOf(v), v)
+		return true
+	})
+}
+
 // Delete is used to delete a given key. Returns the old value if any,
-// and a bool indicating if the key was set.
+// and a bool indicating if the key was set. Nodes left without a value and
+// with a single edge are merged with their child, and nodes left without a
+// value and without edges are removed, so the tree stays fully
+// path-compressed after any delete.
 func (t *Txn[K, T]) Delete(k []K) (T, bool) {
+	t.checkWritable()
+	if t.tracer != nil {
+		start := time.Now()
+		defer func() { t.tracer.OnDelete(time.Since(start)) }()
+	}
 	var zero T
 	newRoot, leaf := t.delete(t.root, k)
 	if newRoot != nil {
@@ -469,6 +890,7 @@ func (t *Txn[K, T]) Delete(k []K) (T, bool) {
 // DeletePrefix is used to delete an entire subtree that matches the prefix
 // This will delete all nodes under that prefix
 func (t *Txn[K, T]) DeletePrefix(prefix []K) bool {
+	t.checkWritable()
 	newRoot, numDeletions := t.deletePrefix(t.root, prefix)
 	if newRoot != nil {
 		t.root = newRoot
@@ -479,6 +901,102 @@ func (t *Txn[K, T]) DeletePrefix(prefix []K) bool {
 
 }
 
+// DeletePrefixCount is like DeletePrefix, but returns the number of keys
+// deleted, which is zero if no key starts with the prefix.
+func (t *Txn[K, T]) DeletePrefixCount(prefix []K) int {
+	t.checkWritable()
+	newRoot, numDeletions := t.deletePrefix(t.root, prefix)
+	if newRoot != nil {
+		t.root = newRoot
+		t.size -= numDeletions
+	}
+	return numDeletions
+}
+
+// Clear deletes all keys, leaving the transaction with an empty tree to
+// rebuild. With TrackMutate enabled, the channels of every node and leaf of
+// the tree are tracked, so committing closes them all. The tree is only
+// walked for that, clearing is O(1) otherwise.
+func (t *Txn[K, T]) Clear() {
+	t.checkWritable()
+	if t.trackMutate {
+		t.trackChannelsAndCount(t.root)
+	}
+	if t.writable != nil {
+		t.writable.Clear()
+	}
+	root := &Node[K, T]{
+		mutateCh: t.newWatch(),
+		ext:      t.root.childExt(),
+	}
+	t.aggregate(root)
+	t.root = root
+	t.size = 0
+}
+
+// DeleteRange deletes every key from start, inclusive, to end, exclusive, and
+// returns the number of keys deleted. An empty end means there's no upper
+// bound, as for Range. The keys are collected first and then deleted one by
+// one, so watches of the deleted leaves are tracked and nodes are merged as
+// for Delete.
+func (t *Txn[K, T]) DeleteRange(start, end []K) int {
+	// Writable nodes are modified in place, so the keys can't be deleted
+	// while iterating.
+	var keys [][]K
+	it := t.root.Iterator()
+	it.SeekLowerBound(start)
+	it.SetUpperBound(end)
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		keys = append(keys, k)
+	}
+	for _, k := range keys {
+		t.Delete(k)
+	}
+	return len(keys)
+}
+
+// RetainPrefixes deletes all keys that don't start with any of the prefixes,
+// returning the number of keys deleted. The tree is walked once, and subtrees
+// that are entirely kept or entirely deleted aren't descended into.
+func (t *Txn[K, T]) RetainPrefixes(prefixes [][]K) int {
+	var dropSubtrees, dropKeys [][]K
+	var visit func(n *Node[K, T], path []K)
+	visit = func(n *Node[K, T], path []K) {
+		partial := false
+		for _, p := range prefixes {
+			if keyHasPrefix(path, p) {
+				// Everything under this node is kept.
+				return
+			}
+			if keyHasPrefix(p, path) {
+				partial = true
+			}
+		}
+		if !partial {
+			dropSubtrees = append(dropSubtrees, path)
+			return
+		}
+		// Some keys under this node are kept, but not its own, which is
+		// shorter than the prefix it's partially covered by.
+		if n.leaf != nil {
+			dropKeys = append(dropKeys, n.leaf.key)
+		}
+		for _, e := range n.edges {
+			visit(e.node, append(slices.Clip(path), e.node.prefix...))
+		}
+	}
+	visit(t.root, nil)
+
+	size := t.size
+	for _, p := range dropSubtrees {
+		t.DeletePrefix(p)
+	}
+	for _, k := range dropKeys {
+		t.Delete(k)
+	}
+	return size - t.size
+}
+
 // Root returns the current root of the radix tree within this
 // transaction. The root is not safe across insert and delete operations,
 // but can be used to read the current state during a transaction.
@@ -489,6 +1007,10 @@ func (t *Txn[K, T]) Root() *Node[K, T] {
 // Get is used to lookup a specific key, returning
 // the value and if it was found
 func (t *Txn[K, T]) Get(k []K) (T, bool) {
+	if t.tracer != nil {
+		start := time.Now()
+		defer func() { t.tracer.OnGet(time.Since(start)) }()
+	}
 	return t.root.Get(k)
 }
 
@@ -498,6 +1020,32 @@ func (t *Txn[K, T]) GetWatch(k []K) (<-chan struct{}, T, bool) {
 	return t.root.GetWatch(k)
 }
 
+// SetPreCommitHook sets a function that validates the final root of the
+// transaction before it is committed, which allows enforcing invariants that
+// span multiple keys. If the hook returns an error, CommitChecked returns it
+// without producing a new tree or issuing notifications, and the transaction
+// can still be amended and committed again. Commit and CommitOnly panic if
+// the hook fails, so use CommitChecked when a hook is set.
+func (t *Txn[K, T]) SetPreCommitHook(fn func(root *Node[K, T]) error) {
+	t.preCommit = fn
+}
+
+// CommitChecked is like Commit, but runs the pre-commit hook first and
+// returns its error instead of committing if it fails.
+func (t *Txn[K, T]) CommitChecked() (*Tree[K, T], error) {
+	t.checkWritable()
+	if t.preCommit != nil {
+		if err := t.preCommit(t.root); err != nil {
+			return nil, err
+		}
+	}
+	nt := t.commit()
+	if t.trackMutate {
+		t.Notify()
+	}
+	return nt, nil
+}
+
 // Commit is used to finalize the transaction and return a new tree. If mutation
 // tracking is turned on then notifications will also be issued.
 func (t *Txn[K, T]) Commit() *Tree[K, T] {
@@ -511,10 +1059,23 @@ func (t *Txn[K, T]) Commit() *Tree[K, T] {
 // CommitOnly is used to finalize the transaction and return a new tree, but
 // does not issue any notifications until Notify is called.
 func (t *Txn[K, T]) CommitOnly() *Tree[K, T] {
+	t.checkWritable()
+	if t.preCommit != nil {
+		if err := t.preCommit(t.root); err != nil {
+			panic("iradix: pre-commit hook failed: " + err.Error())
+		}
+	}
+	return t.commit()
+}
+
+// commit finalizes the transaction without running the pre-commit hook.
+func (t *Txn[K, T]) commit() *Tree[K, T] {
 	nt := &Tree[K, T]{
 		options: t.options,
 		root:    t.root,
 		size:    t.size,
+		hint:    t.hint,
+		bloom:   t.bloom,
 	}
 	if t.writable != nil {
 		t.writable.Clear()
@@ -525,15 +1086,16 @@ func (t *Txn[K, T]) CommitOnly() *Tree[K, T] {
 
 // slowNotify does a complete comparison of the before and after trees in order
 // to trigger notifications. This doesn't require any additional state but it
-// is very expensive to compute.
-func (t *Txn[K, T]) slowNotify() {
+// is very expensive to compute. Returns the number of channels closed and the
+// number of node paths compared.
+func (t *Txn[K, T]) slowNotify() (closed, compared int) {
 	snapIter := t.snap.rawIterator()
 	rootIter := t.root.rawIterator()
 	for snapIter.Front() != nil || rootIter.Front() != nil {
 		// If we've exhausted the nodes in the old snapshot, we know
 		// there's nothing remaining to notify.
 		if snapIter.Front() == nil {
-			return
+			return closed, compared
 		}
 		snapElem := snapIter.Front()
 
@@ -542,25 +1104,20 @@ func (t *Txn[K, T]) slowNotify() {
 		// know from the loop condition there's something in the old
 		// snapshot.
 		if rootIter.Front() == nil {
-			close(snapElem.mutateCh)
-			if snapElem.isLeaf() {
-				close(snapElem.leaf.mutateCh)
-			}
+			closed += t.closeNodeWatches(snapElem, nil)
 			snapIter.Next()
 			continue
 		}
 
 		// Do one string compare so we can check the various conditions
 		// below without repeating the compare.
-		cmp := keyCompare(snapIter.Path(), rootIter.Path())
+		cmp := t.root.compareKeys(snapIter.Path(), rootIter.Path())
+		compared++
 
 		// If the snapshot is behind the root, then we must have deleted
 		// this node during the transaction.
 		if cmp < 0 {
-			close(snapElem.mutateCh)
-			if snapElem.isLeaf() {
-				close(snapElem.leaf.mutateCh)
-			}
+			closed += t.closeNodeWatches(snapElem, nil)
 			snapIter.Next()
 			continue
 		}
@@ -576,14 +1133,28 @@ func (t *Txn[K, T]) slowNotify() {
 		// node and possibly the leaf.
 		rootElem := rootIter.Front()
 		if snapElem != rootElem {
-			close(snapElem.mutateCh)
-			if snapElem.leaf != nil && (snapElem.leaf != rootElem.leaf) {
-				close(snapElem.leaf.mutateCh)
-			}
+			closed += t.closeNodeWatches(snapElem, rootElem.leaf)
 		}
 		snapIter.Next()
 		rootIter.Next()
 	}
+	return closed, compared
+}
+
+// closeNodeWatches closes the watch channels of a node that was replaced or
+// removed, unless tracking is limited to leaves, and those of its leaf unless
+// it was kept as keep. Returns the number of channels closed.
+func (t *Txn[K, T]) closeNodeWatches(n *Node[K, T], keep *leafNode[K, T]) int {
+	closed := 0
+	if !t.trackLeavesOnly {
+		closeWatch(&n.mutateCh)
+		closed++
+	}
+	if n.leaf != nil && n.leaf != keep {
+		closeWatch(&n.leaf.mutateCh)
+		closed++
+	}
+	return closed
 }
 
 // Notify is used along with TrackMutate to trigger notifications. This must
@@ -596,12 +1167,17 @@ func (t *Txn[K, T]) Notify() {
 
 	// If we've overflowed the tracking state we can't use it in any way and
 	// need to do a full tree compare.
+	var closed, compared int
 	if t.trackOverflow {
-		t.slowNotify()
+		closed, compared = t.slowNotify()
 	} else {
 		for ch := range t.trackChannels {
 			close(ch)
 		}
+		closed = len(t.trackChannels)
+	}
+	if t.notifyTrace != nil {
+		t.notifyTrace(closed, compared)
 	}
 
 	// Clean up the tracking state so that a re-notify is safe (will trigger
@@ -634,18 +1210,530 @@ func (t *Tree[K, T]) DeletePrefix(k []K) (*Tree[K, T], bool) {
 	return txn.Commit(), ok
 }
 
+// DeleteRange deletes every key from start, inclusive, to end, exclusive.
+// Returns the new tree and the number of keys deleted. An empty end means
+// there's no upper bound.
+func (t *Tree[K, T]) DeleteRange(start, end []K) (*Tree[K, T], int) {
+	txn := t.Txn()
+	n := txn.DeleteRange(start, end)
+	return txn.Commit(), n
+}
+
+// DeletePrefixCount is like DeletePrefix, but returns the number of keys
+// deleted along with the new tree.
+func (t *Tree[K, T]) DeletePrefixCount(k []K) (*Tree[K, T], int) {
+	txn := t.Txn()
+	n := txn.DeletePrefixCount(k)
+	return txn.Commit(), n
+}
+
+// Clear returns an empty tree with the same options as t, with a Bloom filter
+// and frequency hint of its own. Like Delete and the other methods deriving
+// trees outside a transaction, it doesn't notify any watches.
+func (t *Tree[K, T]) Clear() *Tree[K, T] {
+	return newTree[K, T](t.options)
+}
+
 // Root returns the root node of the tree which can be used for richer
 // query operations.
 func (t *Tree[K, T]) Root() *Node[K, T] {
 	return t.root
 }
 
+// Keys returns all keys in order. The keys are copies, so they can be
+// modified freely.
+func (t *Tree[K, T]) Keys() [][]K {
+	keys := make([][]K, 0, t.size)
+	iter := t.root.Iterator()
+	for k, _, ok := iter.Next(); ok; k, _, ok = iter.Next() {
+		if !t.cloneKeys {
+			k = slices.Clone(k)
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Values returns all values in the order of their keys.
+func (t *Tree[K, T]) Values() []T {
+	values := make([]T, 0, t.size)
+	iter := t.root.Iterator()
+	for _, v, ok := iter.Next(); ok; _, v, ok = iter.Next() {
+		values = append(values, v)
+	}
+	return values
+}
+
+// WatchPrefix returns a channel that is closed by the next commit changing
+// any key under prefix, if the transaction has TrackMutate enabled. It's the
+// channel of the node holding all keys under prefix, as found by
+// SeekPrefixWatch. If no key starts with prefix, it's the channel of the
+// node a key under it would be inserted below, which also fires for some
+// changes of other keys. Channels only fire once, so callers must call
+// WatchPrefix again on the new tree after each fire to keep watching.
+func (t *Tree[K, T]) WatchPrefix(prefix []K) <-chan struct{} {
+	return t.root.Iterator().SeekPrefixWatch(prefix)
+}
+
 // Get is used to lookup a specific key, returning
 // the value and if it was found
 func (t *Tree[K, T]) Get(k []K) (T, bool) {
+	if t.tracer != nil {
+		start := time.Now()
+		defer func() { t.tracer.OnGet(time.Since(start)) }()
+	}
+	if t.bloom != nil && !t.bloom.mayContain(k) {
+		var zero T
+		return zero, false
+	}
+	if t.accessNow != nil {
+		// Access tracking needs the leaf, which the frequency hint doesn't
+		// hand out.
+		_, leaf := t.root.getWatchNode(k)
+		if leaf == nil {
+			var zero T
+			return zero, false
+		}
+		leaf.ext.lastAccess.Store(t.accessNow())
+		return leaf.val, true
+	}
+	if t.hint != nil {
+		return t.hint.get(t.root, k)
+	}
 	return t.root.Get(k)
 }
 
+// Has returns whether the key is in the tree.
+func (t *Tree[K, T]) Has(k []K) bool {
+	_, ok := t.Get(k)
+	return ok
+}
+
+// HasMany returns whether each of the keys is in the tree, in the order of
+// the keys. The keys are sorted and looked up in a single sweep, where each
+// lookup resumes from the deepest node on the path of the previous key that
+// is also on its own path, instead of descending from the root. The sweep is
+// cheaper than as many calls to Has, but sorting unsorted keys usually costs
+// more than it saves, so this pays off for keys that are already sorted, see
+// BenchmarkHasMany in the benchmark module.
+func (t *Tree[K, T]) HasMany(keys [][]K) []bool {
+	type query struct {
+		key []K
+		idx int
+	}
+	order := make([]query, len(keys))
+	for i, k := range keys {
+		order[i] = query{key: k, idx: i}
+	}
+	slices.SortFunc(order, func(a, b query) int {
+		return t.root.compareKeys(a.key, b.key)
+	})
+
+	// path holds the nodes on the path of the previous key, along with the
+	// length of their effective paths.
+	type step struct {
+		n     *Node[K, T]
+		depth int
+	}
+	path := []step{{n: t.root}}
+	out := make([]bool, len(keys))
+	var prev []K
+	for _, q := range order {
+		k, i := q.key, q.idx
+		shared := longestPrefix(prev, k)
+		prev = k
+		for path[len(path)-1].depth > shared {
+			path = path[:len(path)-1]
+		}
+
+		top := path[len(path)-1]
+		n, search := top.n, k[top.depth:]
+		for {
+			if len(search) == 0 {
+				out[i] = n.leaf != nil
+				break
+			}
+			_, child := n.getEdge(search[0])
+			if child == nil || !keyHasPrefix(search, child.prefix) {
+				break
+			}
+			search = search[len(child.prefix):]
+			n = child
+			path = append(path, step{n: n, depth: len(k) - len(search)})
+		}
+	}
+	return out
+}
+
+// ShortestPrefix returns the shortest stored key that is a prefix of k,
+// including k itself, and its value.
+func (t *Tree[K, T]) ShortestPrefix(k []K) ([]K, T, bool) {
+	return t.root.ShortestPrefix(k)
+}
+
+// GetPtr is used to lookup a specific key, returning a pointer to the stored
+// value, or nil if the key is not found. Unlike Get, this distinguishes a
+// stored zero value from a missing key at a glance. The pointer refers to the
+// value stored in the tree, which is shared by all trees containing it, so it
+// must never be written through.
+func (t *Tree[K, T]) GetPtr(k []K) *T {
+	if _, leaf := t.root.getWatchNode(k); leaf != nil {
+		return &leaf.val
+	}
+	return nil
+}
+
+// MultiGet looks up several keys at once, returning the entries of the keys
+// that were found in the order they were requested. All keys are read from
+// this tree, which is an immutable snapshot, so the result is consistent even
+// if other transactions are committed concurrently. Holding on to a single
+// *Tree and reading through it is what prevents torn reads; re-fetching the
+// latest tree between Get calls doesn't.
+func (t *Tree[K, T]) MultiGet(keys [][]K) []Entry[K, T] {
+	var out []Entry[K, T]
+	for _, k := range keys {
+		if _, leaf := t.root.getWatchNode(k); leaf != nil {
+			out = append(out, Entry[K, T]{Key: leaf.publicKey(), Value: leaf.val})
+		}
+	}
+	return out
+}
+
+// InsertCost estimates how many nodes inserting k into the tree would copy,
+// without modifying it: every node on the path down to where k belongs, plus
+// one if an edge has to be split. This is what Txn.NodesCopied reports for the
+// insert in a new transaction. New nodes created by the insert aren't counted.
+func (t *Tree[K, T]) InsertCost(k []K) int {
+	cost := 1
+	n := t.root
+	search := k
+	for len(search) > 0 {
+		_, n = n.getEdge(search[0])
+		if n == nil {
+			break
+		}
+		if !keyHasPrefix(search, n.prefix) {
+			// The edge is split, and the child copied to shorten its prefix.
+			return cost + 1
+		}
+		search = search[len(n.prefix):]
+		cost++
+	}
+	return cost
+}
+
+// LookupProfile does a lookup of every key the way Get does, without the
+// frequency hint or Bloom filter, and returns the total number of nodes
+// visited, including the root, and of edge labels compared while searching
+// the edges of each node. This is meant to model the cost of a real query
+// distribution, e.g. to estimate how much an edge index or WithFrequencyHint
+// could save.
+func (t *Tree[K, T]) LookupProfile(keys [][]K) (totalNodes, totalEdges int) {
+	for _, k := range keys {
+		n := t.root
+		search := k
+		totalNodes++
+		for len(search) > 0 {
+			var probes int
+			n, probes = n.probeEdge(search[0])
+			totalEdges += probes
+			if n == nil {
+				break
+			}
+			totalNodes++
+			if !keyHasPrefix(search, n.prefix) {
+				break
+			}
+			search = search[len(n.prefix):]
+		}
+	}
+	return totalNodes, totalEdges
+}
+
+// LastNBefore returns up to n entries with keys strictly lower than key, in
+// descending key order. This is what paging backwards through the tree needs:
+// pass the lowest key of the current page to get the previous one. Only the
+// returned entries are visited.
+func (t *Tree[K, T]) LastNBefore(key []K, n int) []Entry[K, T] {
+	if n <= 0 {
+		return nil
+	}
+	it := t.root.ReverseIterator()
+	it.SeekReverseLowerBound(key)
+
+	var out []Entry[K, T]
+	for len(out) < n {
+		k, v, ok := it.Previous()
+		if !ok {
+			break
+		}
+		// The lower bound is inclusive, so key itself can only come first.
+		if len(out) == 0 && keyEqual(k, key) {
+			continue
+		}
+		out = append(out, Entry[K, T]{Key: k, Value: v})
+	}
+	return out
+}
+
+// MaxByValue returns the entry with the greatest value according to less,
+// which has to walk all entries as values aren't ordered. If several entries
+// have the greatest value, the one with the smallest key is returned. The
+// bool is false for an empty tree.
+func MaxByValue[K keyT, T any](t *Tree[K, T], less func(a, b T) bool) (Entry[K, T], bool) {
+	return MinByValue(t, func(a, b T) bool {
+		return less(b, a)
+	})
+}
+
+// MinByValue returns the entry with the smallest value according to less,
+// which has to walk all entries as values aren't ordered. If several entries
+// have the smallest value, the one with the smallest key is returned. The
+// bool is false for an empty tree.
+func MinByValue[K keyT, T any](t *Tree[K, T], less func(a, b T) bool) (Entry[K, T], bool) {
+	var best Entry[K, T]
+	found := false
+	t.root.Walk(func(k []K, v T) bool {
+		// Keys come in order, so only strictly smaller values replace the
+		// current minimum.
+		if !found || less(v, best.Value) {
+			best = Entry[K, T]{Key: k, Value: v}
+			found = true
+		}
+		return true
+	})
+	return best, found
+}
+
+// WalkPrefixRange walks all keys from the namespace of loPrefix up to, but
+// excluding, the namespace of hiPrefix in key order. That is, every key that
+// is >= loPrefix, including all keys under loPrefix, and whose leading
+// len(hiPrefix) elements sort before hiPrefix, which excludes hiPrefix itself
+// and all keys under it. Returning false from fn stops the walk.
+func (t *Tree[K, T]) WalkPrefixRange(loPrefix, hiPrefix []K, fn WalkFn[K, T]) {
+	it := t.root.Iterator()
+	it.SeekLowerBound(loPrefix)
+	for k, v, ok := it.Next(); ok; k, v, ok = it.Next() {
+		if t.root.compareKeys(k[:min(len(k), len(hiPrefix))], hiPrefix) >= 0 {
+			return
+		}
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// CountPrefix returns the number of keys starting with prefix. The number of
+// keys under every node is maintained by transactions, so this takes
+// O(len(prefix)).
+func (t *Tree[K, T]) CountPrefix(prefix []K) int {
+	return t.root.CountPrefix(prefix)
+}
+
+// AllKeysMatch returns true if pred returns true for every key. Otherwise it
+// returns the first key in key order for which pred returns false, and stops
+// there.
+func (t *Tree[K, T]) AllKeysMatch(pred func(k []K) bool) ([]K, bool) {
+	var violator []K
+	ok := true
+	t.root.Walk(func(k []K, _ T) bool {
+		if !pred(k) {
+			violator, ok = k, false
+		}
+		return ok
+	})
+	return violator, ok
+}
+
+// DedupeValues returns a tree with the same keys as t, with every value
+// replaced by what canonical returns for it, e.g. a single shared instance
+// for all values that are equivalent, such as pointers to zero values after a
+// bulk load. Results that are equal by == are stored as one instance, the
+// first in key order, so equal strings and structs holding them share their
+// memory too. Results whose dynamic type isn't comparable are stored as
+// returned. The new tree is built in a single transaction, and t is left
+// untouched.
+func (t *Tree[K, T]) DedupeValues(canonical func(T) T) *Tree[K, T] {
+	seen := make(map[any]T)
+	txn := t.Txn()
+	t.root.Walk(func(k []K, v T) bool {
+		v = canonical(v)
+		if rv := reflect.ValueOf(any(v)); !rv.IsValid() || rv.Comparable() {
+			if first, ok := seen[any(v)]; ok {
+				v = first
+			} else {
+				seen[any(v)] = v
+			}
+		}
+		txn.Insert(k, v)
+		return true
+	})
+	return txn.Commit()
+}
+
+// SubTree returns a new tree with the same options holding the entries keyed
+// under prefix, with prefix stripped from their keys, along with whether
+// there were any. Keys are stored whole in the leaves, so the entries are
+// inserted into the new tree one by one rather than sharing the nodes. The
+// entry keyed by prefix itself becomes the empty key, so the new tree accepts
+// it even if t was created with WithRejectEmptyKey.
+func (t *Tree[K, T]) SubTree(prefix []K) (*Tree[K, T], bool) {
+	o := t.options
+	o.rejectEmptyKey = false
+	txn := newTree[K, T](o).Txn()
+	iter := t.root.Iterator()
+	iter.SeekPrefix(prefix)
+	for k, v, ok := iter.Next(); ok; k, v, ok = iter.Next() {
+		txn.Insert(k[len(prefix):], v)
+	}
+	sub := txn.Commit()
+	return sub, sub.Len() > 0
+}
+
+// Filter returns a new tree with the same options holding the entries for
+// which keep returns true. The entries are inserted into an empty tree, so
+// it shares no nodes, nor a bloom filter or frequency hint, with t.
+func (t *Tree[K, T]) Filter(keep func(k []K, v T) bool) *Tree[K, T] {
+	txn := newTree[K, T](t.options).Txn()
+	t.root.Walk(func(k []K, v T) bool {
+		if keep(k, v) {
+			txn.Insert(k, v)
+		}
+		return true
+	})
+	return txn.Commit()
+}
+
+// MapValues returns a tree with the keys of t and their values transformed by
+// f, which is called in key order. The shape of t is copied node by node
+// rather than rebuilt by inserting every key. The new tree has the options of
+// t, except for those tied to the value type, such as an aggregator or value
+// codec, and gets a Bloom filter or frequency hint of its own.
+func MapValues[K keyT, A, B any](t *Tree[K, A], f func(k []K, v A) B) *Tree[K, B] {
+	o := t.options
+	o.aggregator = nil
+	o.valueCodec = nil
+	nt := newTree[K, B](o)
+	nt.root = mapNode(nt, t.root, f)
+	nt.size = t.size
+	return nt
+}
+
+// mapNode returns a copy of n for the tree nt, with its values transformed by
+// f.
+func mapNode[K keyT, A, B any](nt *Tree[K, B], n *Node[K, A], f func(k []K, v A) B) *Node[K, B] {
+	nn := &Node[K, B]{
+		mutateCh: nt.newWatch(),
+		prefix:   n.prefix,
+		size:     n.size,
+		ext:      n.childExt(),
+		dense:    n.dense,
+	}
+	if n.leaf != nil {
+		nn.leaf = &leafNode[K, B]{
+			mutateCh: nt.newWatch(),
+			key:      n.leaf.key,
+			val:      f(n.leaf.publicKey(), n.leaf.val),
+			ext:      nt.newLeafExt(),
+		}
+		if nt.bloom != nil {
+			nt.bloom.add(n.leaf.key)
+		}
+	}
+	if len(n.edges) > 0 {
+		nn.edges = make(edges[K, B], len(n.edges))
+		for i, e := range n.edges {
+			nn.edges[i] = edge[K, B]{label: e.label, node: mapNode(nt, e.node, f)}
+		}
+	}
+	return nn
+}
+
+// CoveringPrefixes returns the longest prefixes, in key order, that together
+// cover all keys, with every key under exactly one of them. These are the
+// paths of the first nodes below the root, where keys starting with the same
+// element fork or end, so no prefix is shared by keys starting differently.
+// If the empty key is stored it covers everything by itself and the result is
+// a single empty prefix. An empty tree has no covering prefixes. The prefixes
+// are copies, which the caller may modify.
+func (t *Tree[K, T]) CoveringPrefixes() [][]K {
+	if t.root.leaf != nil {
+		return [][]K{{}}
+	}
+	var out [][]K
+	for _, e := range t.root.edges {
+		out = append(out, slices.Clone(e.node.prefix))
+	}
+	return out
+}
+
+// DistinctAt returns the number of distinct prefixes of the given length
+// among all keys, ignoring keys shorter than that, e.g. how many different
+// values the first element of the keys takes for length 1. Only the nodes
+// above that length are visited, not the keys under them.
+func (t *Tree[K, T]) DistinctAt(length int) int {
+	return distinctAt(t.root, 0, length)
+}
+
+// WalkExcluding walks the tree in key order like Walk, skipping all keys that
+// start with any of the excluded prefixes. Subtrees under an excluded prefix
+// are pruned as a whole rather than filtered key by key, and subtrees no
+// excluded prefix reaches into are walked without further checks. Excluded
+// prefixes may overlap or nest. Returning false from fn stops the walk.
+func (t *Tree[K, T]) WalkExcluding(exclude [][]K, fn WalkFn[K, T]) {
+	walkExcluding(t.root, nil, exclude, fn)
+}
+
+// WalkStride walks the tree in key order, visiting the first entry and then
+// every stride-th entry after it, which is handy for downsampling ordered
+// data. Subtrees holding only skipped entries are never descended into, so
+// the walk takes about O(Len/stride) node visits for large strides. A stride
+// below 1 is treated as 1. Returning false from fn stops the walk.
+func (t *Tree[K, T]) WalkStride(stride int, fn WalkFn[K, T]) {
+	stride = max(stride, 1)
+	skip := 0
+	strideWalk(t.root, stride, &skip, fn)
+}
+
+// IsCompressed reports whether the tree is fully path-compressed, that is
+// every node but the root that holds no value has at least two edges. Nodes
+// with a single edge should have been merged with their child, and nodes
+// without edges removed. Mutations always keep trees compressed, so this is
+// meant for asserting that invariant in tests.
+func (t *Tree[K, T]) IsCompressed() bool {
+	for iter := t.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		n := iter.Front()
+		if n != t.root && !n.isLeaf() && len(n.edges) < 2 {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkWithSizes walks every node of the underlying radix structure in
+// pre-order, passing its full path, whether it holds a value, and the number
+// of values stored in its subtree, including its own. This feeds treemap-style
+// visualizations directly. Returning false from fn stops the walk.
+func (t *Tree[K, T]) WalkWithSizes(fn func(prefix []K, isLeaf bool, subtreeLeaves int) bool) {
+	for iter := t.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		n := iter.Front()
+		if !fn(iter.Path(), n.isLeaf(), n.size) {
+			return
+		}
+	}
+}
+
+// WalkTree walks every node of the underlying radix structure in pre-order,
+// including internal nodes that don't hold a value. For each node fn receives
+// the node's own prefix relative to its parent, whether it holds a value and
+// the value itself, whether it's the last edge of its parent, and its depth
+// counted in edges from the root. The root has depth 0 and is considered the
+// last child. This is the building block for rendering the tree. Returning
+// false from fn stops the walk.
+func (t *Tree[K, T]) WalkTree(fn func(prefix []K, isLeaf bool, val T, isLastChild bool, depth int) bool) {
+	recursiveWalkTree(t.root, true, 0, fn)
+}
+
 // longestPrefix finds the length of the shared prefix
 // of two strings
 func longestPrefix[K keyT](k1, k2 []K) int {
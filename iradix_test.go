@@ -5,14 +5,18 @@ package iradix
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"math/rand"
 	"reflect"
 	"slices"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"testing/quick"
 	"time"
+	"unsafe"
 )
 
 func copyTree[K keyT, T any](t *Tree[K, T]) *Tree[K, T] {
@@ -42,6 +46,8 @@ func copyNode[K keyT, T any](n *Node[K, T]) *Node[K, T] {
 			nn.edges[idx].node = copyNode(ed.node)
 		}
 	}
+	nn.size = n.size
+	nn.ext = n.ext
 	return nn
 }
 
@@ -239,6 +245,74 @@ func TestInsert_UpdateFeedback(t *testing.T) {
 	}
 }
 
+func TestInsertIfChangesInheritance(t *testing.T) {
+	r := New[byte, string]()
+	r, _, _ = r.Insert([]byte("app"), "on")
+	r, _, _ = r.Insert([]byte("app/db"), "off")
+	eq := func(a, b string) bool { return a == b }
+
+	txn := r.Txn()
+
+	// Matches the inherited value, nothing is stored.
+	if txn.InsertIfChangesInheritance([]byte("app/web"), "on", eq) {
+		t.Fatalf("expected redundant override to be skipped")
+	}
+	if _, ok := txn.Get([]byte("app/web")); ok {
+		t.Fatalf("redundant override was stored")
+	}
+
+	// Inherits from the nearest ancestor, not the root-most one.
+	if txn.InsertIfChangesInheritance([]byte("app/db/replica"), "off", eq) {
+		t.Fatalf("expected redundant override to be skipped")
+	}
+
+	// Differs from the inherited value, gets stored.
+	if !txn.InsertIfChangesInheritance([]byte("app/db/replica"), "on", eq) {
+		t.Fatalf("expected override to be stored")
+	}
+	if v, ok := txn.Get([]byte("app/db/replica")); !ok || v != "on" {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+
+	// Storing the same override again changes nothing.
+	if txn.InsertIfChangesInheritance([]byte("app/db/replica"), "on", eq) {
+		t.Fatalf("expected unchanged override to be skipped")
+	}
+
+	// No ancestor, always stored.
+	if !txn.InsertIfChangesInheritance([]byte("lib"), "on", eq) {
+		t.Fatalf("expected value without ancestor to be stored")
+	}
+
+	r = txn.Commit()
+	verifyTree(t, []string{"app", "app/db", "app/db/replica", "lib"}, r)
+	if r.Len() != 4 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+}
+
+func TestInsertIfChangesInheritance_DeletesRedundantOverride(t *testing.T) {
+	r := New[byte, string]()
+	r, _, _ = r.Insert([]byte("app"), "on")
+	r, _, _ = r.Insert([]byte("app/db"), "off")
+	eq := func(a, b string) bool { return a == b }
+
+	// An existing override equal to the inherited value is removed, which
+	// changes the tree.
+	txn := r.Txn()
+	if !txn.InsertIfChangesInheritance([]byte("app/db"), "on", eq) {
+		t.Fatalf("expected redundant override to be removed")
+	}
+	if _, ok := txn.Get([]byte("app/db")); ok {
+		t.Fatalf("redundant override was not removed")
+	}
+	if txn.InsertIfChangesInheritance([]byte("app/db"), "on", eq) {
+		t.Fatalf("expected nothing to change")
+	}
+	r = txn.Commit()
+	verifyTree(t, []string{"app"}, r)
+}
+
 func TestDelete(t *testing.T) {
 	r := New[byte, bool]()
 	s := []string{"", "A", "AB"}
@@ -255,6 +329,153 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+// checkCompressed verifies that no node other than the root could be merged
+// with its only child or removed, and returns the number of nodes.
+func checkCompressed[K keyT, T any](t *testing.T, r *Tree[K, T]) int {
+	t.Helper()
+	nodes := 0
+	for iter := r.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		n := iter.Front()
+		nodes++
+		if n == r.root || n.isLeaf() {
+			continue
+		}
+		if len(n.edges) < 2 {
+			t.Fatalf("node %v without value has %d edges", iter.Path(), len(n.edges))
+		}
+	}
+	return nodes
+}
+
+func TestIsCompressed(t *testing.T) {
+	r := New[byte, int]()
+	if !r.IsCompressed() {
+		t.Fatalf("empty tree is compressed")
+	}
+	txn := r.Txn()
+	for i, k := range []string{"a", "ab", "abc", "abd", "b", "bcd"} {
+		txn.Insert([]byte(k), i)
+	}
+	for _, k := range []string{"ab", "abc", "a"} {
+		txn.Delete([]byte(k))
+	}
+	r = txn.Commit()
+	if !r.IsCompressed() {
+		t.Fatalf("tree is not compressed after deletes")
+	}
+
+	// The root may have a single edge.
+	single, _, _ := New[byte, int]().Insert([]byte("abc"), 1)
+	if !single.IsCompressed() {
+		t.Fatalf("single key tree is compressed")
+	}
+
+	// Build a tree with a valueless node with a single edge by hand.
+	leaf := &Node[byte, int]{prefix: []byte("cd"), leaf: &leafNode[byte, int]{key: []byte("bcd"), val: 1}}
+	uncompressed := &Tree[byte, int]{root: &Node[byte, int]{edges: edges[byte, int]{
+		r.root.edges[0],
+		{label: 'b', node: &Node[byte, int]{prefix: []byte("b"), edges: edges[byte, int]{{label: 'c', node: leaf}}}},
+	}}}
+	if uncompressed.IsCompressed() {
+		t.Fatalf("expected single edge node to be detected")
+	}
+
+	// A valueless node without edges.
+	uncompressed.root.edges[1].node = &Node[byte, int]{prefix: []byte("b")}
+	if uncompressed.IsCompressed() {
+		t.Fatalf("expected empty node to be detected")
+	}
+}
+
+func TestDelete_Compaction(t *testing.T) {
+	r := New[byte, int]()
+	r, _, _ = r.Insert([]byte("a"), 1)
+	r, _, _ = r.Insert([]byte("ab"), 2)
+	if nodes := checkCompressed(t, r); nodes != 3 {
+		t.Fatalf("expected 3 nodes, got %d", nodes)
+	}
+
+	// Deleting the internal leaf merges it with its only child.
+	r, _, _ = r.Delete([]byte("a"))
+	if nodes := checkCompressed(t, r); nodes != 2 {
+		t.Fatalf("expected 2 nodes, got %d", nodes)
+	}
+	if string(r.root.edges[0].node.prefix) != "ab" {
+		t.Fatalf("expected merged prefix, got %q", r.root.edges[0].node.prefix)
+	}
+
+	// Deleting a leaf collapses its parent split node too.
+	r = New[byte, int]()
+	for i, k := range []string{"foo", "foobar", "foobaz", "fooqux"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	r, _, _ = r.Delete([]byte("foobar"))
+	r, _, _ = r.Delete([]byte("foo"))
+	if nodes := checkCompressed(t, r); nodes != 4 {
+		t.Fatalf("expected 4 nodes, got %d", nodes)
+	}
+
+	// Random deletes within a single transaction, where nodes are modified
+	// in place, keep the tree compressed as well.
+	seedRand()
+	var keys [][]byte
+	txn := New[byte, int]().Txn()
+	for i := 0; i < 1000; i++ {
+		k := []byte(randomHexString(2)[:rng.Intn(5)])
+		keys = append(keys, k)
+		txn.Insert(k, i)
+	}
+	for _, k := range keys[:800] {
+		txn.Delete(k)
+	}
+	checkCompressed(t, txn.Commit())
+}
+
+func TestRetainPrefixes(t *testing.T) {
+	keys := []string{"", "a", "a/1", "a/2", "ab", "b", "b/1", "b/2/x", "c", "cd/1", "d"}
+	cases := []struct {
+		prefixes []string
+		kept     []string
+	}{
+		{[]string{"a/"}, []string{"a/1", "a/2"}},
+		{[]string{"a", "a/"}, []string{"a", "a/1", "a/2", "ab"}},
+		{[]string{"b/", "cd", "zzz"}, []string{"b/1", "b/2/x", "cd/1"}},
+		{[]string{"b/2/x/y"}, nil},
+		{[]string{""}, keys},
+		{nil, nil},
+	}
+	for _, tc := range cases {
+		r := New[byte, int]()
+		for i, k := range keys {
+			r, _, _ = r.Insert([]byte(k), i)
+		}
+		prefixes := make([][]byte, len(tc.prefixes))
+		for i, p := range tc.prefixes {
+			prefixes[i] = []byte(p)
+		}
+
+		txn := r.Txn()
+		deleted := txn.RetainPrefixes(prefixes)
+		r = txn.Commit()
+
+		var kept []string
+		r.Root().Walk(func(k []byte, _ int) bool {
+			kept = append(kept, string(k))
+			return true
+		})
+		if !slices.Equal(kept, tc.kept) {
+			t.Fatalf("%q: kept %q, want %q", tc.prefixes, kept, tc.kept)
+		}
+		if deleted != len(keys)-len(tc.kept) || r.Len() != len(tc.kept) {
+			t.Fatalf("%q: bad count %d, len %d", tc.prefixes, deleted, r.Len())
+		}
+		checkSubtreeCounts(t, r)
+		if !r.IsCompressed() {
+			t.Fatalf("%q: tree is not compressed", tc.prefixes)
+		}
+	}
+}
+
 func TestDeletePrefix(t *testing.T) {
 
 	type exp struct {
@@ -368,6 +589,248 @@ func TestDeletePrefix(t *testing.T) {
 	}
 }
 
+func TestDeletePrefixCount(t *testing.T) {
+	r := New[byte, bool]()
+	for _, k := range []string{"", "test", "test/test1", "test/test2", "testAAA", "R", "RA"} {
+		r, _, _ = r.Insert([]byte(k), true)
+	}
+
+	cases := []struct {
+		prefix string
+		count  int
+	}{
+		{"test/", 2},
+		{"test", 4},
+		{"test/test1", 1},
+		{"test/test1x", 0},
+		{"CCCCC", 0},
+		{"R", 2},
+		{"", 7},
+	}
+	for _, tc := range cases {
+		nr, n := r.DeletePrefixCount([]byte(tc.prefix))
+		if n != tc.count || nr.Len() != r.Len()-n {
+			t.Fatalf("%q: got count %d and len %d, want %d", tc.prefix, n, nr.Len(), tc.count)
+		}
+	}
+
+	// Keys deleted earlier in the transaction aren't counted again.
+	txn := r.Txn()
+	txn.Delete([]byte("test/test1"))
+	if n := txn.DeletePrefixCount([]byte("test")); n != 3 {
+		t.Fatalf("bad count: %d", n)
+	}
+	if n := txn.DeletePrefixCount([]byte("test")); n != 0 {
+		t.Fatalf("bad count: %d", n)
+	}
+	if r = txn.Commit(); r.Len() != 3 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "ba", "bb", "c"}
+	build := func(keys []string) *Tree[byte, int] {
+		txn := New[byte, int]().Txn()
+		for _, k := range keys {
+			txn.Insert([]byte(k), len(k))
+		}
+		return txn.Commit()
+	}
+
+	cases := []struct {
+		start, end string
+		deleted    []string
+	}{
+		{"a", "b", []string{"a", "ab", "abc", "abd"}},
+		{"ab", "abd", []string{"ab", "abc"}},
+		{"abc", "bb", []string{"abc", "abd", "b", "ba"}},
+		{"b", "", []string{"b", "ba", "bb", "c"}},
+		{"", "", keys},
+		{"", "a", []string{""}},
+		{"abe", "az", nil},
+		{"c", "b", nil},
+		{"d", "", nil},
+	}
+	for _, tc := range cases {
+		r := build(keys)
+		r, n := r.DeleteRange([]byte(tc.start), []byte(tc.end))
+		if n != len(tc.deleted) {
+			t.Fatalf("[%q, %q): deleted %d, want %d", tc.start, tc.end, n, len(tc.deleted))
+		}
+		// The result is shaped as if it had been built from the remaining
+		// keys, so nodes were merged.
+		var rest []string
+		for _, k := range keys {
+			if !slices.Contains(tc.deleted, k) {
+				rest = append(rest, k)
+			}
+		}
+		if got, want := treeShape(r), treeShape(build(rest)); !slices.Equal(got, want) {
+			t.Fatalf("[%q, %q): got %v, want %v", tc.start, tc.end, got, want)
+		}
+	}
+
+	// Watches of deleted leaves fire, others don't.
+	r := build(keys)
+	deleted, _, _ := r.Root().GetWatch([]byte("abc"))
+	kept, _, _ := r.Root().GetWatch([]byte("abd"))
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.DeleteRange([]byte("ab"), []byte("abd"))
+	txn.Commit()
+	select {
+	case <-deleted:
+	default:
+		t.Fatalf("watch of deleted key didn't fire")
+	}
+	select {
+	case <-kept:
+		t.Fatalf("watch of kept key fired")
+	default:
+	}
+}
+
+func TestDedupeValues(t *testing.T) {
+	r := New[byte, *int]()
+	for i := 0; i < 10; i++ {
+		v := new(int)
+		*v = i % 2
+		r, _, _ = r.Insert([]byte(fmt.Sprint(i)), v)
+	}
+
+	zero := new(int)
+	deduped := r.DedupeValues(func(v *int) *int {
+		if *v == 0 {
+			return zero
+		}
+		return v
+	})
+	if deduped.Len() != r.Len() {
+		t.Fatalf("bad len: %d", deduped.Len())
+	}
+	r.Root().Walk(func(k []byte, old *int) bool {
+		v, _ := deduped.Get(k)
+		if *old == 0 && v != zero || *old != 0 && v != old {
+			t.Fatalf("%q: bad value %p for %p", k, v, old)
+		}
+		return true
+	})
+	if v, _ := r.Get([]byte("0")); v == zero {
+		t.Fatalf("original tree was modified")
+	}
+
+	// Equal results are shared even if canonical doesn't share them.
+	s := New[byte, string]()
+	for i := 0; i < 10; i++ {
+		s, _, _ = s.Insert([]byte(fmt.Sprint(i)), strings.Repeat("x", i%2+1))
+	}
+	s = s.DedupeValues(strings.Clone)
+	first := make(map[string]*byte)
+	s.Root().Walk(func(k []byte, v string) bool {
+		if p, ok := first[v]; !ok {
+			first[v] = unsafe.StringData(v)
+		} else if p != unsafe.StringData(v) {
+			t.Fatalf("%q: value %q isn't shared", k, v)
+		}
+		return true
+	})
+	if len(first) != 2 {
+		t.Fatalf("bad values: %v", first)
+	}
+}
+
+func TestCloneKeys(t *testing.T) {
+	r := New[byte, int](WithCloneKeys())
+	keys := []string{"foo", "foobar", "foobaz", "zip"}
+	for i, k := range keys {
+		key := []byte(k)
+		r, _, _ = r.Insert(key, i)
+		// Modifying an inserted key doesn't affect the tree.
+		key[0] = '!'
+	}
+
+	// Scribble over every key the tree hands out.
+	scribble := func(k []byte) {
+		for i := range k {
+			k[i] = '!'
+		}
+	}
+	it := r.Root().Iterator()
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		scribble(k)
+	}
+	r.Root().Walk(func(k []byte, _ int) bool {
+		scribble(k)
+		return true
+	})
+	r.Root().WalkBackwards(func(k []byte, _ int) bool {
+		scribble(k)
+		return true
+	})
+	k, _, _ := r.Root().Minimum()
+	scribble(k)
+	k, _, _ = r.Root().Maximum()
+	scribble(k)
+	k, _, _ = r.Root().LongestPrefix([]byte("foobarbaz"))
+	scribble(k)
+	k, _, _ = r.Root().ReverseIterator().Previous()
+	scribble(k)
+	for _, e := range r.MultiGet([][]byte{[]byte("zip")}) {
+		scribble(e.Key)
+	}
+
+	verifyTree(t, keys, r)
+	for i, k := range keys {
+		if v, ok := r.Get([]byte(k)); !ok || v != i {
+			t.Fatalf("%q: bad: %v %v", k, v, ok)
+		}
+	}
+
+	// Without the option, the stored key is returned.
+	shared := New[byte, int]()
+	shared, _, _ = shared.Insert([]byte("foo"), 0)
+	k1, _, _ := shared.Root().Minimum()
+	k2, _, _ := shared.Root().Minimum()
+	if &k1[0] != &k2[0] {
+		t.Fatalf("keys were copied")
+	}
+}
+
+func TestHasMany(t *testing.T) {
+	seedRand()
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("seed: %d", seed)
+		}
+	})
+
+	for _, n := range []int{0, 1, 10, 1000} {
+		txn := New[byte, int]().Txn()
+		for i := 0; i < n; i++ {
+			txn.Insert([]byte(randomHexString(rng.Intn(5))), i)
+		}
+		r := txn.Commit()
+
+		// Query stored keys, their prefixes and extensions, and duplicates,
+		// in random order.
+		var keys [][]byte
+		for i := 0; i < 2*n+10; i++ {
+			keys = append(keys, []byte(randomHexString(rng.Intn(6))))
+		}
+		keys = append(keys, nil, keys[0])
+		got := r.HasMany(keys)
+		if len(got) != len(keys) {
+			t.Fatalf("bad len: %d", len(got))
+		}
+		for i, k := range keys {
+			if got[i] != r.Has(k) {
+				t.Fatalf("n=%d: %q: got %v", n, k, got[i])
+			}
+		}
+	}
+}
+
 func TestTrackMutate_DeletePrefix(t *testing.T) {
 
 	r := New[byte, any]()
@@ -518,6 +981,55 @@ func TestLongestPrefix(t *testing.T) {
 	}
 }
 
+func TestShortestPrefix(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{
+		"foo",
+		"foobar",
+		"foobarbaz",
+		"foozip",
+		"zip",
+	}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	type exp struct {
+		inp string
+		out string
+		ok  bool
+	}
+	cases := []exp{
+		{"", "", false},
+		{"fo", "", false},
+		{"foo", "foo", true},
+		{"foobarbaz", "foo", true},
+		{"foozipzap", "foo", true},
+		{"zip", "zip", true},
+		{"zipzap", "zip", true},
+		{"zi", "", false},
+		{"bar", "", false},
+	}
+	for _, test := range cases {
+		m, v, ok := r.ShortestPrefix([]byte(test.inp))
+		if ok != test.ok || string(m) != test.out {
+			t.Fatalf("mis-match: %q %v %v", m, ok, test)
+		}
+		if ok && keys[v] != test.out {
+			t.Fatalf("bad value: %d %v", v, test)
+		}
+	}
+
+	// The empty key is a prefix of everything.
+	r, _, _ = r.Insert(nil, 100)
+	for _, k := range []string{"", "foo", "x"} {
+		m, v, ok := r.Root().ShortestPrefix([]byte(k))
+		if !ok || len(m) != 0 || v != 100 {
+			t.Fatalf("%q: expected the empty key, got %q %v %v", k, m, v, ok)
+		}
+	}
+}
+
 func TestWalkPrefix(t *testing.T) {
 	r := New[byte, any]()
 
@@ -582,19 +1094,1010 @@ func TestWalkPrefix(t *testing.T) {
 		},
 	}
 
-	root := r.Root()
-	for _, test := range cases {
-		var out []string
-		fn := func(k []byte, _ interface{}) bool {
-			out = append(out, string(k))
-			return true
+	root := r.Root()
+	for _, test := range cases {
+		var out []string
+		fn := func(k []byte, _ interface{}) bool {
+			out = append(out, string(k))
+			return true
+		}
+		root.WalkPrefix([]byte(test.inp), fn)
+		sort.Strings(out)
+		sort.Strings(test.out)
+		if !slices.Equal(out, test.out) {
+			t.Fatalf("mis-match: %v %v", out, test.out)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	type user struct {
+		id, email string
+	}
+	byID := func(u user) []byte { return []byte(u.id) }
+	byEmail := func(u user) []byte { return []byte(u.email) }
+	users := []user{
+		{"3", "alice@example.com"},
+		{"1", "bob@example.com"},
+		{"2", "carol@example.com"},
+	}
+
+	txn := New[byte, user]().Txn()
+	for _, u := range users {
+		if _, ok := txn.Index(u, byID); ok {
+			t.Fatalf("unexpected update for %v", u)
+		}
+	}
+	updated := user{"1", "bob@example.org"}
+	if old, ok := txn.Index(updated, byID); !ok || old != users[1] {
+		t.Fatalf("bad: %v %v", old, ok)
+	}
+	r := txn.Commit()
+
+	var ids []string
+	r.Root().Walk(func(k []byte, u user) bool {
+		if string(k) != u.id {
+			t.Fatalf("key %q doesn't match id %q", k, u.id)
+		}
+		ids = append(ids, u.id)
+		return true
+	})
+	if !slices.Equal(ids, []string{"1", "2", "3"}) {
+		t.Fatalf("bad ids: %v", ids)
+	}
+
+	txn = r.Txn()
+	txn.Reindex(byEmail)
+	r2 := txn.Commit()
+	var emails []string
+	r2.Root().Walk(func(k []byte, u user) bool {
+		if string(k) != u.email {
+			t.Fatalf("key %q doesn't match email %q", k, u.email)
+		}
+		emails = append(emails, string(k))
+		return true
+	})
+	if !slices.Equal(emails, []string{"alice@example.com", "bob@example.org", "carol@example.com"}) {
+		t.Fatalf("bad emails: %v", emails)
+	}
+	if r2.Len() != 3 {
+		t.Fatalf("bad len: %d", r2.Len())
+	}
+
+	// The original tree is untouched.
+	if _, ok := r.Get([]byte("1")); !ok || r.Len() != 3 {
+		t.Fatalf("original tree was modified")
+	}
+
+	// Values written by the same transaction are reindexed too.
+	txn = r.Txn()
+	txn.Index(user{"4", "dave@example.net"}, byID)
+	txn.Reindex(byEmail)
+	r3 := txn.Commit()
+	if _, ok := r3.Get([]byte("dave@example.net")); !ok || r3.Len() != 4 {
+		t.Fatalf("bad reindex after a write: %d", r3.Len())
+	}
+}
+
+func TestGetOrInsert(t *testing.T) {
+	r := New[byte, int]()
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	watch, _, _ := r.Root().GetWatch([]byte("foo"))
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	if actual, loaded := txn.GetOrInsert([]byte("foo"), 2); !loaded || actual != 1 {
+		t.Fatalf("bad: %v %v", actual, loaded)
+	}
+	if txn.Root() != r.Root() || txn.NodesCopied() != 0 {
+		t.Fatalf("existing key was written")
+	}
+	if actual, loaded := txn.GetOrInsert([]byte("foobar"), 3); loaded || actual != 3 {
+		t.Fatalf("bad: %v %v", actual, loaded)
+	}
+	if actual, loaded := txn.GetOrInsert([]byte("foobar"), 4); !loaded || actual != 3 {
+		t.Fatalf("bad: %v %v", actual, loaded)
+	}
+	r = txn.Commit()
+
+	if r.Len() != 2 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	if v, _ := r.Get([]byte("foobar")); v != 3 {
+		t.Fatalf("bad: %v", v)
+	}
+	// Only the nodes on the path of the new key were notified.
+	select {
+	case <-watch:
+		t.Fatalf("loaded key was notified")
+	default:
+	}
+}
+
+func TestMinimumMaximum(t *testing.T) {
+	r := New[byte, int]()
+	if _, _, ok := r.Root().Maximum(); ok {
+		t.Fatalf("empty tree has a maximum")
+	}
+
+	// Every key but the last is stored on a node with edges.
+	keys := []string{"", "a", "ab", "abc", "abd", "abdz", "b", "ba"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	if k, v, ok := r.Root().Maximum(); string(k) != "ba" || v != 7 || !ok {
+		t.Fatalf("bad maximum: %q %v %v", k, v, ok)
+	}
+	if k, v, ok := r.Root().Minimum(); string(k) != "" || v != 0 || !ok {
+		t.Fatalf("bad minimum: %q %v %v", k, v, ok)
+	}
+
+	// The extremes of every subtree match its first and last key.
+	for iter := r.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		n := iter.Front()
+		var walked [][]byte
+		n.Walk(func(k []byte, _ int) bool {
+			walked = append(walked, k)
+			return true
+		})
+		if k, _, _ := n.Minimum(); !bytes.Equal(k, walked[0]) {
+			t.Fatalf("%q: bad minimum: %q", iter.Path(), k)
+		}
+		if k, _, _ := n.Maximum(); !bytes.Equal(k, walked[len(walked)-1]) {
+			t.Fatalf("%q: bad maximum: %q", iter.Path(), k)
+		}
+	}
+
+	// Deleting the maximum moves it up to an internal leaf.
+	r, _, _ = r.Delete([]byte("ba"))
+	if k, _, _ := r.Root().Maximum(); string(k) != "b" {
+		t.Fatalf("bad maximum: %q", k)
+	}
+	r, _, _ = r.Delete([]byte("b"))
+	if k, _, _ := r.Root().Maximum(); string(k) != "abdz" {
+		t.Fatalf("bad maximum: %q", k)
+	}
+}
+
+func TestTxnAbort(t *testing.T) {
+	r := New[byte, int]()
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	watch, _, _ := r.Root().GetWatch([]byte("foo"))
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("foo"), 2)
+	txn.Insert([]byte("bar"), 3)
+	txn.Abort()
+	txn.Abort()
+	if txn.writable != nil || txn.trackChannels != nil {
+		t.Fatalf("resources weren't released")
+	}
+
+	// Reads see the original tree, which is untouched.
+	if v, _ := txn.Get([]byte("foo")); v != 1 || txn.Root() != r.Root() || txn.Dirty() {
+		t.Fatalf("aborted writes are visible")
+	}
+	if v, _ := r.Get([]byte("foo")); v != 1 || r.Len() != 1 {
+		t.Fatalf("original tree was modified")
+	}
+
+	for name, fn := range map[string]func(){
+		"Insert":       func() { txn.Insert([]byte("baz"), 4) },
+		"Delete":       func() { txn.Delete([]byte("foo")) },
+		"DeletePrefix": func() { txn.DeletePrefix(nil) },
+		"InsertBatch":  func() { txn.InsertBatch([]Entry[byte, int]{{Key: []byte("baz")}}) },
+		"Commit":       func() { txn.Commit() },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "aborted") {
+					t.Fatalf("%s: expected a panic, got %v", name, r)
+				}
+			}()
+			fn()
+		}()
+	}
+
+	txn.Notify()
+	select {
+	case <-watch:
+		t.Fatalf("aborted transaction notified")
+	default:
+	}
+}
+
+func TestTxnSavepoint(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"foo", "foobar", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	fooWatch, _, _ := r.Root().GetWatch([]byte("foo"))
+	zipWatch, _, _ := r.Root().GetWatch([]byte("zip"))
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("foo"), 10)
+	outer := txn.Savepoint()
+
+	// Writes after the savepoint must not touch the nodes it saved, even
+	// those the transaction already wrote.
+	txn.Insert([]byte("foo"), 20)
+	txn.Insert([]byte("foobaz"), 21)
+	txn.Delete([]byte("zip"))
+	inner := txn.Savepoint()
+	txn.DeletePrefix([]byte("foo"))
+	if txn.size != 0 {
+		t.Fatalf("bad len: %d", txn.size)
+	}
+
+	txn.Rollback(inner)
+	if v, _ := txn.Get([]byte("foo")); v != 20 || txn.size != 3 {
+		t.Fatalf("bad inner rollback: %d %d", v, txn.size)
+	}
+	txn.Rollback(outer)
+	if v, _ := txn.Get([]byte("foo")); v != 10 || txn.size != 3 {
+		t.Fatalf("bad outer rollback: %d %d", v, txn.size)
+	}
+	if _, ok := txn.Get([]byte("foobaz")); ok {
+		t.Fatalf("rolled back insert is visible")
+	}
+
+	// Savepoints taken after the one rolled back to stay valid.
+	txn.Insert([]byte("foo"), 30)
+	txn.Rollback(inner)
+	if v, _ := txn.Get([]byte("foobaz")); v != 21 || txn.size != 3 {
+		t.Fatalf("bad inner rollback: %d %d", v, txn.size)
+	}
+	txn.Rollback(outer)
+
+	// Only the writes up to the savepoint are notified.
+	r2 := txn.Commit()
+	if v, _ := r2.Get([]byte("foo")); v != 10 || r2.Len() != 3 {
+		t.Fatalf("bad commit: %d %d", v, r2.Len())
+	}
+	select {
+	case <-fooWatch:
+	default:
+		t.Fatalf("foo wasn't notified")
+	}
+	select {
+	case <-zipWatch:
+		t.Fatalf("rolled back delete was notified")
+	default:
+	}
+	if v, _ := r.Get([]byte("foo")); v != 0 || r.Len() != 3 {
+		t.Fatalf("original tree was modified")
+	}
+
+	// The committed tree can be written again without a double close.
+	txn = r2.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("foo"), 40)
+	txn.Commit()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	r.Txn().Rollback(outer)
+}
+
+func TestClear(t *testing.T) {
+	r := New[byte, int](
+		WithComparator(func(a, b byte) int { return int(b) - int(a) }),
+		WithAggregator(0, func(sum, v int) int { return sum + v }, func(a, b int) int { return a + b }),
+	)
+	keys := []string{"", "foo", "foobar", "zip"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i+1)
+	}
+	var watches []<-chan struct{}
+	for _, k := range keys {
+		watch, _, _ := r.Root().GetWatch([]byte(k))
+		watches = append(watches, watch)
+	}
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("zap"), 5)
+	txn.Clear()
+	if txn.size != 0 || txn.Root().Aggregate() != 0 || len(txn.Root().edges) != 0 || txn.Root().leaf != nil {
+		t.Fatalf("transaction wasn't cleared")
+	}
+	txn.Insert([]byte("a"), 1)
+	txn.Insert([]byte("b"), 2)
+	r2 := txn.Commit()
+	for i, watch := range watches {
+		select {
+		case <-watch:
+		default:
+			t.Fatalf("%q wasn't notified", keys[i])
+		}
+	}
+	if hasAnyClosedMutateCh(r2) {
+		t.Fatalf("closed channel in the new tree")
+	}
+	// The comparator is kept, so b comes first.
+	verifyTree(t, []string{"b", "a"}, r2)
+	if r2.Len() != 2 || r2.Root().Aggregate() != 3 {
+		t.Fatalf("bad rebuilt tree: %d %v", r2.Len(), r2.Root().Aggregate())
+	}
+	if r.Len() != 4 {
+		t.Fatalf("original tree was modified")
+	}
+
+	empty := r.Clear()
+	if empty.Len() != 0 || empty.Root().Aggregate() != 0 {
+		t.Fatalf("tree wasn't cleared")
+	}
+	for _, k := range []string{"a", "b"} {
+		empty, _, _ = empty.Insert([]byte(k), 1)
+	}
+	verifyTree(t, []string{"b", "a"}, empty)
+	if empty.Root().Aggregate() != 2 {
+		t.Fatalf("aggregator wasn't kept")
+	}
+}
+
+func TestTxnDirty(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"foo", "foobar"} {
+		r, _, _ = r.Insert([]byte(k), i+1)
+	}
+
+	txn := r.Txn()
+	txn.InsertIfAbsent([]byte("foo"), 100)
+	txn.GetOrInsert([]byte("foobar"), 100)
+	txn.Update([]byte("zip"), 100)
+	txn.Delete([]byte("fo"))
+	txn.DeletePrefix([]byte("zip"))
+	if txn.Dirty() {
+		t.Fatalf("no-op writes made the transaction dirty")
+	}
+
+	txn.Insert([]byte("zip"), 3)
+	if !txn.Dirty() {
+		t.Fatalf("insert didn't make the transaction dirty")
+	}
+	r2 := txn.Commit()
+
+	// Deleting what was inserted restores the content, but not the root.
+	txn = r2.Txn()
+	txn.Delete([]byte("zip"))
+	if !txn.Dirty() {
+		t.Fatalf("delete didn't make the transaction dirty")
+	}
+}
+
+func TestInsertIfAbsentAndUpdate(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"foo", "foobar"} {
+		r, _, _ = r.Insert([]byte(k), i+1)
+	}
+
+	txn := r.Txn()
+	// Present keys aren't overwritten.
+	if old, ok := txn.InsertIfAbsent([]byte("foo"), 100); ok || old != 1 {
+		t.Fatalf("bad: %v %v", old, ok)
+	}
+	// Absent keys aren't inserted, neither below an existing node, nor
+	// where an edge would be split, nor where a new edge would be added.
+	for _, k := range []string{"foobarbaz", "fo", "zip", ""} {
+		if old, ok := txn.Update([]byte(k), 100); ok || old != 0 {
+			t.Fatalf("%q: bad: %v %v", k, old, ok)
+		}
+	}
+	if txn.Root() != r.Root() || txn.NodesCopied() != 0 {
+		t.Fatalf("tree was written")
+	}
+
+	if old, ok := txn.Update([]byte("foobar"), 20); !ok || old != 2 {
+		t.Fatalf("bad: %v %v", old, ok)
+	}
+	for _, k := range []string{"fo", "zip"} {
+		if old, ok := txn.InsertIfAbsent([]byte(k), 30); !ok || old != 0 {
+			t.Fatalf("%q: bad: %v %v", k, old, ok)
+		}
+	}
+	r = txn.Commit()
+
+	expected := map[string]int{"fo": 30, "foo": 1, "foobar": 20, "zip": 30}
+	if r.Len() != len(expected) {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	for k, v := range expected {
+		if got, _ := r.Get([]byte(k)); got != v {
+			t.Fatalf("bad value for %q: %d", k, got)
+		}
+	}
+	checkSubtreeCounts(t, r)
+}
+
+func TestGetPtr(t *testing.T) {
+	r := New[byte, int]()
+	r, _, _ = r.Insert([]byte("zero"), 0)
+	r, _, _ = r.Insert([]byte("one"), 1)
+
+	if p := r.GetPtr([]byte("zero")); p == nil || *p != 0 {
+		t.Fatalf("expected pointer to stored zero value, got %v", p)
+	}
+	if p := r.GetPtr([]byte("one")); p == nil || *p != 1 {
+		t.Fatalf("bad: %v", p)
+	}
+	if p := r.GetPtr([]byte("nope")); p != nil {
+		t.Fatalf("expected nil for missing key, got %v", *p)
+	}
+	if p := r.GetPtr([]byte("zer")); p != nil {
+		t.Fatalf("expected nil for missing key, got %v", *p)
+	}
+
+	// The pointer refers to the immutable stored value, so it is stable
+	// across lookups.
+	if r.GetPtr([]byte("one")) != r.GetPtr([]byte("one")) {
+		t.Fatalf("expected the same pointer")
+	}
+}
+
+func TestPreCommitHook(t *testing.T) {
+	r := New[byte, int]()
+	r, _, _ = r.Insert([]byte("quota/a"), 1)
+
+	// Total under "quota/" must not exceed 10.
+	errQuota := fmt.Errorf("quota exceeded")
+	hook := func(root *Node[byte, int]) error {
+		sum := 0
+		root.WalkPrefix([]byte("quota/"), func(_ []byte, v int) bool {
+			sum += v
+			return true
+		})
+		if sum > 10 {
+			return errQuota
+		}
+		return nil
+	}
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.SetPreCommitHook(hook)
+	watch, _, _ := r.Root().GetWatch([]byte("quota/a"))
+	txn.Insert([]byte("quota/a"), 5)
+	txn.Insert([]byte("quota/b"), 5)
+	nr, err := txn.CommitChecked()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if nr.Len() != 2 {
+		t.Fatalf("bad len: %d", nr.Len())
+	}
+	select {
+	case <-watch:
+	default:
+		t.Fatalf("watch was not triggered")
+	}
+
+	txn = nr.Txn()
+	txn.TrackMutate(true)
+	txn.SetPreCommitHook(hook)
+	watch, _, _ = nr.Root().GetWatch([]byte("quota/a"))
+	txn.Insert([]byte("quota/c"), 1)
+	failed, err := txn.CommitChecked()
+	if err != errQuota {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if failed != nil {
+		t.Fatalf("expected no tree on failed commit")
+	}
+	select {
+	case <-watch:
+		t.Fatalf("watch was triggered by an aborted commit")
+	default:
+	}
+
+	// The transaction can be fixed up and committed.
+	txn.Delete([]byte("quota/a"))
+	nr, err = txn.CommitChecked()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	verifyTree(t, []string{"quota/b", "quota/c"}, nr)
+
+	// Plain Commit refuses to bypass the hook.
+	txn = nr.Txn()
+	txn.SetPreCommitHook(hook)
+	txn.Insert([]byte("quota/d"), 100)
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected Commit to panic")
+		}
+	}()
+	txn.Commit()
+}
+
+func TestMultiGet(t *testing.T) {
+	r := New[byte, int]()
+	r, _, _ = r.Insert([]byte("a"), 1)
+	r, _, _ = r.Insert([]byte("b"), 2)
+	r, _, _ = r.Insert([]byte("c"), 3)
+
+	out := r.MultiGet([][]byte{[]byte("c"), []byte("nope"), []byte("a")})
+	expected := []Entry[byte, int]{
+		{Key: []byte("c"), Value: 3},
+		{Key: []byte("a"), Value: 1},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("bad: %v", out)
+	}
+	if out := r.MultiGet(nil); len(out) != 0 {
+		t.Fatalf("bad: %v", out)
+	}
+}
+
+func TestMultiGet_Consistent(t *testing.T) {
+	// The writer moves units between two keys, so their sum is always 100 in
+	// any committed tree.
+	r := New[byte, int]()
+	r, _, _ = r.Insert([]byte("from"), 100)
+	r, _, _ = r.Insert([]byte("to"), 0)
+	var current atomic.Pointer[Tree[byte, int]]
+	current.Store(r)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 1000; i++ {
+			txn := current.Load().Txn()
+			txn.Insert([]byte("from"), 100-i%100)
+			txn.Insert([]byte("to"), i%100)
+			current.Store(txn.Commit())
+		}
+	}()
+
+	keys := [][]byte{[]byte("from"), []byte("to")}
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		out := current.Load().MultiGet(keys)
+		if len(out) != 2 || out[0].Value+out[1].Value != 100 {
+			t.Fatalf("torn read: %v", out)
+		}
+	}
+}
+
+func TestMinMaxByValue(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	if _, ok := MaxByValue(New[byte, int](), less); ok {
+		t.Fatalf("expected no entry in an empty tree")
+	}
+	if _, ok := MinByValue(New[byte, int](), less); ok {
+		t.Fatalf("expected no entry in an empty tree")
+	}
+
+	r := New[byte, int]()
+	for k, v := range map[string]int{"a": 5, "b": 9, "c": 1, "d": 7} {
+		r, _, _ = r.Insert([]byte(k), v)
+	}
+	if e, ok := MaxByValue(r, less); !ok || string(e.Key) != "b" || e.Value != 9 {
+		t.Fatalf("bad max: %q %v", e.Key, e.Value)
+	}
+	if e, ok := MinByValue(r, less); !ok || string(e.Key) != "c" || e.Value != 1 {
+		t.Fatalf("bad min: %q %v", e.Key, e.Value)
+	}
+
+	// Ties go to the smallest key.
+	for k, v := range map[string]int{"ab": 9, "e": 9, "": 1, "cc": 1} {
+		r, _, _ = r.Insert([]byte(k), v)
+	}
+	if e, _ := MaxByValue(r, less); string(e.Key) != "ab" {
+		t.Fatalf("bad max: %q", e.Key)
+	}
+	if e, _ := MinByValue(r, less); string(e.Key) != "" || e.Value != 1 {
+		t.Fatalf("bad min: %q", e.Key)
+	}
+}
+
+func TestWalkPrefixRange(t *testing.T) {
+	r := New[byte, any]()
+	keys := []string{
+		"",
+		"a",
+		"a/1",
+		"ab",
+		"b",
+		"b/1",
+		"l",
+		"l/zzz",
+		"m",
+		"m/1",
+		"ma",
+		"z",
+	}
+	for _, k := range keys {
+		r, _, _ = r.Insert([]byte(k), nil)
+	}
+
+	cases := []struct {
+		lo, hi string
+		out    []string
+	}{
+		{"a", "m", []string{"a", "a/1", "ab", "b", "b/1", "l", "l/zzz"}},
+		{"a/", "b", []string{"a/1", "ab"}},
+		{"b", "b", nil},
+		{"b", "b/1", []string{"b"}},
+		{"m", "ma", []string{"m", "m/1"}},
+		{"", "a", []string{""}},
+		{"y", "zz", []string{"z"}},
+		{"zz", "zzz", nil},
+	}
+	for _, tc := range cases {
+		var out []string
+		r.WalkPrefixRange([]byte(tc.lo), []byte(tc.hi), func(k []byte, _ any) bool {
+			out = append(out, string(k))
+			return true
+		})
+		if !slices.Equal(out, tc.out) {
+			t.Fatalf("[%q, %q): got %v, want %v", tc.lo, tc.hi, out, tc.out)
+		}
+	}
+}
+
+func TestInsertCost(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"foo", "foobar", "foobaz", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := map[string]int{
+		"":          1, // the root
+		"a":         1, // new edge on the root
+		"foo":       2, // update
+		"foob":      3, // splits "ba"
+		"fo":        2, // splits "foo"
+		"foobarbaz": 4, // new edge on the "r" leaf
+		"foobax":    3, // new edge on the "ba" node
+		"fox":       2, // splits "foo"
+		"zap":       2, // splits "zip"
+	}
+	for k, expected := range cases {
+		if cost := r.InsertCost([]byte(k)); cost != expected {
+			t.Fatalf("%q: got cost %d, want %d", k, cost, expected)
+		}
+		txn := r.Txn()
+		txn.Insert([]byte(k), 100)
+		if copied := txn.NodesCopied(); copied != expected {
+			t.Fatalf("%q: insert copied %d nodes, estimated %d", k, copied, expected)
+		}
+	}
+
+	// Writable nodes aren't copied again.
+	txn := r.Txn()
+	txn.Insert([]byte("foobar"), 1)
+	txn.Insert([]byte("foobaz"), 1)
+	if copied := txn.NodesCopied(); copied != 5 {
+		t.Fatalf("bad: %d", copied)
+	}
+}
+
+func TestLastNBefore(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"a", "a/1", "ab", "b", "b/1", "c"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		key string
+		n   int
+		out []string
+	}{
+		{"b", 2, []string{"ab", "a/1"}},
+		{"b/0", 2, []string{"b", "ab"}},
+		{"c", 10, []string{"b/1", "b", "ab", "a/1", "a"}},
+		{"z", 1, []string{"c"}},
+		{"a", 3, nil},
+		{"", 3, nil},
+		{"c", 0, nil},
+	}
+	for _, tc := range cases {
+		var out []string
+		for _, e := range r.LastNBefore([]byte(tc.key), tc.n) {
+			if e.Value != slices.Index(keys, string(e.Key)) {
+				t.Fatalf("bad value for %q: %d", e.Key, e.Value)
+			}
+			out = append(out, string(e.Key))
+		}
+		if !slices.Equal(out, tc.out) {
+			t.Fatalf("%d before %q: got %v, want %v", tc.n, tc.key, out, tc.out)
+		}
+	}
+}
+
+func TestDistinctAt(t *testing.T) {
+	r := New[byte, any]()
+	if n := r.DistinctAt(0); n != 0 {
+		t.Fatalf("expected 0 for empty tree, got %d", n)
+	}
+	for _, k := range []string{"a", "ab", "abc", "abd", "ac", "b", "ba", "bcd", "c", "dddd"} {
+		r, _, _ = r.Insert([]byte(k), nil)
+	}
+
+	for length, expected := range []int{1, 4, 5, 4, 1, 0} {
+		distinct := make(map[string]struct{})
+		r.Root().Walk(func(k []byte, _ any) bool {
+			if len(k) >= length {
+				distinct[string(k[:length])] = struct{}{}
+			}
+			return true
+		})
+		if len(distinct) != expected {
+			t.Fatalf("bad brute force count for %d: %d", length, len(distinct))
+		}
+		if n := r.DistinctAt(length); n != expected {
+			t.Fatalf("length %d: got %d, want %d", length, n, expected)
+		}
+	}
+}
+
+func TestWalkExcluding(t *testing.T) {
+	r := New[byte, int]()
+	var keys []string
+	for i := 0; i < 500; i++ {
+		k := fmt.Sprintf("%x", i*7919%1000)
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	r, _, _ = r.Insert(nil, -1)
+	r.Root().Walk(func(k []byte, _ int) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+
+	cases := [][]string{
+		nil,
+		{"1"},
+		{"1", "12", "123"},          // nested
+		{"2a", "2", "3e", "3e8"},    // overlapping, in any order
+		{"1f", "zzz", "10", "1f4x"}, // partly matching nothing
+		{"12", "1"},
+		{""},
+	}
+	for _, exclude := range cases {
+		var excl [][]byte
+		for _, p := range exclude {
+			excl = append(excl, []byte(p))
+		}
+		var expected, visited []string
+		for _, k := range keys {
+			if !slices.ContainsFunc(exclude, func(p string) bool { return strings.HasPrefix(k, p) }) {
+				expected = append(expected, k)
+			}
+		}
+		r.WalkExcluding(excl, func(k []byte, _ int) bool {
+			visited = append(visited, string(k))
+			return true
+		})
+		if !slices.Equal(visited, expected) {
+			t.Fatalf("%q: got %v, want %v", exclude, visited, expected)
+		}
+	}
+
+	// Stopping the walk.
+	n := 0
+	r.WalkExcluding([][]byte{[]byte("1")}, func([]byte, int) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Fatalf("bad: %d", n)
+	}
+}
+
+func TestWalkStride(t *testing.T) {
+	r := New[byte, int]()
+	var keys []string
+	for i := 0; i < 200; i++ {
+		k := fmt.Sprintf("%x", i*7919%1000)
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	r.Root().Walk(func(k []byte, _ int) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+
+	for _, stride := range []int{-1, 0, 1, 2, 3, 7, 64, 199, 200, 1000} {
+		var expected, visited []string
+		for i := 0; i < len(keys); i += max(stride, 1) {
+			expected = append(expected, keys[i])
+		}
+		r.WalkStride(stride, func(k []byte, _ int) bool {
+			visited = append(visited, string(k))
+			return true
+		})
+		if !reflect.DeepEqual(visited, expected) {
+			t.Fatalf("stride %d: got %v, want %v", stride, visited, expected)
+		}
+	}
+
+	// Stopping the walk.
+	n := 0
+	r.WalkStride(3, func([]byte, int) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Fatalf("bad: %d", n)
+	}
+	New[byte, int]().WalkStride(2, func([]byte, int) bool {
+		t.Fatalf("unexpected visit")
+		return true
+	})
+}
+
+func TestWalkWithSizes(t *testing.T) {
+	type node struct {
+		path   string
+		isLeaf bool
+		size   int
+	}
+	expected := []node{
+		{"", false, 5},
+		{"fo", false, 4},
+		{"foo", true, 3},
+		{"fooba", false, 2},
+		{"foobar", true, 1},
+		{"foobaz", true, 1},
+		{"fozz", true, 1},
+		{"zip", true, 1},
+	}
+	r := New[byte, int]()
+	for i, k := range []string{"foo", "foobar", "foobaz", "fozz", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	var out []node
+	r.WalkWithSizes(func(prefix []byte, isLeaf bool, size int) bool {
+		out = append(out, node{string(prefix), isLeaf, size})
+		return true
+	})
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("bad walk:\n got: %v\nwant: %v", out, expected)
+	}
+}
+
+// countSubtrees records the number of leaves under every node of the subtree
+// rooted at n into sizes. Returns the count for n.
+func countSubtrees[K keyT, T any](n *Node[K, T], sizes map[*Node[K, T]]int) int {
+	size := 0
+	if n.isLeaf() {
+		size = 1
+	}
+	for _, e := range n.edges {
+		size += countSubtrees(e.node, sizes)
+	}
+	sizes[n] = size
+	return size
+}
+
+// checkSubtreeCounts verifies that every node's size matches the number of
+// leaves under it.
+func checkSubtreeCounts[K keyT, T any](t *testing.T, r *Tree[K, T]) {
+	t.Helper()
+	sizes := make(map[*Node[K, T]]int)
+	if countSubtrees(r.root, sizes) != r.Len() {
+		t.Fatalf("tree length mismatch")
+	}
+	for iter := r.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		n := iter.Front()
+		if n.size != sizes[n] {
+			t.Fatalf("bad size for %v: %d, want %d", iter.Path(), n.size, sizes[n])
+		}
+	}
+}
+
+func TestSubtreeCounts(t *testing.T) {
+	seedRand()
+	r := New[byte, int]()
+	var keys []string
+	for i := 0; i < 500; i++ {
+		// Short keys over a tiny alphabet produce lots of splits and merges.
+		k := randomHexString(2)[:rng.Intn(5)]
+		keys = append(keys, k)
+	}
+
+	txn := r.Txn()
+	for i, k := range keys {
+		txn.Insert([]byte(k), i)
+	}
+	r = txn.Commit()
+	checkSubtreeCounts(t, r)
+
+	for i := 0; i < 10; i++ {
+		txn = r.Txn()
+		for j := 0; j < 30; j++ {
+			k := []byte(keys[rng.Intn(len(keys))])
+			switch rng.Intn(3) {
+			case 0:
+				txn.Insert(k, j)
+			case 1:
+				txn.Delete(k)
+			default:
+				txn.DeletePrefix(k[:len(k)/2+len(k)%2])
+			}
+		}
+		r = txn.Commit()
+		checkSubtreeCounts(t, r)
+	}
+}
+
+func TestCountPrefix(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"", "foo", "foobar", "foobaz", "fozz", "zip", "zipzap"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	r, _, _ = r.Delete([]byte("zip"))
+	r, _ = r.DeletePrefix([]byte("fooba"))
+	keys = []string{"", "foo", "fozz", "zipzap"}
+
+	for _, prefix := range []string{"", "f", "fo", "foo", "foob", "fozz", "fozzz", "z", "zipz", "x"} {
+		expected := 0
+		for _, k := range keys {
+			if bytes.HasPrefix([]byte(k), []byte(prefix)) {
+				expected++
+			}
 		}
-		root.WalkPrefix([]byte(test.inp), fn)
-		sort.Strings(out)
-		sort.Strings(test.out)
-		if !slices.Equal(out, test.out) {
-			t.Fatalf("mis-match: %v %v", out, test.out)
+		if n := r.CountPrefix([]byte(prefix)); n != expected {
+			t.Fatalf("prefix %q: got %d, want %d", prefix, n, expected)
 		}
+		if n := r.Root().CountPrefix([]byte(prefix)); n != expected {
+			t.Fatalf("prefix %q: got %d from the root, want %d", prefix, n, expected)
+		}
+	}
+}
+
+func TestWalkTree(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"foo", "foobar", "foobaz", "fozz", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i+1)
+	}
+
+	type node struct {
+		prefix string
+		isLeaf bool
+		val    int
+		isLast bool
+		depth  int
+	}
+	expected := []node{
+		{"", false, 0, true, 0},
+		{"fo", false, 0, false, 1},
+		{"o", true, 1, false, 2},
+		{"ba", false, 0, true, 3},
+		{"r", true, 2, false, 4},
+		{"z", true, 3, true, 4},
+		{"zz", true, 4, true, 2},
+		{"zip", true, 5, true, 1},
+	}
+	var out []node
+	r.WalkTree(func(prefix []byte, isLeaf bool, val int, isLastChild bool, depth int) bool {
+		out = append(out, node{string(prefix), isLeaf, val, isLastChild, depth})
+		return true
+	})
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("bad walk:\n got: %v\nwant: %v", out, expected)
+	}
+
+	// Early termination.
+	out = nil
+	r.WalkTree(func(prefix []byte, isLeaf bool, val int, isLastChild bool, depth int) bool {
+		out = append(out, node{string(prefix), isLeaf, val, isLastChild, depth})
+		return len(out) < 3
+	})
+	if !reflect.DeepEqual(out, expected[:3]) {
+		t.Fatalf("bad walk:\n got: %v\nwant: %v", out, expected[:3])
 	}
 }
 
@@ -1024,6 +2527,138 @@ func TestTrackMutate_SeekPrefixWatch(t *testing.T) {
 	}
 }
 
+func TestKeysValues(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCloneKeys()}} {
+		r := New[byte, int](opts...)
+		if keys, values := r.Keys(), r.Values(); len(keys) != 0 || len(values) != 0 {
+			t.Fatalf("bad empty tree: %v %v", keys, values)
+		}
+		for i, k := range []string{"foo", "", "foobar", "bar"} {
+			r, _, _ = r.Insert([]byte(k), i)
+		}
+
+		keys := r.Keys()
+		var got []string
+		for _, k := range keys {
+			got = append(got, string(k))
+		}
+		if !reflect.DeepEqual(got, []string{"", "bar", "foo", "foobar"}) {
+			t.Fatalf("bad keys: %q", got)
+		}
+		if values := r.Values(); !reflect.DeepEqual(values, []int{1, 3, 0, 2}) {
+			t.Fatalf("bad values: %v", values)
+		}
+
+		// Modifying the keys doesn't affect the tree.
+		for _, k := range keys {
+			for i := range k {
+				k[i] = 'x'
+			}
+		}
+		if _, ok := r.Get([]byte("foobar")); !ok {
+			t.Fatalf("tree was modified through its keys")
+		}
+	}
+}
+
+func TestWatchPrefix(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"foo/a", "foo/b", "foobar", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	write := func(fn func(txn *Txn[byte, int])) {
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		fn(txn)
+		r = txn.Commit()
+	}
+	fired := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// Changes elsewhere don't fire.
+	watch := r.WatchPrefix([]byte("foo/"))
+	write(func(txn *Txn[byte, int]) {
+		txn.Insert([]byte("foobar"), 10)
+		txn.Delete([]byte("zip"))
+	})
+	if fired(watch) {
+		t.Fatalf("fired for other keys")
+	}
+
+	// Updates, inserts and deletes under the prefix all fire, and
+	// subscribing again after each keeps watching.
+	for _, fn := range []func(txn *Txn[byte, int]){
+		func(txn *Txn[byte, int]) { txn.Insert([]byte("foo/a"), 10) },
+		func(txn *Txn[byte, int]) { txn.Insert([]byte("foo/c"), 11) },
+		func(txn *Txn[byte, int]) { txn.Delete([]byte("foo/b")) },
+		func(txn *Txn[byte, int]) { txn.DeletePrefix([]byte("foo/")) },
+	} {
+		watch = r.WatchPrefix([]byte("foo/"))
+		write(fn)
+		if !fired(watch) {
+			t.Fatalf("didn't fire")
+		}
+	}
+
+	// Watching an absent prefix fires once a key is inserted under it.
+	watch = r.WatchPrefix([]byte("foo/"))
+	write(func(txn *Txn[byte, int]) { txn.Insert([]byte("foo/x"), 1) })
+	if !fired(watch) {
+		t.Fatalf("didn't fire for a new key")
+	}
+}
+
+func TestTrackMutateLeavesOnly(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		r := New[byte, int]()
+		for j, k := range []string{"foo", "foo/a", "zip", "zap"} {
+			r, _, _ = r.Insert([]byte(k), j)
+		}
+		fooWatch, _, _ := r.Root().GetWatch([]byte("foo"))
+		zipWatch, _, _ := r.Root().GetWatch([]byte("zip"))
+		zapWatch, _, _ := r.Root().GetWatch([]byte("zap"))
+		missingWatch, _, _ := r.Root().GetWatch([]byte("foobar"))
+		prefixWatch := r.WatchPrefix([]byte("foo"))
+
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		txn.TrackMutateLeavesOnly(true)
+		txn.Insert([]byte("foo"), 10)
+		txn.Insert([]byte("foobar"), 11)
+		txn.Delete([]byte("zip"))
+		r = txn.CommitOnly()
+		if i == 0 {
+			txn.Notify()
+		} else {
+			txn.slowNotify()
+		}
+		if hasAnyClosedMutateCh(r) {
+			t.Fatalf("closed channel in the new tree")
+		}
+
+		for name, ch := range map[string]<-chan struct{}{"foo": fooWatch, "zip": zipWatch} {
+			select {
+			case <-ch:
+			default:
+				t.Fatalf("%d: %s wasn't notified", i, name)
+			}
+		}
+		for name, ch := range map[string]<-chan struct{}{"zap": zapWatch, "missing": missingWatch, "prefix": prefixWatch} {
+			select {
+			case <-ch:
+				t.Fatalf("%d: %s was notified", i, name)
+			default:
+			}
+		}
+	}
+}
+
 func TestTrackMutate_GetWatch(t *testing.T) {
 	for i := 0; i < 3; i++ {
 		r := New[byte, any]()
@@ -1802,6 +3437,62 @@ func TestIterateLowerBound(t *testing.T) {
 	}
 }
 
+func TestSeekLowerBoundWatch(t *testing.T) {
+	cases := []struct {
+		seek, lowerBound string
+		insert, delete   string
+		fires            bool
+	}{
+		{"aa/2", "aa/3", "aa/25", "", true},
+		{"aa/2", "aa/3", "", "aa/3", true},
+		{"aa/2", "aa/3", "c/2", "", false},
+		{"aa/0", "aa/1", "aa/05", "", true},
+		{"aa/0", "aa/1", "b/2", "", false},
+		{"aa/4", "ab/1", "aa/5", "", true},
+		{"aa/4", "ab/1", "", "ab/1", true},
+		{"aa/4", "ab/1", "b/2", "", false},
+		{"a", "aa/1", "a", "", true},
+		{"a", "aa/1", "c/2", "", false},
+		{"ab/1", "ab/1", "", "ab/1", true},
+		{"az", "b/1", "az/1", "", true},
+		{"d", "", "e", "", true},
+	}
+	for _, tc := range cases {
+		// Every case closes channels, so it needs a tree of its own.
+		r := New[byte, int]()
+		for _, k := range []string{"aa/1", "aa/3", "ab/1", "b/1", "c/1"} {
+			r, _, _ = r.Insert([]byte(k), 0)
+		}
+		it := r.Root().Iterator()
+		watch := it.SeekLowerBoundWatch([]byte(tc.seek))
+		k, _, ok := it.Next()
+		if string(k) != tc.lowerBound || ok != (tc.lowerBound != "") {
+			t.Fatalf("%q: got lower bound %q, want %q", tc.seek, k, tc.lowerBound)
+		}
+
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		if tc.insert != "" {
+			txn.Insert([]byte(tc.insert), 1)
+		}
+		if tc.delete != "" {
+			txn.Delete([]byte(tc.delete))
+		}
+		txn.Commit()
+
+		select {
+		case <-watch:
+			if !tc.fires {
+				t.Fatalf("%q: watch fired on insert %q", tc.seek, tc.insert)
+			}
+		default:
+			if tc.fires {
+				t.Fatalf("%q: watch didn't fire on insert %q, delete %q", tc.seek, tc.insert, tc.delete)
+			}
+		}
+	}
+}
+
 type readableString string
 
 func TestIterateLowerBoundFuzz(t *testing.T) {
@@ -1891,3 +3582,518 @@ func TestClone(t *testing.T) {
 		t.Fatalf("bad baz in t2")
 	}
 }
+
+func TestIteratorNextBlock(t *testing.T) {
+	r := New[byte, int]()
+	var keys []string
+	for i := 0; i < 23; i++ {
+		k := fmt.Sprintf("%02d", i)
+		keys = append(keys, k)
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	for _, size := range []int{1, 2, 5, 23, 100} {
+		it := r.Root().Iterator()
+		buf := make([]Entry[byte, int], size)
+		var out []string
+		blocks := 0
+		for {
+			n := it.NextBlock(size, buf)
+			if n == 0 {
+				break
+			}
+			blocks++
+			for _, e := range buf[:n] {
+				if keys[e.Value] != string(e.Key) {
+					t.Fatalf("bad entry %q: %d", e.Key, e.Value)
+				}
+				out = append(out, string(e.Key))
+			}
+		}
+		if !slices.Equal(out, keys) {
+			t.Fatalf("block size %d: got %v", size, out)
+		}
+		if expected := (len(keys) + size - 1) / size; blocks != expected {
+			t.Fatalf("block size %d: got %d blocks, want %d", size, blocks, expected)
+		}
+	}
+
+	// The block is capped by the buffer.
+	it := r.Root().Iterator()
+	if n := it.NextBlock(10, make([]Entry[byte, int], 3)); n != 3 {
+		t.Fatalf("bad: %d", n)
+	}
+	if k, _, _ := it.Next(); string(k) != "03" {
+		t.Fatalf("bad next key: %q", k)
+	}
+}
+
+func TestIteratorSetUpperBound(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "ba", "c"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		start, end string
+		expected   []string
+	}{
+		{"", "", keys},
+		{"", "a", []string{""}},
+		{"a", "abd", []string{"a", "ab", "abc"}},
+		{"aa", "ba", []string{"ab", "abc", "abd", "b"}},
+		{"ab", "abcd", []string{"ab", "abc"}},
+		{"b", "zzz", []string{"b", "ba", "c"}},
+		{"abc", "abc", nil},
+		{"c", "b", nil},
+		{"d", "", nil},
+	}
+	for _, tc := range cases {
+		it := r.Root().Iterator()
+		it.SeekLowerBound([]byte(tc.start))
+		it.SetUpperBound([]byte(tc.end))
+		var out []string
+		for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+			out = append(out, string(k))
+		}
+		if !slices.Equal(out, tc.expected) {
+			t.Fatalf("[%q, %q): got %q, want %q", tc.start, tc.end, out, tc.expected)
+		}
+		// The iterator stays exhausted.
+		if _, _, ok := it.Next(); ok {
+			t.Fatalf("[%q, %q): iterator resumed", tc.start, tc.end)
+		}
+	}
+
+	// The bound can be set before seeking, and applies to prefix seeks too.
+	it := r.Root().Iterator()
+	it.SetUpperBound([]byte("abd"))
+	it.SeekPrefix([]byte("ab"))
+	var out []string
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		out = append(out, string(k))
+	}
+	if !slices.Equal(out, []string{"ab", "abc"}) {
+		t.Fatalf("bad prefix scan: %q", out)
+	}
+}
+
+func TestSeal(t *testing.T) {
+	r := New[byte, int]()
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	r, _, _ = r.Insert([]byte("foobar"), 2)
+	sealed := r.Seal()
+	if !sealed.Sealed() || r.Sealed() {
+		t.Fatalf("bad sealed flags")
+	}
+
+	// Reads work.
+	if v, ok := sealed.Get([]byte("foobar")); !ok || v != 2 || sealed.Len() != 2 {
+		t.Fatalf("bad read: %v %v", v, ok)
+	}
+	if k, _, _ := sealed.Root().LongestPrefix([]byte("foobaz")); string(k) != "foo" {
+		t.Fatalf("bad: %q", k)
+	}
+
+	// Writes panic, with a message pointing at the seal.
+	for name, write := range map[string]func(){
+		"Txn":    func() { sealed.Txn() },
+		"Insert": func() { sealed.Insert([]byte("zip"), 3) },
+		"Delete": func() { sealed.Delete([]byte("foo")) },
+	} {
+		func() {
+			defer func() {
+				if msg, _ := recover().(string); !strings.Contains(msg, "sealed tree") {
+					t.Fatalf("%s: bad panic: %q", name, msg)
+				}
+			}()
+			write()
+		}()
+	}
+
+	// A clone can be forked, leaving the sealed tree alone.
+	fork, _, _ := sealed.Clone().Insert([]byte("zip"), 3)
+	if fork.Len() != 3 || sealed.Len() != 2 || fork.Sealed() {
+		t.Fatalf("bad fork")
+	}
+}
+
+func TestAccessTracking(t *testing.T) {
+	var tick int64
+	r := New[byte, int](WithAccessTracking(func() int64 {
+		tick++
+		return tick
+	}))
+	for i, k := range []string{"foo", "foobar", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	lastAccess := func(k string) int64 {
+		at, ok := r.Root().LastAccess([]byte(k))
+		if !ok {
+			t.Fatalf("missing key %q", k)
+		}
+		return at
+	}
+	if lastAccess("foo") != 0 {
+		t.Fatalf("expected no access")
+	}
+
+	r.Get([]byte("foo"))
+	r.Get([]byte("zip"))
+	r.Get([]byte("foo"))
+	r.Get([]byte("nope"))
+	if lastAccess("foo") != 3 || lastAccess("zip") != 2 || lastAccess("foobar") != 0 {
+		t.Fatalf("bad ticks: %d %d %d", lastAccess("foo"), lastAccess("zip"), lastAccess("foobar"))
+	}
+	if tick != 3 {
+		t.Fatalf("now called for a missing key")
+	}
+	if _, ok := r.Root().LastAccess([]byte("nope")); ok {
+		t.Fatalf("expected missing key")
+	}
+
+	// Writing a key resets its tick.
+	r, _, _ = r.Insert([]byte("foo"), 100)
+	if lastAccess("foo") != 0 || lastAccess("zip") != 2 {
+		t.Fatalf("bad ticks after write")
+	}
+}
+
+func TestSubTree(t *testing.T) {
+	r := New[byte, int](WithComparator(func(a, b byte) int { return int(b) - int(a) }))
+	for i, k := range []string{"foo", "foo/a", "foo/b", "foobar", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	sub, ok := r.SubTree([]byte("foo/"))
+	if !ok || sub.Len() != 2 {
+		t.Fatalf("bad subtree: %v %d", ok, sub.Len())
+	}
+	// The comparator is kept, so the keys are still in descending order.
+	var got []string
+	sub.Root().Walk(func(k []byte, v int) bool {
+		got = append(got, fmt.Sprintf("%s=%d", k, v))
+		return true
+	})
+	if fmt.Sprint(got) != "[b=2 a=1]" {
+		t.Fatalf("bad entries: %v", got)
+	}
+
+	// A prefix ending inside an edge matches the keys under it, and a key
+	// equal to the prefix becomes the empty key.
+	sub, ok = r.SubTree([]byte("fo"))
+	if v, _ := sub.Get([]byte("o")); !ok || sub.Len() != 4 || v != 0 {
+		t.Fatalf("bad subtree: %v %d", ok, sub.Len())
+	}
+	sub, ok = r.SubTree([]byte("foobar"))
+	if v, _ := sub.Get(nil); !ok || sub.Len() != 1 || v != 3 {
+		t.Fatalf("bad subtree: %v %d", ok, sub.Len())
+	}
+	if sub, _ = r.SubTree(nil); !sub.Equal(r, func(a, b int) bool { return a == b }) {
+		t.Fatalf("empty prefix doesn't match everything")
+	}
+
+	for _, prefix := range []string{"x", "foo/c", "foobars"} {
+		if sub, ok := r.SubTree([]byte(prefix)); ok || sub.Len() != 0 {
+			t.Fatalf("%q: unexpected match", prefix)
+		}
+	}
+
+	// A prefix that is itself a key becomes the empty key, even in trees
+	// rejecting it.
+	strict := New[byte, int](WithRejectEmptyKey())
+	strict, _, _ = strict.Insert([]byte("foo"), 1)
+	strict, _, _ = strict.Insert([]byte("foo/a"), 2)
+	sub, ok = strict.SubTree([]byte("foo"))
+	if v, _ := sub.Get(nil); !ok || sub.Len() != 2 || v != 1 {
+		t.Fatalf("bad subtree: %v %d", ok, sub.Len())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	r := New[byte, int](WithBloomPrefilter(100, 0.01))
+	for i := 0; i < 100; i++ {
+		r, _, _ = r.Insert([]byte(fmt.Sprintf("%03d", i)), i)
+	}
+
+	even := r.Filter(func(k []byte, v int) bool { return v%2 == 0 })
+	if even.Len() != 50 {
+		t.Fatalf("bad len: %d", even.Len())
+	}
+	even.Root().Walk(func(k []byte, v int) bool {
+		if v%2 != 0 || string(k) != fmt.Sprintf("%03d", v) {
+			t.Fatalf("bad entry: %s=%d", k, v)
+		}
+		return true
+	})
+	if even.bloom == r.bloom {
+		t.Fatalf("bloom filter is shared")
+	}
+
+	// Writing to either tree doesn't affect the other.
+	even, _, _ = even.Insert([]byte("foo"), 1)
+	r, _, _ = r.Delete([]byte("000"))
+	if _, ok := r.Get([]byte("foo")); ok {
+		t.Fatalf("insert leaked into the source")
+	}
+	if _, ok := even.Get([]byte("000")); !ok || even.Len() != 51 {
+		t.Fatalf("delete leaked into the filtered tree")
+	}
+
+	if none := r.Filter(func([]byte, int) bool { return false }); none.Len() != 0 || len(none.Root().edges) != 0 {
+		t.Fatalf("bad empty filter")
+	}
+}
+
+func TestMapValues(t *testing.T) {
+	r := New[byte, int](
+		WithComparator(func(a, b byte) int { return int(b) - int(a) }),
+		WithBloomPrefilter(100, 0.01),
+		WithAggregator(0, func(sum, v int) int { return sum + v }, func(a, b int) int { return a + b }),
+	)
+	keys := []string{"", "foo", "foobar", "foobaz", "zip"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	var visited []string
+	m := MapValues(r, func(k []byte, v int) string {
+		visited = append(visited, string(k))
+		return fmt.Sprintf("%s=%d", k, v)
+	})
+	if m.Len() != r.Len() {
+		t.Fatalf("bad len: %d", m.Len())
+	}
+	// The comparator is kept, so the keys are in descending order after the
+	// empty key.
+	verifyTree(t, []string{"", "zip", "foo", "foobaz", "foobar"}, m)
+	if !reflect.DeepEqual(visited, []string{"", "zip", "foo", "foobaz", "foobar"}) {
+		t.Fatalf("bad call order: %v", visited)
+	}
+	for i, k := range keys {
+		if v, ok := m.Get([]byte(k)); !ok || v != fmt.Sprintf("%s=%d", k, i) {
+			t.Fatalf("%q: bad value %q", k, v)
+		}
+	}
+	if m.bloom == nil || m.bloom == r.bloom || m.aggregator != nil {
+		t.Fatalf("bad options")
+	}
+
+	// The new tree can be written and watched like any other, without
+	// affecting the source.
+	watch, _, _ := m.Root().GetWatch([]byte("foobar"))
+	txn := m.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("foobar"), "new")
+	txn.Delete([]byte("zip"))
+	m2 := txn.Commit()
+	select {
+	case <-watch:
+	default:
+		t.Fatalf("watch wasn't notified")
+	}
+	verifyTree(t, []string{"", "foo", "foobaz", "foobar"}, m2)
+	if v, _ := r.Get([]byte("foobar")); v != 2 || r.Len() != 5 {
+		t.Fatalf("source was modified")
+	}
+
+	empty := MapValues(New[byte, int](), func([]byte, int) bool { return true })
+	if empty.Len() != 0 {
+		t.Fatalf("bad empty tree")
+	}
+}
+
+func TestCoveringPrefixes(t *testing.T) {
+	r := New[byte, int]()
+	if out := r.CoveringPrefixes(); out != nil {
+		t.Fatalf("expected no prefixes, got %q", out)
+	}
+	for i, k := range []string{"users/1", "users/2", "users/20", "orders/9", "orders/10", "groups/admin"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	expected := [][]byte{[]byte("groups/admin"), []byte("orders/"), []byte("users/")}
+	out := r.CoveringPrefixes()
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("got %q, want %q", out, expected)
+	}
+
+	// Every key is under exactly one prefix.
+	r.Root().Walk(func(k []byte, _ int) bool {
+		n := 0
+		for _, p := range out {
+			if bytes.HasPrefix(k, p) {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Fatalf("%q is covered %d times", k, n)
+		}
+		return true
+	})
+
+	// The prefixes don't share memory with the tree.
+	out[0][0] = 'x'
+	_ = append(out[1], 'x')
+	if v, ok := r.Get([]byte("groups/admin")); !ok || v != 5 {
+		t.Fatalf("tree was modified through a prefix")
+	}
+	if !reflect.DeepEqual(r.CoveringPrefixes(), expected) {
+		t.Fatalf("tree was modified through a prefix")
+	}
+
+	// A key that is a prefix of others is its own covering prefix.
+	r, _, _ = r.Insert([]byte("orders"), 10)
+	if out := r.CoveringPrefixes(); string(out[1]) != "orders" {
+		t.Fatalf("got %q", out)
+	}
+	r, _, _ = r.Insert(nil, 11)
+	if out := r.CoveringPrefixes(); len(out) != 1 || len(out[0]) != 0 {
+		t.Fatalf("got %q", out)
+	}
+}
+
+func TestComparator(t *testing.T) {
+	unsigned := func(a, b int32) int {
+		return cmp.Compare(uint32(a), uint32(b))
+	}
+	keys := [][]int32{{-1}, {1}, {0}, {1, -5}, {1, 3}, {-2}, {1, -5, 0}}
+	expected := [][]int32{{0}, {1}, {1, 3}, {1, -5}, {1, -5, 0}, {-2}, {-1}}
+
+	txn := New[int32, int](WithComparator(unsigned)).Txn()
+	for i, k := range keys {
+		txn.Insert(k, i)
+	}
+	r := txn.Commit()
+
+	var got [][]int32
+	r.Root().Walk(func(k []int32, _ int) bool {
+		got = append(got, k)
+		return true
+	})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad order: %v", got)
+	}
+	got = nil
+	r.Root().WalkBackwards(func(k []int32, _ int) bool {
+		got = append(got, k)
+		return true
+	})
+	reversed := slices.Clone(expected)
+	slices.Reverse(reversed)
+	if !reflect.DeepEqual(got, reversed) {
+		t.Fatalf("bad reverse order: %v", got)
+	}
+	if k, _, _ := r.Root().Minimum(); !reflect.DeepEqual(k, expected[0]) {
+		t.Fatalf("bad minimum: %v", k)
+	}
+	if k, _, _ := r.Root().Maximum(); !reflect.DeepEqual(k, expected[len(expected)-1]) {
+		t.Fatalf("bad maximum: %v", k)
+	}
+
+	// Seeking follows the comparator too.
+	it := r.Root().Iterator()
+	it.SeekLowerBound([]int32{1, 4})
+	if k, _, _ := it.Next(); !reflect.DeepEqual(k, []int32{1, -5}) {
+		t.Fatalf("bad lower bound: %v", k)
+	}
+	rit := r.Root().ReverseIterator()
+	rit.SeekReverseLowerBound([]int32{-3})
+	if k, _, _ := rit.Previous(); !reflect.DeepEqual(k, []int32{1, -5, 0}) {
+		t.Fatalf("bad reverse lower bound: %v", k)
+	}
+
+	// Writes keep the order, and batch builds match sequential ones.
+	r2, _, _ := r.Delete([]int32{1})
+	r2, _, _ = r2.Insert([]int32{2}, 10)
+	got = nil
+	r2.Root().Walk(func(k []int32, _ int) bool {
+		got = append(got, k)
+		return true
+	})
+	if !reflect.DeepEqual(got, [][]int32{{0}, {1, 3}, {1, -5}, {1, -5, 0}, {2}, {-2}, {-1}}) {
+		t.Fatalf("bad order after writes: %v", got)
+	}
+	var entries []Entry[int32, int]
+	for i, k := range keys {
+		entries = append(entries, Entry[int32, int]{Key: k, Value: i})
+	}
+	txn = New[int32, int](WithComparator(unsigned)).Txn()
+	txn.InsertBatch(entries)
+	if !reflect.DeepEqual(treeShape(txn.Commit()), treeShape(r)) {
+		t.Fatalf("batch build doesn't match")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	New[int64, int](WithComparator(unsigned))
+}
+
+func TestLookupProfile(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"foo", "foobar", "fozz", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	// The root has edges "fo" and "zip", "fo" has edges "o" and "zz", and
+	// "o" has the single edge "bar". Searching two edges takes two probes.
+	cases := []struct {
+		key          string
+		nodes, edges int
+	}{
+		{"foobar", 4, 5},
+		{"zip", 2, 2},
+		{"fob", 2, 4},
+		{"", 1, 0},
+		{"zap", 2, 2},
+	}
+	var keys [][]byte
+	var totalNodes, totalEdges int
+	for _, tc := range cases {
+		nodes, edges := r.LookupProfile([][]byte{[]byte(tc.key)})
+		if nodes != tc.nodes || edges != tc.edges {
+			t.Fatalf("%q: got %d nodes and %d edges, want %d and %d", tc.key, nodes, edges, tc.nodes, tc.edges)
+		}
+		keys = append(keys, []byte(tc.key))
+		totalNodes += tc.nodes
+		totalEdges += tc.edges
+	}
+	if nodes, edges := r.LookupProfile(keys); nodes != totalNodes || edges != totalEdges {
+		t.Fatalf("got %d nodes and %d edges, want %d and %d", nodes, edges, totalNodes, totalEdges)
+	}
+	if nodes, edges := r.LookupProfile(nil); nodes != 0 || edges != 0 {
+		t.Fatalf("bad: %d %d", nodes, edges)
+	}
+}
+
+func TestAllKeysMatch(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"aaaaaaaa", "abcdefgh", "zzzzzzzz"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	length8 := func(k []byte) bool { return len(k) == 8 }
+	if k, ok := r.AllKeysMatch(length8); !ok || k != nil {
+		t.Fatalf("bad: %q %v", k, ok)
+	}
+
+	r, _, _ = r.Insert([]byte("zz"), 3)
+	r, _, _ = r.Insert([]byte("abc"), 4)
+	visited := 0
+	k, ok := r.AllKeysMatch(func(k []byte) bool {
+		visited++
+		return length8(k)
+	})
+	if ok || string(k) != "abc" {
+		t.Fatalf("bad: %q %v", k, ok)
+	}
+	if visited != 2 {
+		t.Fatalf("walk didn't stop at the violator: %d", visited)
+	}
+	if _, ok := New[byte, int]().AllKeysMatch(func([]byte) bool { return false }); !ok {
+		t.Fatalf("empty tree should match")
+	}
+}
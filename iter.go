@@ -8,15 +8,39 @@ package iradix
 type Iterator[K keyT, T any] struct {
 	node  *Node[K, T]
 	stack []edges[K, T]
+
+	// cmp is the element order of the tree, see WithComparator.
+	cmp func(a, b K) int
+	// upper is the exclusive upper bound set by SetUpperBound, if not empty.
+	upper []K
+}
+
+// SetUpperBound makes the iterator stop before the first key that is greater
+// than or equal to key, so Next returns false from then on. It can be called
+// before or after seeking. An empty key removes the bound, since no key sorts
+// before it.
+func (i *Iterator[K, T]) SetUpperBound(key []K) {
+	i.upper = key
 }
 
 // SeekPrefixWatch is used to seek the iterator to a given prefix
 // and returns the watch channel of the finest granularity
 func (i *Iterator[K, T]) SeekPrefixWatch(prefix []K) (watch <-chan struct{}) {
+	return i.seekPrefix(prefix).watch()
+}
+
+// SeekPrefix is used to seek the iterator to a given prefix
+func (i *Iterator[K, T]) SeekPrefix(prefix []K) {
+	i.seekPrefix(prefix)
+}
+
+// seekPrefix seeks the iterator to a given prefix and returns the node of the
+// finest granularity to watch.
+func (i *Iterator[K, T]) seekPrefix(prefix []K) (watch *Node[K, T]) {
 	// Wipe the stack
 	i.stack = nil
 	n := i.node
-	watch = n.mutateCh
+	watch = n
 	search := prefix
 	for {
 		// Check for key exhaustion
@@ -33,7 +57,7 @@ func (i *Iterator[K, T]) SeekPrefixWatch(prefix []K) (watch <-chan struct{}) {
 		}
 
 		// Update to the finest granularity as the search makes progress
-		watch = n.mutateCh
+		watch = n
 
 		// Consume the search prefix
 		switch {
@@ -49,11 +73,6 @@ func (i *Iterator[K, T]) SeekPrefixWatch(prefix []K) (watch <-chan struct{}) {
 	}
 }
 
-// SeekPrefix is used to seek the iterator to a given prefix
-func (i *Iterator[K, T]) SeekPrefix(prefix []K) {
-	i.SeekPrefixWatch(prefix)
-}
-
 func (i *Iterator[K, T]) recurseMin(n *Node[K, T]) *Node[K, T] {
 	// Traverse to the minimum child
 	if n.leaf != nil {
@@ -73,10 +92,27 @@ func (i *Iterator[K, T]) recurseMin(n *Node[K, T]) *Node[K, T] {
 }
 
 // SeekLowerBound is used to seek the iterator to the smallest key that is
-// greater or equal to the given key. There is no watch variant as it's hard to
-// predict based on the radix structure which node(s) changes might affect the
-// result.
+// greater or equal to the given key. See SeekLowerBoundWatch for a watch
+// variant.
 func (i *Iterator[K, T]) SeekLowerBound(key []K) {
+	i.seekLowerBound(key)
+}
+
+// SeekLowerBoundWatch is like SeekLowerBound, and returns a conservative watch
+// channel for the lower bound, that is the first key Next returns. It's hard
+// to predict based on the radix structure which nodes changes might affect
+// the lower bound, so this is the channel of the deepest node whose subtree
+// holds both the given key and its lower bound, and it fires on any change
+// in that subtree, including changes that don't affect the result. If there
+// is no lower bound, it's the channel of the root the iterator was created
+// from. Keys after the lower bound aren't covered.
+func (i *Iterator[K, T]) SeekLowerBoundWatch(key []K) (watch <-chan struct{}) {
+	return i.seekLowerBound(key).watch()
+}
+
+// seekLowerBound seeks the iterator to the lower bound of key and returns the
+// node to watch for changes of the lower bound.
+func (i *Iterator[K, T]) seekLowerBound(key []K) (watch *Node[K, T]) {
 	// Wipe the stack. Unlike Prefix iteration, we need to build the stack as we
 	// go because we need only a subset of edges of many nodes in the path to the
 	// leaf with the lower bound. Note that the iterator will still recurse into
@@ -93,6 +129,12 @@ func (i *Iterator[K, T]) SeekLowerBound(key []K) {
 	i.node = nil
 	search := key
 
+	// Any key between the search key and the lower bound, if the lower bound
+	// is on the stack, is under the node that pushed it last, and the root
+	// covers every key if there is no lower bound.
+	var parent *Node[K, T]
+	watch = n
+
 	found := func(n *Node[K, T]) {
 		i.stack = append(
 			i.stack,
@@ -112,16 +154,20 @@ func (i *Iterator[K, T]) SeekLowerBound(key []K) {
 		// Compare current prefix with the search key's same-length prefix.
 		var prefixCmp int
 		if len(n.prefix) < len(search) {
-			prefixCmp = keyCompare(n.prefix, search[0:len(n.prefix)])
+			prefixCmp = n.compareKeys(n.prefix, search[0:len(n.prefix)])
 		} else {
-			prefixCmp = keyCompare(n.prefix, search)
+			prefixCmp = n.compareKeys(n.prefix, search)
 		}
 
 		if prefixCmp > 0 {
 			// Prefix is larger, that means the lower bound is greater than the search
 			// and from now on we need to follow the minimum path to the smallest
-			// leaf under this subtree.
+			// leaf under this subtree. A smaller key could still be inserted
+			// next to this node though.
 			findMin(n)
+			if parent != nil {
+				watch = parent
+			}
 			return
 		}
 
@@ -136,7 +182,7 @@ func (i *Iterator[K, T]) SeekLowerBound(key []K) {
 		// leaf and an exact match we're done.
 		if n.leaf != nil && keyEqual(n.leaf.key, key) {
 			found(n)
-			return
+			return n
 		}
 
 		// Consume the search prefix if the current node has one. Note that this is
@@ -150,7 +196,7 @@ func (i *Iterator[K, T]) SeekLowerBound(key []K) {
 			// all child nodes must be strictly greater, the smallest key in this
 			// subtree must be the lower bound.
 			findMin(n)
-			return
+			return n
 		}
 
 		// Otherwise, take the lower bound next edge.
@@ -162,9 +208,11 @@ func (i *Iterator[K, T]) SeekLowerBound(key []K) {
 		// Create stack edges for the all strictly higher edges in this node.
 		if idx+1 < len(n.edges) {
 			i.stack = append(i.stack, n.edges[idx+1:])
+			watch = n
 		}
 
 		// Recurse
+		parent = n
 		n = n.edges[idx].node
 	}
 }
@@ -197,8 +245,29 @@ func (i *Iterator[K, T]) Next() ([]K, T, bool) {
 
 		// Return the leaf values if any
 		if elem.leaf != nil {
-			return elem.leaf.key, elem.leaf.val, true
+			if len(i.upper) > 0 && compareKeysFunc(i.cmp, elem.leaf.key, i.upper) >= 0 {
+				// Keys only grow from here, so we're done.
+				i.node, i.stack = nil, nil
+				return nil, zero, false
+			}
+			return elem.leaf.publicKey(), elem.leaf.val, true
 		}
 	}
 	return nil, zero, false
 }
+
+// NextBlock fills buf with up to n of the next entries in order, and returns
+// how many it filled, which is less than n only for the final block and 0 once
+// the iterator is exhausted. At most len(buf) entries are filled, so buf can
+// be reused between calls.
+func (i *Iterator[K, T]) NextBlock(n int, buf []Entry[K, T]) int {
+	n = min(n, len(buf))
+	for j := 0; j < n; j++ {
+		k, v, ok := i.Next()
+		if !ok {
+			return j
+		}
+		buf[j] = Entry[K, T]{Key: k, Value: v}
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package iradix
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeyElementOutOfRange is returned by InsertErr for keys with an element
+// outside the range set with WithKeyElementRange.
+var ErrKeyElementOutOfRange = errors.New("iradix: key element out of range")
+
+// ErrEmptyKey is returned by InsertErr for the empty key in trees created with
+// WithRejectEmptyKey.
+var ErrEmptyKey = errors.New("iradix: empty key")
+
+// keyRange validates key elements of trees created with WithKeyElementRange.
+type keyRange[K keyT] struct {
+	min, max K
+}
+
+// validate checks the elements of k against the range in the element order
+// cmp, or the natural order if it's nil.
+func (r *keyRange[K]) validate(k []K, cmp func(a, b K) int) error {
+	for i, e := range k {
+		var out bool
+		if cmp != nil {
+			out = cmp(e, r.min) < 0 || cmp(e, r.max) > 0
+		} else {
+			out = e < r.min || e > r.max
+		}
+		if out {
+			return fmt.Errorf("%w: element %d is %v, not in [%v, %v]", ErrKeyElementOutOfRange, i, e, r.min, r.max)
+		}
+	}
+	return nil
+}
+
+// WithKeyElementRange restricts key elements to [lo, hi] in the tree's
+// element order, see WithComparator, to catch keys that weren't encoded
+// properly. Txn.InsertErr and Tree.InsertErr reject
+// other keys with ErrKeyElementOutOfRange before modifying anything, and
+// Insert panics on them. The key type K must match the tree's, otherwise New
+// panics.
+func WithKeyElementRange[K keyT](lo, hi K) Option {
+	return func(o *options) {
+		o.keyRange = &keyRange[K]{min: lo, max: hi}
+	}
+}
+
+// WithRejectEmptyKey forbids the empty key, which is otherwise a valid key
+// that is a prefix of every other key. Txn.InsertErr and Tree.InsertErr
+// reject it with ErrEmptyKey, and Insert panics on it.
+func WithRejectEmptyKey() Option {
+	return func(o *options) {
+		o.rejectEmptyKey = true
+	}
+}
+
+// validateKey checks k against the restrictions set by the options.
+func (t *Txn[K, T]) validateKey(k []K) error {
+	if t.rejectEmptyKey && len(k) == 0 {
+		return ErrEmptyKey
+	}
+	if t.keyRange == nil {
+		return nil
+	}
+	return t.keyRange.(*keyRange[K]).validate(k, t.root.comparator())
+}
+
+// InsertErr is like Insert, but returns an error instead of inserting keys
+// that are invalid for the tree, that is keys outside the range set with
+// WithKeyElementRange, and the empty key with WithRejectEmptyKey.
+func (t *Txn[K, T]) InsertErr(k []K, v T) (T, bool, error) {
+	if err := t.validateKey(k); err != nil {
+		var zero T
+		return zero, false, err
+	}
+	old, ok := t.upsertValid(k, v, nil, nil)
+	return old, ok, nil
+}
+
+// InsertErr is like Insert, but returns an error instead of inserting keys
+// that are invalid for the tree. The tree is returned unchanged on error.
+func (t *Tree[K, T]) InsertErr(k []K, v T) (*Tree[K, T], T, bool, error) {
+	txn := t.Txn()
+	old, ok, err := txn.InsertErr(k, v)
+	if err != nil {
+		return t, old, ok, err
+	}
+	return txn.Commit(), old, ok, nil
+}
@@ -0,0 +1,148 @@
+package iradix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeyElementRange(t *testing.T) {
+	digits := New[byte, int](WithKeyElementRange[byte]('0', '9'))
+	txn := digits.Txn()
+	for _, k := range []string{"", "0", "0123456789", "99"} {
+		if _, _, err := txn.InsertErr([]byte(k), 1); err != nil {
+			t.Fatalf("%q: unexpected error: %v", k, err)
+		}
+	}
+	for _, k := range []string{"a", "12a", "1/2", " "} {
+		if _, _, err := txn.InsertErr([]byte(k), 1); !errors.Is(err, ErrKeyElementOutOfRange) {
+			t.Fatalf("%q: expected out of range error, got %v", k, err)
+		}
+	}
+	digits = txn.Commit()
+	if digits.Len() != 4 {
+		t.Fatalf("bad len: %d", digits.Len())
+	}
+
+	// The tree is left untouched on error.
+	r, _, _, err := digits.InsertErr([]byte("x"), 1)
+	if err == nil || r != digits {
+		t.Fatalf("expected error and unchanged tree: %v", err)
+	}
+
+	ints := New[int, string](WithKeyElementRange(-1, 1))
+	ints, _, _, err = ints.InsertErr([]int{-1, 0, 1}, "ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, _, err = ints.InsertErr([]int{0, 2}, "bad"); !errors.Is(err, ErrKeyElementOutOfRange) {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+	if ints.Len() != 1 {
+		t.Fatalf("bad len: %d", ints.Len())
+	}
+
+	// The range is in the comparator's order, here descending.
+	desc := New[byte, int](WithComparator(func(a, b byte) int { return int(b) - int(a) }), WithKeyElementRange[byte]('9', '0'))
+	if _, _, _, err = desc.InsertErr([]byte("05"), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, _, err = desc.InsertErr([]byte("a"), 1); !errors.Is(err, ErrKeyElementOutOfRange) {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+}
+
+func TestKeyElementRange_Panics(t *testing.T) {
+	expectPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("%s: expected panic", name)
+			}
+		}()
+		fn()
+	}
+	r := New[byte, int](WithKeyElementRange[byte]('a', 'z'))
+	expectPanic("insert", func() { r.Insert([]byte("A"), 1) })
+	expectPanic("add", func() { Add(r.Txn(), []byte("A"), 1) })
+	expectPanic("type mismatch", func() { New[int, int](WithKeyElementRange[byte]('a', 'z')) })
+}
+
+func TestEmptyKey(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"", "a", "ab", "b"} {
+		r, _, _ = r.Insert([]byte(k), i+1)
+	}
+	if r.Len() != 4 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+
+	// Get and Insert treat nil and empty keys alike.
+	if v, ok := r.Get(nil); !ok || v != 1 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if r2, old, ok := r.Insert([]byte{}, 10); !ok || old != 1 || r2.Len() != 4 {
+		t.Fatalf("bad update of the empty key: %v %v", old, ok)
+	}
+
+	// The empty key is the longest prefix only if nothing longer matches.
+	if k, v, ok := r.Root().LongestPrefix([]byte("c")); !ok || len(k) != 0 || v != 1 {
+		t.Fatalf("bad: %q %v %v", k, v, ok)
+	}
+	if k, _, _ := r.Root().LongestPrefix([]byte("abc")); string(k) != "ab" {
+		t.Fatalf("bad: %q", k)
+	}
+
+	// Walking the empty prefix visits it along with everything else, other
+	// prefixes don't.
+	count := func(prefix string) int {
+		n := 0
+		r.Root().WalkPrefix([]byte(prefix), func([]byte, int) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	if count("") != 4 || count("a") != 2 {
+		t.Fatalf("bad walks: %d %d", count(""), count("a"))
+	}
+
+	// Deleting a non-empty prefix keeps it, deleting the empty prefix
+	// deletes everything.
+	r2, _ := r.DeletePrefix([]byte("a"))
+	if _, ok := r2.Get(nil); !ok || r2.Len() != 2 {
+		t.Fatalf("empty key was deleted with a prefix")
+	}
+	r2, _ = r.DeletePrefix(nil)
+	if r2.Len() != 0 {
+		t.Fatalf("bad len: %d", r2.Len())
+	}
+
+	// Deleting the empty key keeps everything else.
+	r2, old, ok := r.Delete(nil)
+	if !ok || old != 1 || r2.Len() != 3 {
+		t.Fatalf("bad delete: %v %v %d", old, ok, r2.Len())
+	}
+	if _, ok := r2.Get(nil); ok {
+		t.Fatalf("empty key wasn't deleted")
+	}
+	if v, _ := r2.Get([]byte("ab")); v != 3 {
+		t.Fatalf("bad: %v", v)
+	}
+}
+
+func TestRejectEmptyKey(t *testing.T) {
+	r := New[byte, int](WithRejectEmptyKey())
+	if _, _, _, err := r.InsertErr(nil, 1); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("expected ErrEmptyKey, got %v", err)
+	}
+	r, _, _, err := r.InsertErr([]byte("a"), 1)
+	if err != nil || r.Len() != 1 {
+		t.Fatalf("bad: %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	r.Insert([]byte{}, 1)
+}
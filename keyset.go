@@ -0,0 +1,86 @@
+package iradix
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+)
+
+// keySetVersion is the first byte of every key set written by MarshalKeys,
+// bumped on format changes.
+const keySetVersion = 1
+
+// MarshalKeys encodes the keys of t in key order, without their values, in a
+// compact binary form which UnmarshalKeySet reads back. Keys are front-coded:
+// every key is written as the number of leading elements it shares with the
+// previous key, followed by the remaining elements, so keys with long common
+// prefixes take little space. Key elements are encoded portably, as in
+// EncodeDelta.
+func (t *Tree[K, T]) MarshalKeys() ([]byte, error) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	enc := encoder[K]{w: bw}
+	enc.writeByte(keySetVersion)
+	enc.writeUvarint(uint64(t.size))
+	var prev []K
+	t.root.Walk(func(k []K, _ T) bool {
+		shared := longestPrefix(prev, k)
+		enc.writeUvarint(uint64(shared))
+		enc.writeKey(k[shared:])
+		prev = k
+		return enc.err == nil
+	})
+	if enc.err != nil {
+		return nil, enc.err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalKeySet decodes the keys written by MarshalKeys, in the order of
+// the tree they were written from. ErrInvalidEncoding is returned if data is
+// malformed.
+func UnmarshalKeySet[K keyT](data []byte) ([][]K, error) {
+	r := bytes.NewReader(data)
+	dec := decoder[K]{r: r, invalid: ErrInvalidEncoding}
+	if v, err := dec.readByte(); err != nil {
+		return nil, err
+	} else if v != keySetVersion {
+		return nil, fmt.Errorf("%w: unknown version %d", ErrInvalidEncoding, v)
+	}
+	n, err := dec.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every key takes at least two bytes, so don't trust a larger count for
+	// the allocation.
+	if n > uint64(r.Len()/2) {
+		return nil, fmt.Errorf("%w: bad key count %d", ErrInvalidEncoding, n)
+	}
+	keys := make([][]K, 0, n)
+	var prev []K
+	for i := uint64(0); i < n; i++ {
+		shared, err := dec.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if shared > uint64(len(prev)) {
+			return nil, fmt.Errorf("%w: shared prefix longer than the previous key", ErrInvalidEncoding)
+		}
+		suffix, err := dec.readKey()
+		if err != nil {
+			return nil, err
+		}
+		k := make([]K, 0, int(shared)+len(suffix))
+		k = append(append(k, prev[:shared]...), suffix...)
+		keys = append(keys, k)
+		prev = k
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidEncoding)
+	}
+	return keys, nil
+}
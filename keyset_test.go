@@ -0,0 +1,84 @@
+package iradix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMarshalKeys(t *testing.T) {
+	r := New[byte, string]()
+	txn := r.Txn()
+	txn.Insert(nil, "")
+	for i := 0; i < 1000; i++ {
+		txn.Insert([]byte(fmt.Sprintf("key/%d", i)), strings.Repeat("v", 100))
+	}
+	r = txn.Commit()
+
+	data, err := r.MarshalKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := UnmarshalKeySet[byte](data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected [][]byte
+	r.Root().Walk(func(k []byte, _ string) bool {
+		expected = append(expected, k)
+		return true
+	})
+	if !slices.EqualFunc(keys, expected, keyEqual[byte]) {
+		t.Fatalf("bad keys: %q", keys)
+	}
+
+	// Leaving out the values makes it much smaller than the full encoding.
+	var full bytes.Buffer
+	if err := Marshal(r, &full, func(v string) ([]byte, error) { return []byte(v), nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(data)*10 > full.Len() {
+		t.Fatalf("key set is too large: %d bytes, full encoding is %d", len(data), full.Len())
+	}
+
+	// Truncated and corrupt key sets are rejected.
+	for _, b := range [][]byte{nil, data[:len(data)-1], append(slices.Clip(data), 0), {keySetVersion + 1}, {keySetVersion, 1, 1, 0}, {keySetVersion, 200}} {
+		if _, err := UnmarshalKeySet[byte](b); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("%v: expected ErrInvalidEncoding, got %v", b, err)
+		}
+	}
+}
+
+func TestMarshalKeys_KeyTypes(t *testing.T) {
+	testKeySet(t, [][]string{{"foo", "bar"}, {"foo"}, {"foo", "baz", "x"}, {""}})
+	testKeySet(t, [][]int{{1, -2, 3}, {1}, {-1 << 40}})
+	testKeySet(t, [][]float64{{1.5, -0.25}, {1.5}})
+	testKeySet[uint16](t, nil)
+}
+
+func testKeySet[K keyT](t *testing.T, keys [][]K) {
+	t.Helper()
+	r := New[K, int]()
+	for i, k := range keys {
+		r, _, _ = r.Insert(k, i)
+	}
+	data, err := r.MarshalKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := UnmarshalKeySet[K](data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != r.Len() {
+		t.Fatalf("bad key count: %d", len(got))
+	}
+	for _, k := range got {
+		if !r.Has(k) {
+			t.Fatalf("unexpected key %v", k)
+		}
+	}
+}
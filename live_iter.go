@@ -0,0 +1,54 @@
+package iradix
+
+// LiveIterator iterates over keys in order while following the latest version
+// of a tree. Before every step it fetches the current tree, and if that has
+// changed since the previous step, it re-seeks past the last key returned. The
+// keys returned are strictly increasing, so nothing is returned twice, and
+// keys committed after the iteration started are seen if they sort after the
+// current position. Keys committed behind the position are missed, and a
+// value is returned as of the tree current when its key is reached.
+//
+// Next returning false only means there are no more keys in the current tree;
+// calling it again later returns keys committed since, which allows tailing a
+// growing ordered data set.
+type LiveIterator[K keyT, T any] struct {
+	get  func() *Tree[K, T]
+	tree *Tree[K, T]
+	iter *Iterator[K, T]
+
+	// last is the last key returned, or the start key if started is false.
+	last    []K
+	started bool
+}
+
+// NewLiveIterator returns a LiveIterator over the keys greater or equal to
+// start, reading trees from get, which is called before every step and must
+// be safe to call from the goroutine using the iterator.
+func NewLiveIterator[K keyT, T any](get func() *Tree[K, T], start []K) *LiveIterator[K, T] {
+	return &LiveIterator[K, T]{get: get, last: start}
+}
+
+// Next returns the next key and its value from the current tree.
+func (li *LiveIterator[K, T]) Next() ([]K, T, bool) {
+	if tree := li.get(); tree != li.tree || li.iter == nil {
+		li.tree = tree
+		li.iter = tree.root.Iterator()
+		li.iter.SeekLowerBound(li.last)
+		if li.started {
+			// The lower bound includes the last key returned.
+			k, v, ok := li.iter.Next()
+			if !ok || !keyEqual(k, li.last) {
+				return li.yield(k, v, ok)
+			}
+		}
+	}
+	return li.yield(li.iter.Next())
+}
+
+func (li *LiveIterator[K, T]) yield(k []K, v T, ok bool) ([]K, T, bool) {
+	if ok {
+		li.last = k
+		li.started = true
+	}
+	return k, v, ok
+}
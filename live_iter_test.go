@@ -0,0 +1,64 @@
+package iradix
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestLiveIterator(t *testing.T) {
+	r := New[byte, int]()
+	for _, i := range []int{10, 20, 30, 40} {
+		r, _, _ = r.Insert([]byte(fmt.Sprintf("%03d", i)), i)
+	}
+	current := r
+	it := NewLiveIterator(func() *Tree[byte, int] { return current }, []byte("015"))
+
+	var out []int
+	next := func() bool {
+		k, v, ok := it.Next()
+		if ok {
+			if string(k) != fmt.Sprintf("%03d", v) {
+				t.Fatalf("bad entry %q: %d", k, v)
+			}
+			out = append(out, v)
+		}
+		return ok
+	}
+
+	next() // 20
+	// Commit keys before, at and after the current position.
+	for _, i := range []int{5, 20, 25, 50} {
+		current, _, _ = current.Insert([]byte(fmt.Sprintf("%03d", i)), i)
+	}
+	next() // 25
+	current, _, _ = current.Delete([]byte("030"))
+	for next() {
+	}
+	if !slices.Equal(out, []int{20, 25, 40, 50}) {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// Tailing picks up keys committed after exhaustion.
+	current, _, _ = current.Insert([]byte("050"), 50)
+	current, _, _ = current.Insert([]byte("060"), 60)
+	for next() {
+	}
+	if !slices.Equal(out, []int{20, 25, 40, 50, 60}) {
+		t.Fatalf("bad: %v", out)
+	}
+	if next() {
+		t.Fatalf("expected the iterator to be exhausted")
+	}
+}
+
+func TestLiveIterator_Start(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"a", "b", "c"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	it := NewLiveIterator(func() *Tree[byte, int] { return r }, []byte("b"))
+	if k, _, ok := it.Next(); !ok || string(k) != "b" {
+		t.Fatalf("expected start key to be included, got %q", k)
+	}
+}
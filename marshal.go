@@ -0,0 +1,129 @@
+package iradix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidEncoding is returned by Unmarshal if its input is malformed.
+var ErrInvalidEncoding = errors.New("iradix: invalid encoding")
+
+// marshalVersion is the first byte of every marshaled tree, bumped on format
+// changes.
+const marshalVersion = 1
+
+// Marshal writes the entries of t to w in a compact binary form which
+// Unmarshal reads back. Rather than every key, it stores the shape of the
+// tree: the prefix of every node, whether it holds a value, the value encoded
+// by encodeVal, and its children, so shared key prefixes are written only
+// once. Edge labels are the first elements of the children's prefixes, so
+// they aren't written separately. Key elements are encoded portably, as in
+// EncodeDelta. Errors returned by encodeVal are returned as is.
+func Marshal[K keyT, T any](t *Tree[K, T], w io.Writer, encodeVal func(T) ([]byte, error)) error {
+	bw := bufio.NewWriter(w)
+	enc := encoder[K]{w: bw}
+	enc.writeByte(marshalVersion)
+	marshalNode(&enc, t.root, encodeVal)
+	if enc.err != nil {
+		return enc.err
+	}
+	return bw.Flush()
+}
+
+// marshalNode writes n and all nodes under it in pre-order.
+func marshalNode[K keyT, T any](enc *encoder[K], n *Node[K, T], encodeVal func(T) ([]byte, error)) {
+	enc.writeKey(n.prefix)
+	if n.leaf == nil {
+		enc.writeByte(0)
+	} else {
+		enc.writeByte(1)
+		b, err := encodeVal(n.leaf.val)
+		if err != nil && enc.err == nil {
+			enc.err = err
+		}
+		enc.writeBytes(b)
+	}
+	enc.writeUvarint(uint64(len(n.edges)))
+	for _, e := range n.edges {
+		if enc.err != nil {
+			return
+		}
+		marshalNode(enc, e.node, encodeVal)
+	}
+}
+
+// Unmarshal reads a tree written by Marshal from r, decoding values with
+// decodeVal, and builds it in a single transaction on a new tree created with
+// the given options. The entries are inserted with Txn.InsertBatch, so the
+// tree is built bottom-up, and keys are validated as by Txn.InsertErr.
+// ErrInvalidEncoding is returned if the input is malformed, and errors
+// returned by decodeVal or key validation are returned as is.
+func Unmarshal[K keyT, T any](r io.Reader, decodeVal func([]byte) (T, error), opts ...Option) (*Tree[K, T], error) {
+	dec := decoder[K]{r: byteReader(r), invalid: ErrInvalidEncoding}
+	if v, err := dec.readByte(); err != nil {
+		return nil, err
+	} else if v != marshalVersion {
+		return nil, fmt.Errorf("%w: unknown version %d", ErrInvalidEncoding, v)
+	}
+
+	var entries []Entry[K, T]
+	if err := unmarshalNode(&dec, nil, true, decodeVal, &entries); err != nil {
+		return nil, err
+	}
+	txn := New[K, T](opts...).Txn()
+	for _, e := range entries {
+		if err := txn.validateKey(e.Key); err != nil {
+			return nil, err
+		}
+	}
+	txn.InsertBatch(entries)
+	return txn.Commit(), nil
+}
+
+// unmarshalNode reads a node written by marshalNode whose parent has the
+// effective path parent, appending its entries and those of all nodes under
+// it to entries. Only the root may have an empty prefix.
+func unmarshalNode[K keyT, T any](dec *decoder[K], parent []K, root bool, decodeVal func([]byte) (T, error), entries *[]Entry[K, T]) error {
+	prefix, err := dec.readKey()
+	if err != nil {
+		return err
+	}
+	if !root && len(prefix) == 0 {
+		return fmt.Errorf("%w: empty prefix", ErrInvalidEncoding)
+	}
+	path := make([]K, 0, len(parent)+len(prefix))
+	path = append(append(path, parent...), prefix...)
+
+	hasLeaf, err := dec.readByte()
+	if err != nil {
+		return err
+	}
+	switch hasLeaf {
+	case 0:
+	case 1:
+		b, err := dec.readBytes()
+		if err != nil {
+			return err
+		}
+		v, err := decodeVal(b)
+		if err != nil {
+			return err
+		}
+		*entries = append(*entries, Entry[K, T]{Key: path, Value: v})
+	default:
+		return fmt.Errorf("%w: bad leaf flag %d", ErrInvalidEncoding, hasLeaf)
+	}
+
+	edges, err := dec.readUvarint()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < edges; i++ {
+		if err := unmarshalNode(dec, path, false, decodeVal, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package iradix
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	encode := func(v int) ([]byte, error) { return encInt(v), nil }
+	eq := func(a, b int) bool { return a == b }
+
+	r := New[byte, int]()
+	txn := r.Txn()
+	for i := 0; i < 1000; i++ {
+		txn.Insert([]byte("key/"+strconv.Itoa(i)), i)
+	}
+	txn.Insert(nil, -1)
+	txn.Insert([]byte("k"), -2)
+	r = txn.Commit()
+
+	var buf bytes.Buffer
+	if err := Marshal(r, &buf, encode); err != nil {
+		t.Fatal(err)
+	}
+	// Shared prefixes are written once, so the encoding is smaller than a
+	// delta from the empty tree, which holds every key in full.
+	var full bytes.Buffer
+	if err := EncodeDelta(New[byte, int](), r, &full, encInt); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() >= full.Len() {
+		t.Fatalf("encoding is too large: %d bytes, full delta is %d", buf.Len(), full.Len())
+	}
+
+	encoded := bytes.Clone(buf.Bytes())
+	out, err := Unmarshal[byte](bytes.NewReader(encoded), decInt, WithLazyChannels())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(r, eq) || !out.lazyChannels {
+		t.Fatalf("bad round trip")
+	}
+	if !treeShapeEqual(out, r) {
+		t.Fatalf("round trip changed the shape")
+	}
+
+	// Empty trees and other key types.
+	buf.Reset()
+	if err := Marshal(New[byte, int](), &buf, encode); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := Unmarshal[byte](&buf, decInt); err != nil || out.Len() != 0 {
+		t.Fatalf("bad empty round trip: %v", err)
+	}
+	s := New[string, int]()
+	for i, k := range [][]string{{"a", "b"}, {"a"}, {"c", "", "d"}} {
+		s, _, _ = s.Insert(k, i)
+	}
+	buf.Reset()
+	if err := Marshal(s, &buf, encode); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := Unmarshal[string](&buf, decInt); err != nil || !out.Equal(s, eq) {
+		t.Fatalf("bad string round trip: %v", err)
+	}
+
+	// Errors.
+	boom := errors.New("boom")
+	if err := Marshal(r, &buf, func(int) ([]byte, error) { return nil, boom }); err != boom {
+		t.Fatalf("expected encoder error, got %v", err)
+	}
+	if _, err := Unmarshal[byte](bytes.NewReader(encoded), func([]byte) (int, error) { return 0, boom }); err != boom {
+		t.Fatalf("expected decoder error, got %v", err)
+	}
+	for _, b := range [][]byte{nil, encoded[:len(encoded)-1], {marshalVersion + 1}, {marshalVersion, 0, 2}, {marshalVersion, 0, 0, 1, 0, 0, 0}} {
+		if _, err := Unmarshal[byte](bytes.NewReader(b), decInt); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("%v: expected ErrInvalidEncoding, got %v", b, err)
+		}
+	}
+	if _, err := Unmarshal[byte](bytes.NewReader(encoded), decInt, WithRejectEmptyKey()); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("expected ErrEmptyKey, got %v", err)
+	}
+}
+
+func treeShapeEqual[K keyT, T any](a, b *Tree[K, T]) bool {
+	sa, sb := treeShape(a), treeShape(b)
+	if len(sa) != len(sb) {
+		return false
+	}
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+	return true
+}
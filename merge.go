@@ -0,0 +1,318 @@
+package iradix
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// mergeSource returns the entries of one of the merged sources in key order,
+// like Iterator.Next.
+type mergeSource[K keyT, T any] func() ([]K, T, bool)
+
+// mergeCursor is the current entry of one of the merged sources.
+type mergeCursor[K keyT, T any] struct {
+	next mergeSource[K, T]
+	key  []K
+	val  T
+	// pos is the position of the source among all merged sources, used to
+	// break ties so that values of equal keys come in source order.
+	pos int
+	// cmp compares keys in the order of the source tree.
+	cmp func(a, b []K) int
+}
+
+// mergeHeap is a min-heap of cursors ordered by key.
+type mergeHeap[K keyT, T any] []*mergeCursor[K, T]
+
+func (h mergeHeap[K, T]) Len() int {
+	return len(h)
+}
+
+func (h mergeHeap[K, T]) Less(i, j int) bool {
+	if cmp := h[i].cmp(h[i].key, h[j].key); cmp != 0 {
+		return cmp < 0
+	}
+	return h[i].pos < h[j].pos
+}
+
+func (h mergeHeap[K, T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *mergeHeap[K, T]) Push(x any) {
+	*h = append(*h, x.(*mergeCursor[K, T]))
+}
+
+func (h *mergeHeap[K, T]) Pop() any {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return c
+}
+
+// newMergeHeap returns a heap positioned at the first entry of each source,
+// comparing keys with cmp.
+func newMergeHeap[K keyT, T any](sources []mergeSource[K, T], cmp func(a, b []K) int) *mergeHeap[K, T] {
+	h := make(mergeHeap[K, T], 0, len(sources))
+	for pos, next := range sources {
+		if k, v, ok := next(); ok {
+			h = append(h, &mergeCursor[K, T]{next: next, key: k, val: v, pos: pos, cmp: cmp})
+		}
+	}
+	heap.Init(&h)
+	return &h
+}
+
+// next pops the smallest key together with the values of all sources holding
+// it, in source order. The values are appended to vals, which is returned.
+func (h *mergeHeap[K, T]) next(vals []T) ([]K, []T, bool) {
+	if h.Len() == 0 {
+		return nil, vals, false
+	}
+	key := (*h)[0].key
+	for h.Len() > 0 && keyEqual((*h)[0].key, key) {
+		c := (*h)[0]
+		vals = append(vals, c.val)
+		if k, v, ok := c.next(); ok {
+			c.key, c.val = k, v
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return key, vals, true
+}
+
+// MergeSorted merges the entries of several trees into a new tree created with
+// the given options. It does a single N-way merge of all trees in key order,
+// calling resolve for every key with the values of all trees holding it, in the
+// order the trees were given, and stores the result. resolve may keep vals.
+// This takes O(n log(len(trees))) for n entries in total, which beats merging
+// the trees pairwise when there are many of them.
+func MergeSorted[K keyT, T any](trees []*Tree[K, T], resolve func(k []K, vals []T) T, opts ...Option) *Tree[K, T] {
+	sources := make([]mergeSource[K, T], len(trees))
+	for i, t := range trees {
+		sources[i] = leafSource(t.root)
+	}
+	var h *mergeHeap[K, T]
+	if len(trees) > 0 {
+		// All trees share the order of the first.
+		h = newMergeHeap(sources, trees[0].root.compareKeys)
+	} else {
+		h = &mergeHeap[K, T]{}
+	}
+
+	txn := New[K, T](opts...).Txn()
+	for k, vals, ok := h.next(nil); ok; k, vals, ok = h.next(nil) {
+		txn.Insert(k, resolve(k, vals))
+	}
+	return txn.Commit()
+}
+
+// leafSource returns the leaves under n in key order, walking the nodes
+// directly rather than through an Iterator.
+func leafSource[K keyT, T any](n *Node[K, T]) mergeSource[K, T] {
+	iter := n.rawIterator()
+	return func() ([]K, T, bool) {
+		for ; iter.Front() != nil; iter.Next() {
+			if leaf := iter.Front().leaf; leaf != nil {
+				iter.Next()
+				return leaf.publicKey(), leaf.val, true
+			}
+		}
+		var zero T
+		return nil, zero, false
+	}
+}
+
+// MergedIterator iterates the union of the entries of several iterators in
+// key order, see MergeIterators.
+type MergedIterator[K keyT, T any] struct {
+	h    *mergeHeap[K, T]
+	pick func(a, b T) T
+	vals []T
+}
+
+// MergeIterators returns an iterator over the union of the entries of iters,
+// e.g. of per-shard trees, in key order, without building a combined tree.
+// The iterators may be seeked or bounded beforehand, and all must iterate
+// trees with the same order. For keys returned by several of them, pick is
+// called with the value picked so far as a and the next one as b, in the
+// order the iterators were given. If pick is nil, the last one wins. Every
+// step takes O(log(len(iters))), and the iterators are advanced as it goes.
+// This is the reading counterpart to MergeSorted.
+func MergeIterators[K keyT, T any](pick func(a, b T) T, iters ...*Iterator[K, T]) *MergedIterator[K, T] {
+	sources := make([]mergeSource[K, T], len(iters))
+	for i, it := range iters {
+		sources[i] = it.Next
+	}
+	var h *mergeHeap[K, T]
+	if len(iters) > 0 {
+		// All iterators share the order of the first.
+		cmp := iters[0].cmp
+		h = newMergeHeap(sources, func(a, b []K) int {
+			return compareKeysFunc(cmp, a, b)
+		})
+	} else {
+		h = &mergeHeap[K, T]{}
+	}
+	return &MergedIterator[K, T]{h: h, pick: pick}
+}
+
+// Next returns the next key of the union and its picked value, or false once
+// all iterators are exhausted.
+func (m *MergedIterator[K, T]) Next() ([]K, T, bool) {
+	k, vals, ok := m.h.next(m.vals[:0])
+	m.vals = vals
+	if !ok {
+		var zero T
+		return nil, zero, false
+	}
+	v := vals[0]
+	for _, b := range vals[1:] {
+		if m.pick == nil {
+			v = b
+		} else {
+			v = m.pick(v, b)
+		}
+	}
+	return k, v, true
+}
+
+// IndexSlice builds a tree, created with the given options, mapping the key
+// derived from every record by keyOf to the record. If several records have
+// the same key, the last one wins. The records are inserted in key order,
+// which keeps the nodes written by consecutive inserts together.
+func IndexSlice[K keyT, R any](records []R, keyOf func(R) []K, opts ...Option) *Tree[K, R] {
+	txn := New[K, R](opts...).Txn()
+	entries := make([]Entry[K, R], len(records))
+	for i, r := range records {
+		entries[i] = Entry[K, R]{Key: keyOf(r), Value: r}
+	}
+	// A stable sort keeps duplicates in their original order, so the last
+	// one is inserted last.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return txn.root.compareKeys(entries[i].Key, entries[j].Key) < 0
+	})
+
+	for _, e := range entries {
+		txn.Insert(e.Key, e.Value)
+	}
+	return txn.Commit()
+}
+
+// Generate builds a tree, created with the given options, from the entries
+// returned by next, which is called until it returns false. The entries are
+// inserted in a single transaction in the order they are returned, so later
+// entries win over earlier ones with the same key.
+func Generate[K keyT, T any](next func() (Entry[K, T], bool), opts ...Option) *Tree[K, T] {
+	txn := New[K, T](opts...).Txn()
+	for e, ok := next(); ok; e, ok = next() {
+		txn.Insert(e.Key, e.Value)
+	}
+	return txn.Commit()
+}
+
+// InsertTree inserts all entries of other into the transaction in key order,
+// so that they are committed atomically with the transaction's other changes.
+// For keys already present in the transaction, resolve is called with the
+// current value as a and the value in other as b, and its result is stored.
+// If resolve is nil, values in other win.
+func (t *Txn[K, T]) InsertTree(other *Tree[K, T], resolve func(k []K, a, b T) T) {
+	other.root.Walk(func(k []K, v T) bool {
+		if resolve == nil {
+			t.Insert(k, v)
+		} else {
+			t.upsert(k, v, func(old T, exists bool) (T, bool) {
+				if !exists {
+					return v, true
+				}
+				return resolve(k, old, v), true
+			})
+		}
+		return true
+	})
+}
+
+// Merge returns a new tree holding the entries of both t and other. For keys
+// present in both, resolve is called with the value in t as a and the value
+// in other as b, and its result is stored. The entries of the smaller tree are
+// inserted into a transaction of the larger one, which is committed once, so
+// merging n entries into a tree of size m takes O(n log m) and the result
+// shares all untouched nodes with the larger tree, whose options it keeps.
+// Like Tree.Insert, Merge doesn't track mutations. To have watches on the
+// receiver fire for the keys the merge changes, use Txn.InsertTree on a
+// transaction with TrackMutate enabled instead.
+func (t *Tree[K, T]) Merge(other *Tree[K, T], resolve func(key []K, a, b T) T) *Tree[K, T] {
+	large, small := t, other
+	if other.size > t.size {
+		large, small = other, t
+	}
+	txn := large.Txn()
+	small.root.Walk(func(k []K, v T) bool {
+		// Resolve conflicts during the insert, which walks the tree once and
+		// replaces every leaf once.
+		txn.upsert(k, v, func(old T, exists bool) (T, bool) {
+			switch {
+			case !exists:
+				return v, true
+			case large == t:
+				return resolve(k, old, v), true
+			default:
+				return resolve(k, v, old), true
+			}
+		})
+		return true
+	})
+	return txn.Commit()
+}
+
+// UnionReportingConflicts returns a new tree holding the entries of both t and
+// other, along with the keys present in both, in key order. For such keys,
+// resolve is called with the value in t as a and the value in other as b, and
+// its result is stored. Both trees are iterated in lockstep once, and the
+// result is built in a single transaction on t. Unlike Merge this visits all
+// entries of t, since all of them may be conflicts.
+func (t *Tree[K, T]) UnionReportingConflicts(other *Tree[K, T], resolve func(k []K, a, b T) T) (*Tree[K, T], [][]K) {
+	txn := t.Txn()
+	var conflicts [][]K
+	ours, theirs := t.root.Iterator(), other.root.Iterator()
+	ok, ov, oOK := ours.Next()
+	tk, tv, tOK := theirs.Next()
+	for tOK {
+		cmp := 1
+		if oOK {
+			cmp = t.root.compareKeys(ok, tk)
+		}
+		switch {
+		case cmp < 0:
+			ok, ov, oOK = ours.Next()
+		case cmp > 0:
+			txn.Insert(tk, tv)
+			tk, tv, tOK = theirs.Next()
+		default:
+			conflicts = append(conflicts, tk)
+			txn.Insert(tk, resolve(tk, ov, tv))
+			ok, ov, oOK = ours.Next()
+			tk, tv, tOK = theirs.Next()
+		}
+	}
+	return txn.Commit(), conflicts
+}
+
+// UnionSize returns the number of distinct keys in t and other combined,
+// without building the union. It does a lockstep walk of both trees which
+// skips subtrees shared by them, so it's cheap for trees derived from one
+// another.
+func (t *Tree[K, T]) UnionSize(other *Tree[K, T]) int {
+	size := t.size
+	walkChanged(t.root, other.root, func(_, _ T) bool { return true }, func(_ []K, _ T, kind ChangeKind) bool {
+		if kind == ChangeAdded {
+			size++
+		}
+		return true
+	})
+	return size
+}
@@ -0,0 +1,392 @@
+package iradix
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestMergeSorted(t *testing.T) {
+	day1 := New[byte, int]()
+	day2 := New[byte, int]()
+	day3 := New[byte, int]()
+	for k, v := range map[string]int{"a": 1, "b": 1, "foo": 1, "foobar": 1} {
+		day1, _, _ = day1.Insert([]byte(k), v)
+	}
+	for k, v := range map[string]int{"b": 2, "c": 2, "foo": 2} {
+		day2, _, _ = day2.Insert([]byte(k), v)
+	}
+	for k, v := range map[string]int{"": 3, "foo": 3, "foobar": 3, "z": 3} {
+		day3, _, _ = day3.Insert([]byte(k), v)
+	}
+
+	// resolve may keep vals.
+	resolved := make(map[string][]int)
+	merged := MergeSorted([]*Tree[byte, int]{day1, day2, day3, New[byte, int]()}, func(k []byte, vals []int) int {
+		resolved[string(k)] = vals
+		sum := 0
+		for _, v := range vals {
+			sum += v
+		}
+		return sum
+	})
+
+	expected := map[string][]int{
+		"":       {3},
+		"a":      {1},
+		"b":      {1, 2},
+		"c":      {2},
+		"foo":    {1, 2, 3},
+		"foobar": {1, 3},
+		"z":      {3},
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Fatalf("bad resolve calls: %v", resolved)
+	}
+	if merged.Len() != len(expected) {
+		t.Fatalf("bad len: %d", merged.Len())
+	}
+	for k, vals := range expected {
+		sum := 0
+		for _, v := range vals {
+			sum += v
+		}
+		if v, ok := merged.Get([]byte(k)); !ok || v != sum {
+			t.Fatalf("bad value for %q: %v %v", k, v, ok)
+		}
+	}
+
+	// Inputs are untouched.
+	if v, _ := day1.Get([]byte("foo")); v != 1 || day1.Len() != 4 {
+		t.Fatalf("input tree was modified")
+	}
+}
+
+func TestMergeSorted_Many(t *testing.T) {
+	var trees []*Tree[byte, int]
+	for i := 0; i < 20; i++ {
+		txn := New[byte, int]().Txn()
+		for j := i; j < 100; j += 3 {
+			txn.Insert([]byte(fmt.Sprintf("%03d", j)), 1)
+		}
+		trees = append(trees, txn.Commit())
+	}
+	merged := MergeSorted(trees, func(_ []byte, vals []int) int {
+		return len(vals)
+	})
+	if merged.Len() != 100 {
+		t.Fatalf("bad len: %d", merged.Len())
+	}
+	for j := 0; j < 100; j++ {
+		expected := 0
+		for i := 0; i < 20; i++ {
+			if j >= i && (j-i)%3 == 0 {
+				expected++
+			}
+		}
+		if v, _ := merged.Get([]byte(fmt.Sprintf("%03d", j))); v != expected {
+			t.Fatalf("bad count for %d: %d, want %d", j, v, expected)
+		}
+	}
+	if MergeSorted[byte, int](nil, nil).Len() != 0 {
+		t.Fatalf("expected empty tree")
+	}
+}
+
+func TestMergeIterators(t *testing.T) {
+	var shards []*Tree[byte, int]
+	for i, keys := range [][]string{{"a", "b", "foo", "foobar"}, {"b", "c", "foo"}, {"", "foo", "foobar", "z"}, nil} {
+		r := New[byte, int]()
+		for _, k := range keys {
+			r, _, _ = r.Insert([]byte(k), 1<<i)
+		}
+		shards = append(shards, r)
+	}
+	iters := func() []*Iterator[byte, int] {
+		var out []*Iterator[byte, int]
+		for _, r := range shards {
+			out = append(out, r.Root().Iterator())
+		}
+		return out
+	}
+	collect := func(m *MergedIterator[byte, int]) []string {
+		var out []string
+		for k, v, ok := m.Next(); ok; k, v, ok = m.Next() {
+			out = append(out, fmt.Sprintf("%s=%d", k, v))
+		}
+		if _, _, ok := m.Next(); ok {
+			t.Fatalf("exhausted iterator returned a key")
+		}
+		return out
+	}
+
+	// Picking a | b folds the values of all shards holding a key, in order.
+	var picks []string
+	got := collect(MergeIterators(func(a, b int) int {
+		picks = append(picks, fmt.Sprintf("%d|%d", a, b))
+		return a | b
+	}, iters()...))
+	want := []string{"=4", "a=1", "b=3", "c=2", "foo=7", "foobar=5", "z=4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if want := []string{"1|2", "1|2", "3|4", "1|4"}; !reflect.DeepEqual(picks, want) {
+		t.Fatalf("bad picks: %v", picks)
+	}
+
+	// Without pick the last iterator wins, and seeked iterators only
+	// contribute the rest of their entries.
+	seeked := iters()
+	seeked[0].SeekPrefix([]byte("foo"))
+	seeked[2].SeekLowerBound([]byte("foobar"))
+	got = collect(MergeIterators(nil, seeked...))
+	want = []string{"b=2", "c=2", "foo=2", "foobar=4", "z=4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if got := collect(MergeIterators[byte, int](nil)); got != nil {
+		t.Fatalf("bad empty merge: %v", got)
+	}
+
+	// Iterators of trees with a comparator are merged in its order.
+	reverse := WithComparator(func(a, b byte) int { return int(b) - int(a) })
+	r1, r2 := New[byte, int](reverse), New[byte, int](reverse)
+	r1, _, _ = r1.Insert([]byte("a"), 1)
+	r1, _, _ = r1.Insert([]byte("c"), 1)
+	r2, _, _ = r2.Insert([]byte("b"), 2)
+	got = collect(MergeIterators(nil, r1.Root().Iterator(), r2.Root().Iterator()))
+	if want := []string{"c=1", "b=2", "a=1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestIndexSlice(t *testing.T) {
+	type record struct {
+		name string
+		rev  int
+	}
+	records := []record{{"b", 1}, {"a", 1}, {"c", 1}, {"a", 2}, {"b", 2}, {"a", 3}}
+	r := IndexSlice(records, func(r record) []byte { return []byte(r.name) })
+
+	var out []record
+	r.Root().Walk(func(k []byte, v record) bool {
+		if string(k) != v.name {
+			t.Fatalf("bad key %q for %v", k, v)
+		}
+		out = append(out, v)
+		return true
+	})
+	if !reflect.DeepEqual(out, []record{{"a", 3}, {"b", 2}, {"c", 1}}) {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// Options are applied to the tree.
+	r = IndexSlice(records[:3], func(r record) []byte { return []byte(r.name) }, WithoutWatch())
+	if !r.noWatch || r.Len() != 3 {
+		t.Fatalf("bad tree: %d", r.Len())
+	}
+	if IndexSlice[byte](nil, func(r record) []byte { return nil }).Len() != 0 {
+		t.Fatalf("expected empty tree")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	// Squares of 0 to 9 keyed by their decimal representation, with the last
+	// key repeated.
+	i := 0
+	r := Generate(func() (Entry[byte, int], bool) {
+		if i > 10 {
+			return Entry[byte, int]{}, false
+		}
+		k := i
+		if i == 10 {
+			k = 3
+		}
+		i++
+		return Entry[byte, int]{Key: []byte(strconv.Itoa(k)), Value: i * i}, true
+	}, WithLazyChannels())
+
+	if r.Len() != 10 || !r.lazyChannels {
+		t.Fatalf("bad tree: %d", r.Len())
+	}
+	for k := 0; k < 10; k++ {
+		expected := (k + 1) * (k + 1)
+		if k == 3 {
+			expected = 11 * 11
+		}
+		if v, ok := r.Get([]byte(strconv.Itoa(k))); !ok || v != expected {
+			t.Fatalf("bad value for %d: %v %v", k, v, ok)
+		}
+	}
+
+	r = Generate(func() (Entry[byte, int], bool) { return Entry[byte, int]{}, false })
+	if r.Len() != 0 {
+		t.Fatalf("expected empty tree")
+	}
+}
+
+func TestInsertTree(t *testing.T) {
+	other := New[byte, int]()
+	for k, v := range map[string]int{"a": 10, "b": 20, "c": 30} {
+		other, _, _ = other.Insert([]byte(k), v)
+	}
+	base, _, _ := New[byte, int]().Insert([]byte("a"), 1)
+
+	txn := base.Txn()
+	txn.Insert([]byte("b"), 2)
+	txn.Insert([]byte("x"), 3)
+	var conflicts []string
+	txn.InsertTree(other, func(k []byte, a, b int) int {
+		conflicts = append(conflicts, string(k))
+		return a + b
+	})
+	txn.Delete([]byte("c"))
+	r := txn.Commit()
+
+	expected := map[string]int{"a": 11, "b": 22, "x": 3}
+	if r.Len() != len(expected) {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	for k, v := range expected {
+		if got, ok := r.Get([]byte(k)); !ok || got != v {
+			t.Fatalf("bad value for %q: %v %v", k, got, ok)
+		}
+	}
+	if !reflect.DeepEqual(conflicts, []string{"a", "b"}) {
+		t.Fatalf("bad conflicts: %v", conflicts)
+	}
+
+	// Without a resolver the merged tree wins.
+	txn = base.Txn()
+	txn.InsertTree(other, nil)
+	if v, _ := txn.Get([]byte("a")); v != 10 {
+		t.Fatalf("bad: %d", v)
+	}
+}
+
+func TestUnionSize(t *testing.T) {
+	build := func(keys ...string) *Tree[byte, int] {
+		r := New[byte, int]()
+		for i, k := range keys {
+			r, _, _ = r.Insert([]byte(k), i)
+		}
+		return r
+	}
+
+	a := build("foo", "foobar", "zip")
+	cases := []struct {
+		name  string
+		other *Tree[byte, int]
+		size  int
+	}{
+		{"empty", New[byte, int](), 3},
+		{"identical", a, 3},
+		{"same keys", build("zip", "foobar", "foo"), 3},
+		{"disjoint", build("", "bar", "fo", "foobaz", "zap"), 8},
+		{"overlapping", build("foo", "foobaz", "zip", "zipzap"), 5},
+	}
+	for _, tc := range cases {
+		if size := a.UnionSize(tc.other); size != tc.size {
+			t.Fatalf("%s: got %d, want %d", tc.name, size, tc.size)
+		}
+		if size := tc.other.UnionSize(a); size != tc.size {
+			t.Fatalf("%s reversed: got %d, want %d", tc.name, size, tc.size)
+		}
+	}
+
+	// Trees derived from one another.
+	b, _, _ := a.Insert([]byte("foobaz"), 10)
+	b, _, _ = b.Delete([]byte("zip"))
+	if size := a.UnionSize(b); size != 4 {
+		t.Fatalf("got %d, want 4", size)
+	}
+}
+
+func TestTreeMerge(t *testing.T) {
+	build := func(kv map[string]int) *Tree[byte, int] {
+		r := New[byte, int]()
+		for k, v := range kv {
+			r, _, _ = r.Insert([]byte(k), v)
+		}
+		return r
+	}
+	small := build(map[string]int{"a": 1, "foo": 2})
+	large := build(map[string]int{"foo": 10, "foobar": 20, "zip": 30})
+	expected := map[string]int{"a": 1, "foo": 2 - 10, "foobar": 20, "zip": 30}
+
+	// The resolver sees the receiver's value first whichever tree is smaller.
+	sub := func(k []byte, a, b int) int { return a - b }
+	for _, r := range []*Tree[byte, int]{small.Merge(large, sub), large.Merge(small, func(k []byte, a, b int) int { return b - a })} {
+		if r.Len() != len(expected) {
+			t.Fatalf("bad len: %d", r.Len())
+		}
+		for k, v := range expected {
+			if got, ok := r.Get([]byte(k)); !ok || got != v {
+				t.Fatalf("bad value for %q: %v %v", k, got, ok)
+			}
+		}
+	}
+
+	// The merged trees are left alone.
+	if small.Len() != 2 || large.Len() != 3 {
+		t.Fatalf("input trees were modified")
+	}
+
+	// Merging with an empty tree changes nothing.
+	if r := New[byte, int]().Merge(large, sub); r.Len() != 3 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+
+	// The resolver runs once per conflicting key, and the untouched nodes
+	// of the larger tree are shared.
+	calls := 0
+	r := large.Merge(small, func(k []byte, a, b int) int {
+		calls++
+		return a
+	})
+	if calls != 1 {
+		t.Fatalf("resolver called %d times", calls)
+	}
+	_, before := large.root.getWatchNode([]byte("zip"))
+	if _, after := r.root.getWatchNode([]byte("zip")); after != before {
+		t.Fatalf("untouched leaf was copied")
+	}
+}
+
+func TestUnionReportingConflicts(t *testing.T) {
+	build := func(kv map[string]int) *Tree[byte, int] {
+		r := New[byte, int]()
+		for k, v := range kv {
+			r, _, _ = r.Insert([]byte(k), v)
+		}
+		return r
+	}
+	a := build(map[string]int{"": 1, "foo": 2, "foobar": 3, "zip": 4})
+	b := build(map[string]int{"fo": 10, "foobar": 30, "zip": 40, "zipzap": 50})
+
+	r, conflicts := a.UnionReportingConflicts(b, func(k []byte, a, b int) int { return a - b })
+	if !reflect.DeepEqual(conflicts, [][]byte{[]byte("foobar"), []byte("zip")}) {
+		t.Fatalf("bad conflicts: %q", conflicts)
+	}
+	expected := map[string]int{"": 1, "fo": 10, "foo": 2, "foobar": -27, "zip": -36, "zipzap": 50}
+	if r.Len() != len(expected) {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	for k, v := range expected {
+		if got, ok := r.Get([]byte(k)); !ok || got != v {
+			t.Fatalf("bad value for %q: %v %v", k, got, ok)
+		}
+	}
+
+	// Every key conflicts with itself, including the ones of shared subtrees.
+	derived, _, _ := a.Insert([]byte("zzz"), 5)
+	_, conflicts = a.UnionReportingConflicts(derived, func(k []byte, a, b int) int { return a })
+	if len(conflicts) != a.Len() {
+		t.Fatalf("bad conflicts: %q", conflicts)
+	}
+	if _, conflicts := a.UnionReportingConflicts(New[byte, int](), nil); conflicts != nil {
+		t.Fatalf("bad conflicts: %q", conflicts)
+	}
+}
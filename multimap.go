@@ -0,0 +1,100 @@
+package iradix
+
+import "slices"
+
+// AppendValue appends e to the slice stored under k, starting from an empty
+// slice if k doesn't exist, for trees used as multimaps. Like Add, it walks
+// the tree once, and is a function because methods can't constrain the value
+// type further than the Txn does. The stored slice is never appended to in
+// place, since earlier versions of the tree may share its backing array, so
+// every append copies it and costs O(len). Build long lists with a single
+// Insert instead.
+func AppendValue[K keyT, E any](t *Txn[K, []E], k []K, e E) {
+	t.upsert(k, nil, func(old []E, _ bool) ([]E, bool) {
+		return append(slices.Clip(old), e), true
+	})
+}
+
+// MultiTree is an immutable tree where a key maps to any number of values,
+// kept in the order they were inserted. It's a tree of value slices, see
+// Tree, that keeps the slices of earlier versions intact and yields every
+// value as an entry of its own.
+type MultiTree[K keyT, T any] struct {
+	tree *Tree[K, []T]
+
+	// size is the number of values, counting each key once per value.
+	size int
+}
+
+// NewMultiTree returns an empty MultiTree. The options are those of the
+// underlying tree, whose values are slices, so options typed by the value
+// type, such as WithAggregator, must be given for []T.
+func NewMultiTree[K keyT, T any](opts ...Option) *MultiTree[K, T] {
+	return &MultiTree[K, T]{tree: New[K, []T](opts...)}
+}
+
+// Tree returns the underlying tree of value slices.
+func (m *MultiTree[K, T]) Tree() *Tree[K, []T] {
+	return m.tree
+}
+
+// Len returns the number of values, counting each key once per value.
+func (m *MultiTree[K, T]) Len() int {
+	return m.size
+}
+
+// InsertMulti adds v to the values of k and returns the new tree.
+func (m *MultiTree[K, T]) InsertMulti(k []K, v T) *MultiTree[K, T] {
+	txn := m.tree.Txn()
+	AppendValue(txn, k, v)
+	return &MultiTree[K, T]{tree: txn.Commit(), size: m.size + 1}
+}
+
+// GetAll returns the values of k in insertion order, or nil if there are none.
+// The slice is shared with the tree and must not be modified, but it can be
+// appended to.
+func (m *MultiTree[K, T]) GetAll(k []K) []T {
+	values, _ := m.tree.Get(k)
+	return slices.Clip(values)
+}
+
+// DeleteValue removes the values of k for which matches returns true, and
+// returns the new tree and the number of values removed. k is deleted once
+// it has no values left.
+func (m *MultiTree[K, T]) DeleteValue(k []K, matches func(T) bool) (*MultiTree[K, T], int) {
+	values, ok := m.tree.Get(k)
+	if !ok {
+		return m, 0
+	}
+	var kept []T
+	for _, v := range values {
+		if !matches(v) {
+			kept = append(kept, v)
+		}
+	}
+	removed := len(values) - len(kept)
+	if removed == 0 {
+		return m, 0
+	}
+	var tree *Tree[K, []T]
+	if len(kept) == 0 {
+		tree, _, _ = m.tree.Delete(k)
+	} else {
+		tree, _, _ = m.tree.Insert(k, kept)
+	}
+	return &MultiTree[K, T]{tree: tree, size: m.size - removed}, removed
+}
+
+// Walk calls fn for every value in key order, and for the values of a key in
+// insertion order, passing the key along with each value. Returning false
+// from fn stops the walk.
+func (m *MultiTree[K, T]) Walk(fn WalkFn[K, T]) {
+	m.tree.root.Walk(func(k []K, values []T) bool {
+		for _, v := range values {
+			if !fn(k, v) {
+				return false
+			}
+		}
+		return true
+	})
+}
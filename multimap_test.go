@@ -0,0 +1,107 @@
+package iradix
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestAppendValue(t *testing.T) {
+	r := New[byte, []int]()
+	txn := r.Txn()
+	AppendValue(txn, []byte("foo"), 1)
+	AppendValue(txn, []byte("foo"), 2)
+	AppendValue(txn, []byte("bar"), 3)
+	v1 := txn.Commit()
+
+	txn = v1.Txn()
+	AppendValue(txn, []byte("foo"), 4)
+	v2 := txn.Commit()
+
+	// Appending to the same version twice mustn't let the appends clobber
+	// each other through a shared backing array.
+	txn = v1.Txn()
+	AppendValue(txn, []byte("foo"), 5)
+	v3 := txn.Commit()
+
+	for _, tc := range []struct {
+		tree     *Tree[byte, []int]
+		key      string
+		expected []int
+	}{
+		{v1, "foo", []int{1, 2}},
+		{v1, "bar", []int{3}},
+		{v2, "foo", []int{1, 2, 4}},
+		{v3, "foo", []int{1, 2, 5}},
+		{v3, "bar", []int{3}},
+	} {
+		if v, _ := tc.tree.Get([]byte(tc.key)); !slices.Equal(v, tc.expected) {
+			t.Fatalf("%q: got %v, want %v", tc.key, v, tc.expected)
+		}
+	}
+	if v1.Len() != 2 || v2.Len() != 2 {
+		t.Fatalf("bad len: %d %d", v1.Len(), v2.Len())
+	}
+}
+
+func TestMultiTree(t *testing.T) {
+	m := NewMultiTree[byte, int]()
+	for i, k := range []string{"foo", "bar", "foo", "foo", "zip"} {
+		m = m.InsertMulti([]byte(k), i)
+	}
+	if m.Len() != 5 || m.Tree().Len() != 3 {
+		t.Fatalf("bad len: %d %d", m.Len(), m.Tree().Len())
+	}
+	if values := m.GetAll([]byte("foo")); !slices.Equal(values, []int{0, 2, 3}) {
+		t.Fatalf("bad values: %v", values)
+	}
+	if values := m.GetAll([]byte("nope")); values != nil {
+		t.Fatalf("bad values: %v", values)
+	}
+
+	// Appending to the returned values doesn't affect the tree.
+	_ = append(m.GetAll([]byte("foo")), 100)
+	m2 := m.InsertMulti([]byte("foo"), 5)
+	if values := m.GetAll([]byte("foo")); !slices.Equal(values, []int{0, 2, 3}) {
+		t.Fatalf("bad values: %v", values)
+	}
+
+	var pairs []string
+	m2.Walk(func(k []byte, v int) bool {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", k, v))
+		return true
+	})
+	if want := []string{"bar=1", "foo=0", "foo=2", "foo=3", "foo=5", "zip=4"}; !slices.Equal(pairs, want) {
+		t.Fatalf("bad walk: %v", pairs)
+	}
+	n := 0
+	m2.Walk(func([]byte, int) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Fatalf("expected walk to stop after 3 values, got %d", n)
+	}
+
+	// Deleting values keeps the rest in order, and the key only goes away
+	// with its last value.
+	m3, removed := m2.DeleteValue([]byte("foo"), func(v int) bool { return v%2 == 0 })
+	if removed != 2 || m3.Len() != 4 || !slices.Equal(m3.GetAll([]byte("foo")), []int{3, 5}) {
+		t.Fatalf("bad delete: %d %d %v", removed, m3.Len(), m3.GetAll([]byte("foo")))
+	}
+	if !slices.Equal(m2.GetAll([]byte("foo")), []int{0, 2, 3, 5}) {
+		t.Fatalf("earlier version was modified")
+	}
+	m4, removed := m3.DeleteValue([]byte("foo"), func(int) bool { return true })
+	if removed != 2 || m4.Len() != 2 || m4.Tree().Len() != 2 {
+		t.Fatalf("bad delete: %d %d %d", removed, m4.Len(), m4.Tree().Len())
+	}
+	if _, ok := m4.Tree().Get([]byte("foo")); ok {
+		t.Fatalf("key without values wasn't deleted")
+	}
+	for _, k := range []string{"zip", "nope"} {
+		if m5, removed := m4.DeleteValue([]byte(k), func(v int) bool { return v == 100 }); m5 != m4 || removed != 0 {
+			t.Fatalf("%q: bad delete of nothing", k)
+		}
+	}
+}
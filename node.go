@@ -4,7 +4,10 @@
 package iradix
 
 import (
+	"context"
+	"slices"
 	"sort"
+	"sync/atomic"
 )
 
 // WalkFn is used when walking the tree. Takes a
@@ -12,11 +15,56 @@ import (
 // be terminated.
 type WalkFn[K keyT, T any] func(k []K, v T) bool
 
+// Entry is a key and its value stored in the tree.
+type Entry[K keyT, T any] struct {
+	Key   []K
+	Value T
+}
+
 // leafNode is used to represent a value
 type leafNode[K keyT, T any] struct {
 	mutateCh chan struct{}
 	key      []K
 	val      T
+
+	// ext is set in trees created with WithCloneKeys or WithAccessTracking,
+	// and is nil otherwise, so that other trees don't pay for it.
+	ext *leafExt
+}
+
+// leafExt is the optional state of a leaf.
+type leafExt struct {
+	// cloneKey is set in trees created with WithCloneKeys.
+	cloneKey bool
+
+	// lastAccess is the tick of the last Tree.Get of the key in trees created
+	// with WithAccessTracking.
+	lastAccess atomic.Int64
+}
+
+// cloneKeyExt is shared by the leaves of trees created with WithCloneKeys
+// but not WithAccessTracking, which don't need their own.
+var cloneKeyExt = &leafExt{cloneKey: true}
+
+// newLeafExt returns the extension for a new leaf of a tree with the options
+// o, which is nil unless they need one.
+func (o *options) newLeafExt() *leafExt {
+	if o.accessNow != nil {
+		return &leafExt{cloneKey: o.cloneKeys}
+	}
+	if o.cloneKeys {
+		return cloneKeyExt
+	}
+	return nil
+}
+
+// publicKey returns the key of the leaf to hand out to callers, which is a
+// copy in trees created with WithCloneKeys, and the stored key otherwise.
+func (l *leafNode[K, T]) publicKey() []K {
+	if l.ext != nil && l.ext.cloneKey {
+		return slices.Clone(l.key)
+	}
+	return l.key
 }
 
 // edge is used to represent an edge node
@@ -40,6 +88,50 @@ type Node[K keyT, T any] struct {
 	// We avoid a fully materialized slice to save memory,
 	// since in most cases we expect to be sparse
 	edges edges[K, T]
+
+	// size is the number of leaves in the subtree rooted at this node.
+	size int
+
+	// ext is set in trees created with WithComparator or WithAggregator, and
+	// is nil otherwise, so that other trees don't pay for it.
+	ext *nodeExt[K]
+
+	// dense indexes the edges of wide nodes in trees created with
+	// WithDenseEdgeIndex, and is nil otherwise.
+	dense *denseIndex
+}
+
+// nodeExt is the optional state of a node. It is never modified, so nodes
+// can share it.
+type nodeExt[K keyT] struct {
+	// cmp orders key elements in trees created with WithComparator, and is
+	// nil otherwise. New nodes inherit it from their parent.
+	cmp func(a, b K) int
+
+	// agg is the aggregate of all values in the subtree rooted at the node.
+	// It is only maintained in trees created with WithAggregator.
+	agg any
+}
+
+// comparator returns the element order of the tree n belongs to, or nil for
+// the natural order.
+func (n *Node[K, T]) comparator() func(a, b K) int {
+	if n.ext == nil {
+		return nil
+	}
+	return n.ext.cmp
+}
+
+// childExt returns the extension for a new node created under n, which keeps
+// the comparator but not the aggregate.
+func (n *Node[K, T]) childExt() *nodeExt[K] {
+	if n.ext == nil || n.ext.agg == nil {
+		return n.ext
+	}
+	if n.ext.cmp == nil {
+		return nil
+	}
+	return &nodeExt[K]{cmp: n.ext.cmp}
 }
 
 func (n *Node[K, T]) cacheableNode() {}
@@ -49,20 +141,67 @@ func (n *Node[K, T]) isLeaf() bool {
 }
 
 func (n *Node[K, T]) findEdge(label K) (idx int, ok bool) {
-	size := len(n.edges)
-	idx = sort.Search(size, func(i int) bool {
-		return n.edges[i].label >= label
-	})
-	ok = idx != size && n.edges[idx].label == label
+	if n.dense != nil {
+		b, _ := denseLabel(label)
+		pos := n.dense[b]
+		return int(pos) - 1, pos > 0
+	}
+	idx, ok = n.findLowerBoundEdge(label)
+	ok = ok && n.edges[idx].label == label
 	return
 }
 
 func (n *Node[K, T]) findLowerBoundEdge(label K) (int, bool) {
+	if n.dense != nil {
+		b, _ := denseLabel(label)
+		if pos := n.dense[b]; pos > 0 {
+			return int(pos - 1), true
+		}
+	}
 	num := len(n.edges)
-	idx := sort.Search(num, func(i int) bool {
+	var idx int
+	if cmp := n.comparator(); cmp != nil {
+		idx = sort.Search(num, func(i int) bool {
+			return cmp(n.edges[i].label, label) >= 0
+		})
+	} else {
+		idx = sort.Search(num, func(i int) bool {
+			return n.edges[i].label >= label
+		})
+	}
+	return idx, idx != num
+}
+
+// probeEdge looks up the child under the edge with the given label like
+// getEdge, additionally returning the number of labels compared.
+func (n *Node[K, T]) probeEdge(label K) (*Node[K, T], int) {
+	probes := 0
+	cmp := n.comparator()
+	idx := sort.Search(len(n.edges), func(i int) bool {
+		probes++
+		if cmp != nil {
+			return cmp(n.edges[i].label, label) >= 0
+		}
 		return n.edges[i].label >= label
 	})
-	return idx, idx != num
+	if idx < len(n.edges) && n.edges[idx].label == label {
+		return n.edges[idx].node, probes
+	}
+	return nil, probes
+}
+
+// compareKeys compares two keys in the order of the tree n belongs to.
+func (n *Node[K, T]) compareKeys(a, b []K) int {
+	return compareKeysFunc(n.comparator(), a, b)
+}
+
+// compareKeysFunc compares two keys with the element order cmp, or the
+// natural order if it's nil.
+func compareKeysFunc[K keyT](cmp func(a, b K) int, a, b []K) int {
+	if cmp != nil {
+		return slices.CompareFunc(a, b, cmp)
+	}
+	return keyCompare(a, b)
 }
 
 func (n *Node[K, T]) addEdge(e edge[K, T]) {
@@ -72,6 +211,9 @@ func (n *Node[K, T]) addEdge(e edge[K, T]) {
 		copy(n.edges[idx+1:], n.edges[idx:])
 		n.edges[idx] = e
 	}
+	if n.dense != nil {
+		n.dense = newDenseIndex(n.edges)
+	}
 }
 
 func (n *Node[K, T]) replaceEdge(e edge[K, T]) {
@@ -98,16 +240,54 @@ func (n *Node[K, T]) delEdge(label K) {
 	copy(n.edges[idx:], n.edges[idx+1:])
 	n.edges[len(n.edges)-1] = edge[K, T]{}
 	n.edges = n.edges[:len(n.edges)-1]
+	if n.dense != nil {
+		n.dense = newDenseIndex(n.edges)
+	}
 }
 
 func (n *Node[K, T]) GetWatch(k []K) (<-chan struct{}, T, bool) {
+	watch, leaf := n.getWatchNode(k)
+	if leaf != nil {
+		return leaf.watch(), leaf.val, true
+	}
+	var zero T
+	return watch.watch(), zero, false
+}
+
+func (n *Node[K, T]) Get(k []K) (T, bool) {
+	_, leaf := n.getWatchNode(k)
+	if leaf != nil {
+		return leaf.val, true
+	}
+	var zero T
+	return zero, false
+}
+
+// LastAccess returns the tick recorded by the last Tree.Get of k in a tree
+// created with WithAccessTracking, or zero if it wasn't read since its value
+// was last written. The bool reports whether k is in the tree.
+func (n *Node[K, T]) LastAccess(k []K) (int64, bool) {
+	if _, leaf := n.getWatchNode(k); leaf != nil {
+		if leaf.ext == nil {
+			return 0, true
+		}
+		return leaf.ext.lastAccess.Load(), true
+	}
+	return 0, false
+}
+
+// getWatchNode looks up the leaf stored at k. If there's no such leaf, it
+// returns the deepest node visited by the search, whose mutation channel
+// should be watched for the key to appear. Channels are not touched here, so
+// plain lookups never allocate lazy channels.
+func (n *Node[K, T]) getWatchNode(k []K) (*Node[K, T], *leafNode[K, T]) {
 	search := k
-	watch := n.mutateCh
+	watch := n
 	for {
 		// Check for key exhaustion
 		if len(search) == 0 {
 			if n.isLeaf() {
-				return n.leaf.mutateCh, n.leaf.val, true
+				return nil, n.leaf
 			}
 			break
 		}
@@ -119,7 +299,7 @@ func (n *Node[K, T]) GetWatch(k []K) (<-chan struct{}, T, bool) {
 		}
 
 		// Update to the finest granularity as the search makes progress
-		watch = n.mutateCh
+		watch = n
 
 		// Consume the search prefix
 		if keyHasPrefix(search, n.prefix) {
@@ -128,13 +308,7 @@ func (n *Node[K, T]) GetWatch(k []K) (<-chan struct{}, T, bool) {
 			break
 		}
 	}
-	var zero T
-	return watch, zero, false
-}
-
-func (n *Node[K, T]) Get(k []K) (T, bool) {
-	_, val, ok := n.GetWatch(k)
-	return val, ok
+	return watch, nil
 }
 
 // LongestPrefix is like Get, but instead of an
@@ -167,17 +341,116 @@ func (n *Node[K, T]) LongestPrefix(k []K) ([]K, T, bool) {
 		}
 	}
 	if last != nil {
-		return last.key, last.val, true
+		return last.publicKey(), last.val, true
 	}
 	var zero T
 	return nil, zero, false
 }
 
+// LongestPrefixWithin is like LongestPrefix, but only considers the keys
+// under scope, and takes and returns keys relative to it: it finds the longest
+// key that starts with scope and is a prefix of scope followed by k, and
+// returns it with scope stripped. Keys above scope, such as a shared parent
+// of several scopes, are never returned.
+func (n *Node[K, T]) LongestPrefixWithin(scope, k []K) ([]K, T, bool) {
+	var zero T
+	search := scope
+	for len(search) > 0 {
+		_, n = n.getEdge(search[0])
+		if n == nil {
+			return nil, zero, false
+		}
+		switch {
+		case keyHasPrefix(search, n.prefix):
+			search = search[len(n.prefix):]
+		case keyHasPrefix(n.prefix, search):
+			// The rest of the node's prefix must be matched by k.
+			rest := n.prefix[len(search):]
+			if !keyHasPrefix(k, rest) {
+				return nil, zero, false
+			}
+			k = k[len(rest):]
+			search = search[:0]
+		default:
+			return nil, zero, false
+		}
+	}
+	key, v, ok := n.LongestPrefix(k)
+	if !ok {
+		return nil, zero, false
+	}
+	return key[len(scope):], v, true
+}
+
+// GetOrLongestPrefix is like LongestPrefix, but also reports whether the match
+// is an exact one, i.e. k itself. Since a stored k is its own longest prefix,
+// this takes a single descent, unlike Get followed by LongestPrefix.
+func (n *Node[K, T]) GetOrLongestPrefix(k []K) ([]K, T, bool, bool) {
+	key, v, ok := n.LongestPrefix(k)
+	return key, v, ok, ok && len(key) == len(k)
+}
+
+// ShortestPrefix is the dual of LongestPrefix, returning the shortest stored
+// key that is a prefix of k, including k itself.
+func (n *Node[K, T]) ShortestPrefix(k []K) ([]K, T, bool) {
+	search := k
+	for {
+		// Look for a leaf node
+		if n.isLeaf() {
+			return n.leaf.publicKey(), n.leaf.val, true
+		}
+
+		// Check for key exhaustion
+		if len(search) == 0 {
+			break
+		}
+
+		// Look for an edge
+		_, n = n.getEdge(search[0])
+		if n == nil {
+			break
+		}
+
+		// Consume the search prefix
+		if keyHasPrefix(search, n.prefix) {
+			search = search[len(n.prefix):]
+		} else {
+			break
+		}
+	}
+	var zero T
+	return nil, zero, false
+}
+
+// prefixSubtree returns a detached copy of the node holding all keys under
+// prefix, with its prefix set to its full effective path, so that it can be
+// walked as a root while still yielding comparable paths. If no key starts
+// with prefix, an empty node is returned.
+func (n *Node[K, T]) prefixSubtree(prefix []K) *Node[K, T] {
+	var path []K
+	search := prefix
+	ext := n.childExt()
+	for len(search) > 0 {
+		_, n = n.getEdge(search[0])
+		if n == nil || !keyHasPrefix(search, n.prefix) && !keyHasPrefix(n.prefix, search) {
+			return &Node[K, T]{ext: ext}
+		}
+		path = append(path, n.prefix...)
+		search = search[min(len(search), len(n.prefix)):]
+	}
+	return &Node[K, T]{
+		leaf:   n.leaf,
+		prefix: path,
+		edges:  n.edges,
+		ext:    n.ext,
+	}
+}
+
 // Minimum is used to return the minimum value in the tree
 func (n *Node[K, T]) Minimum() ([]K, T, bool) {
 	for {
 		if n.isLeaf() {
-			return n.leaf.key, n.leaf.val, true
+			return n.leaf.publicKey(), n.leaf.val, true
 		}
 		if len(n.edges) > 0 {
 			n = n.edges[0].node
@@ -189,26 +462,69 @@ func (n *Node[K, T]) Minimum() ([]K, T, bool) {
 	return nil, zero, false
 }
 
-// Maximum is used to return the maximum value in the tree
+// Maximum is used to return the maximum value in the tree. A leaf stored on a
+// node with edges is never the maximum, since every key under the node's
+// edges extends the leaf's key and so sorts after it, so the maximum is the
+// leaf of the node reached by following the last edge until there are none.
+// That node only lacks a leaf if the tree is empty.
 func (n *Node[K, T]) Maximum() ([]K, T, bool) {
-	for {
-		if num := len(n.edges); num > 0 {
-			n = n.edges[num-1].node // bug?
-			continue
-		}
-		if n.isLeaf() {
-			return n.leaf.key, n.leaf.val, true
-		}
-		break
+	for len(n.edges) > 0 {
+		n = n.edges[len(n.edges)-1].node
+	}
+	if n.isLeaf() {
+		return n.leaf.publicKey(), n.leaf.val, true
 	}
 	var zero T
 	return nil, zero, false
 }
 
+// MinimumWatch is like Minimum, but also returns a watch channel that is
+// closed when the minimum may have changed. If the minimum is n's own entry,
+// no key sorts before it, so that's the channel of its leaf, like GetWatch
+// returns for existing keys. Otherwise a smaller key could be inserted at any
+// node along the path to the minimum, and all of them are copied along with n
+// then, so it's n's channel, which conservatively fires on any change under n.
+// Node channels aren't closed by transactions tracking leaves only, see
+// Txn.TrackMutateLeavesOnly.
+func (n *Node[K, T]) MinimumWatch() (<-chan struct{}, []K, T, bool) {
+	if n.isLeaf() {
+		return n.leaf.watch(), n.leaf.publicKey(), n.leaf.val, true
+	}
+	k, v, ok := n.Minimum()
+	return n.watch(), k, v, ok
+}
+
+// MaximumWatch is like Maximum, but also returns a watch channel that is
+// closed when the maximum may have changed. A larger key could extend the
+// maximum, or be inserted at any node along the path to it, and all of them
+// are copied along with n then, so it's always n's channel, which
+// conservatively fires on any change under n. Node channels aren't closed by
+// transactions tracking leaves only, see Txn.TrackMutateLeavesOnly.
+func (n *Node[K, T]) MaximumWatch() (<-chan struct{}, []K, T, bool) {
+	k, v, ok := n.Maximum()
+	return n.watch(), k, v, ok
+}
+
+// Ceiling returns the smallest key that is greater than or equal to key, and
+// its value. It's a shorthand for SeekLowerBound followed by Next.
+func (n *Node[K, T]) Ceiling(key []K) ([]K, T, bool) {
+	it := n.Iterator()
+	it.SeekLowerBound(key)
+	return it.Next()
+}
+
+// Floor returns the largest key that is less than or equal to key, and its
+// value. It's a shorthand for SeekReverseLowerBound followed by Previous.
+func (n *Node[K, T]) Floor(key []K) ([]K, T, bool) {
+	it := n.ReverseIterator()
+	it.SeekReverseLowerBound(key)
+	return it.Previous()
+}
+
 // Iterator is used to return an iterator at
 // the given node to walk the tree
 func (n *Node[K, T]) Iterator() *Iterator[K, T] {
-	return &Iterator[K, T]{node: n}
+	return &Iterator[K, T]{node: n, cmp: n.comparator()}
 }
 
 // ReverseIterator is used to return an iterator at
@@ -223,6 +539,22 @@ func (n *Node[K, T]) PathIterator(path []K) *PathIterator[K, T] {
 	return &PathIterator[K, T]{node: n, path: path}
 }
 
+// ReversePathIterator is used to return an iterator at the given node to
+// walk the values above the given path, from the path up to the root. Since
+// nodes don't link to their parents, the path is descended once when the
+// iterator is created, collecting its leaves.
+func (n *Node[K, T]) ReversePathIterator(path []K) *ReversePathIterator[K, T] {
+	var leaves []*leafNode[K, T]
+	i := n.PathIterator(path)
+	for i.node != nil {
+		if i.node.leaf != nil {
+			leaves = append(leaves, i.node.leaf)
+		}
+		i.iterate()
+	}
+	return &ReversePathIterator[K, T]{leaves: leaves}
+}
+
 // rawIterator is used to return a raw iterator at the given node to walk the
 // tree.
 func (n *Node[K, T]) rawIterator() *rawIterator[K, T] {
@@ -231,11 +563,93 @@ func (n *Node[K, T]) rawIterator() *rawIterator[K, T] {
 	return iter
 }
 
+// RawWalk visits every node under n in pre-order, leaves or not, together
+// with its effective path: the key a leaf at that node would have. It's an
+// escape hatch for algorithms the package doesn't provide. The nodes are
+// shared with every tree and transaction referring to them, so they must be
+// treated as read-only; mutating them is unsafe. Returning false from fn stops
+// the walk.
+func (n *Node[K, T]) RawWalk(fn func(effectivePath []K, n *Node[K, T]) bool) {
+	for iter := n.rawIterator(); iter.Front() != nil; iter.Next() {
+		if !fn(iter.Path(), iter.Front()) {
+			return
+		}
+	}
+}
+
+// CountPrefix returns the number of keys under the node starting with prefix
+// in O(len(prefix)).
+func (n *Node[K, T]) CountPrefix(prefix []K) int {
+	it := n.Iterator()
+	it.SeekPrefix(prefix)
+	if it.node == nil {
+		return 0
+	}
+	return it.node.size
+}
+
+// UniqueCompletion returns the key starting with prefix and its value if
+// there's exactly one such key, which may be prefix itself, and false if
+// there are none or several. This takes O(len(prefix)) to find the subtree
+// under prefix, whose size tells if it holds a single key, plus the length of
+// the path down to that key.
+func (n *Node[K, T]) UniqueCompletion(prefix []K) ([]K, T, bool) {
+	it := n.Iterator()
+	it.SeekPrefix(prefix)
+	if it.node == nil || it.node.size != 1 {
+		var zero T
+		return nil, zero, false
+	}
+	// A subtree holding a single key can't fork, so the key is at the end of
+	// its only path.
+	n = it.node
+	for n.leaf == nil {
+		n = n.edges[0].node
+	}
+	return n.leaf.publicKey(), n.leaf.val, true
+}
+
 // Walk is used to walk the tree
 func (n *Node[K, T]) Walk(fn WalkFn[K, T]) {
 	recursiveWalk(n, fn)
 }
 
+// walkContextInterval is the number of entries WalkContext visits between
+// checks of the context. Checking is cheap for contexts that are never
+// canceled, but takes a lock for cancelable ones.
+const walkContextInterval = 1024
+
+// WalkContext walks the tree like Walk, checking ctx every 1024 entries and
+// returning its error if it's done. Returning false from fn stops the walk,
+// and WalkContext returns nil then.
+func (n *Node[K, T]) WalkContext(ctx context.Context, fn WalkFn[K, T]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	visited := 0
+	recursiveWalk(n, func(k []K, v T) bool {
+		if visited++; visited%walkContextInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		return fn(k, v)
+	})
+	return err
+}
+
+// WalkDepth walks the tree like Walk, but only down to maxDepth edges below
+// n, passing fn the number of edges between n and each entry, so n's own
+// entry is at depth 0. Deeper subtrees aren't visited at all, so they can be
+// expanded on demand by walking from their nodes. Returning false from fn
+// stops the walk.
+func (n *Node[K, T]) WalkDepth(maxDepth int, fn func(k []K, v T, depth int) bool) {
+	if maxDepth >= 0 {
+		recursiveWalkDepth(n, 0, maxDepth, fn)
+	}
+}
+
 // WalkBackwards is used to walk the tree in reverse order
 func (n *Node[K, T]) WalkBackwards(fn WalkFn[K, T]) {
 	reverseRecursiveWalk(n, fn)
@@ -284,11 +698,25 @@ func (n *Node[K, T]) WalkPath(path []K, fn WalkFn[K, T]) {
 	}
 }
 
+// WalkPathReverse is like WalkPath, but visits the entries from the given
+// path up to the root, so the most specific one comes first, which is what
+// resolving overrides where the most specific rule wins needs. Like WalkPath,
+// the walk stops when fn returns true.
+func (n *Node[K, T]) WalkPathReverse(path []K, fn WalkFn[K, T]) {
+	i := n.ReversePathIterator(path)
+
+	for path, val, ok := i.Previous(); ok; path, val, ok = i.Previous() {
+		if fn(path, val) {
+			return
+		}
+	}
+}
+
 // recursiveWalk is used to do a pre-order walk of a node
 // recursively. Returns true if the walk should be aborted
 func recursiveWalk[K keyT, T any](n *Node[K, T], fn WalkFn[K, T]) bool {
 	// Visit the leaf values if any
-	if n.leaf != nil && !fn(n.leaf.key, n.leaf.val) {
+	if n.leaf != nil && !fn(n.leaf.publicKey(), n.leaf.val) {
 		return false
 	}
 
@@ -301,19 +729,132 @@ func recursiveWalk[K keyT, T any](n *Node[K, T], fn WalkFn[K, T]) bool {
 	return true
 }
 
+// recursiveWalkDepth is used to do a pre-order walk of a node at the given
+// depth, not descending below maxDepth. Returns false if the walk was
+// aborted.
+func recursiveWalkDepth[K keyT, T any](n *Node[K, T], depth, maxDepth int, fn func(k []K, v T, depth int) bool) bool {
+	if n.leaf != nil && !fn(n.leaf.publicKey(), n.leaf.val, depth) {
+		return false
+	}
+	if depth == maxDepth {
+		return true
+	}
+	for _, e := range n.edges {
+		if !recursiveWalkDepth(e.node, depth+1, maxDepth, fn) {
+			return false
+		}
+	}
+	return true
+}
+
 // reverseRecursiveWalk is used to do a reverse pre-order
 // walk of a node recursively. Returns true if the walk
 // should be aborted
 func reverseRecursiveWalk[K keyT, T any](n *Node[K, T], fn WalkFn[K, T]) bool {
+	// Recurse on the children in reverse order, as they are all greater
+	// than the leaf of this node
+	for i := len(n.edges) - 1; i >= 0; i-- {
+		e := n.edges[i]
+		if !reverseRecursiveWalk(e.node, fn) {
+			return false
+		}
+	}
+
 	// Visit the leaf values if any
-	if n.leaf != nil && !fn(n.leaf.key, n.leaf.val) {
+	if n.leaf != nil && !fn(n.leaf.publicKey(), n.leaf.val) {
+		return false
+	}
+	return true
+}
+
+// recursiveWalkTree is used to do a pre-order walk of all nodes, passing
+// structural details to fn. Returns false if the walk was aborted.
+func recursiveWalkTree[K keyT, T any](n *Node[K, T], isLast bool, depth int, fn func(prefix []K, isLeaf bool, val T, isLastChild bool, depth int) bool) bool {
+	var val T
+	if n.isLeaf() {
+		val = n.leaf.val
+	}
+	if !fn(n.prefix, n.isLeaf(), val, isLast, depth) {
 		return false
 	}
 
-	// Recurse on the children in reverse order
-	for i := len(n.edges) - 1; i >= 0; i-- {
-		e := n.edges[i]
-		if !reverseRecursiveWalk(e.node, fn) {
+	// Recurse on the children
+	for i, e := range n.edges {
+		if !recursiveWalkTree(e.node, i == len(n.edges)-1, depth+1, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// walkExcluding walks the keys under n, whose effective path is path, that
+// don't start with any of the excluded prefixes. Returns false if the walk
+// was stopped.
+func walkExcluding[K keyT, T any](n *Node[K, T], path []K, exclude [][]K, fn WalkFn[K, T]) bool {
+	// Only prefixes extending the path can exclude keys further down.
+	var nested [][]K
+	for _, p := range exclude {
+		if keyHasPrefix(path, p) {
+			return true
+		}
+		if keyHasPrefix(p, path) {
+			nested = append(nested, p)
+		}
+	}
+	if len(nested) == 0 {
+		return recursiveWalk(n, fn)
+	}
+
+	if n.leaf != nil && !fn(n.leaf.publicKey(), n.leaf.val) {
+		return false
+	}
+	for _, e := range n.edges {
+		if !walkExcluding(e.node, append(slices.Clip(path), e.node.prefix...), nested, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// distinctAt returns the number of distinct key prefixes of the given length
+// under n, whose effective path has length depth. Nodes whose path reaches the
+// length hold keys sharing a single such prefix, so they aren't descended into.
+func distinctAt[K keyT, T any](n *Node[K, T], depth, length int) int {
+	depth += len(n.prefix)
+	if depth >= length {
+		if n.leaf == nil && len(n.edges) == 0 {
+			return 0
+		}
+		return 1
+	}
+	count := 0
+	for _, e := range n.edges {
+		count += distinctAt(e.node, depth, length)
+	}
+	return count
+}
+
+// strideWalk walks the entries under n in order, visiting one out of every
+// stride entries. skip is the number of entries left to skip before the next
+// visit. Subtrees holding no more than that are skipped using their size.
+// Returns false if the walk was aborted.
+func strideWalk[K keyT, T any](n *Node[K, T], stride int, skip *int, fn WalkFn[K, T]) bool {
+	if *skip >= n.size {
+		*skip -= n.size
+		return true
+	}
+	if n.leaf != nil {
+		if *skip == 0 {
+			if !fn(n.leaf.publicKey(), n.leaf.val) {
+				return false
+			}
+			*skip = stride - 1
+		} else {
+			*skip--
+		}
+	}
+	for _, e := range n.edges {
+		if !strideWalk(e.node, stride, skip, fn) {
 			return false
 		}
 	}
@@ -4,6 +4,8 @@
 package iradix
 
 import (
+	"context"
+	"fmt"
 	"testing"
 )
 
@@ -48,3 +50,488 @@ func TestNodeWalkBackwards(t *testing.T) {
 		return i >= 0
 	})
 }
+
+func TestNodeWalkDepth(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"", "a", "ab", "abc", "abd", "b", "bcd"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	walk := func(n *Node[byte, int], maxDepth int) string {
+		var got []string
+		n.WalkDepth(maxDepth, func(k []byte, _ int, depth int) bool {
+			got = append(got, fmt.Sprintf("%s:%d", k, depth))
+			return true
+		})
+		return fmt.Sprint(got)
+	}
+	cases := []struct {
+		maxDepth int
+		want     string
+	}{
+		{-1, "[]"},
+		{0, "[:0]"},
+		{1, "[:0 a:1 b:1]"},
+		{2, "[:0 a:1 ab:2 b:1 bcd:2]"},
+		{3, "[:0 a:1 ab:2 abc:3 abd:3 b:1 bcd:2]"},
+		{100, "[:0 a:1 ab:2 abc:3 abd:3 b:1 bcd:2]"},
+	}
+	for _, tc := range cases {
+		if got := walk(r.Root(), tc.maxDepth); got != tc.want {
+			t.Fatalf("depth %d: got %s, want %s", tc.maxDepth, got, tc.want)
+		}
+	}
+
+	// Depths are relative to the node walked from.
+	n := r.root.prefixSubtree([]byte("ab"))
+	if got := walk(n, 1); got != "[ab:0 abc:1 abd:1]" {
+		t.Fatalf("bad subtree walk: %s", got)
+	}
+
+	// Stop early.
+	visited := 0
+	r.Root().WalkDepth(3, func([]byte, int, int) bool {
+		visited++
+		return visited < 2
+	})
+	if visited != 2 {
+		t.Fatalf("expected walk to stop after 2 entries, got %d", visited)
+	}
+}
+
+func TestNodeRawWalk(t *testing.T) {
+	r := New[byte, any]()
+	keys := []string{"001", "002", "005", "010", "100", "1", ""}
+	for _, k := range keys {
+		r, _, _ = r.Insert([]byte(k), nil)
+	}
+
+	type visit struct {
+		path string
+		node *Node[byte, any]
+	}
+	var want []visit
+	for iter := r.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		want = append(want, visit{string(iter.Path()), iter.Front()})
+	}
+
+	var got []visit
+	r.Root().RawWalk(func(path []byte, n *Node[byte, any]) bool {
+		got = append(got, visit{string(path), n})
+		return true
+	})
+	if len(got) != len(want) {
+		t.Fatalf("got %d nodes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("node %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// Stop early.
+	n := 0
+	r.Root().RawWalk(func([]byte, *Node[byte, any]) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Fatalf("expected walk to stop after 3 nodes, got %d", n)
+	}
+}
+
+// checkDenseIndexes verifies that every wide node of the tree has a dense index
+// pointing at its edges, and that no other node has one.
+func checkDenseIndexes[T any](t *testing.T, r *Tree[byte, T]) {
+	t.Helper()
+	for iter := r.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		n := iter.Front()
+		if len(n.edges) < denseEdgeThreshold {
+			if n.dense != nil {
+				t.Fatalf("%q: narrow node is indexed", iter.Path())
+			}
+			continue
+		}
+		if n.dense == nil {
+			t.Fatalf("%q: wide node isn't indexed", iter.Path())
+		}
+		indexed := 0
+		for _, pos := range n.dense {
+			if pos > 0 {
+				indexed++
+			}
+		}
+		if indexed != len(n.edges) {
+			t.Fatalf("%q: %d indexed labels for %d edges", iter.Path(), indexed, len(n.edges))
+		}
+		for i, e := range n.edges {
+			if int(n.dense[e.label]) != i+1 {
+				t.Fatalf("%q: bad index for label %d", iter.Path(), e.label)
+			}
+		}
+	}
+}
+
+func TestDenseEdgeIndex(t *testing.T) {
+	seedRand()
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("seed: %d", seed)
+		}
+	})
+
+	dense := New[byte, int](WithDenseEdgeIndex())
+	plain := New[byte, int]()
+	for round := 0; round < 20; round++ {
+		dtxn, ptxn := dense.Txn(), plain.Txn()
+		for i := 0; i < 200; i++ {
+			k := randomBytes(1 + rng.Intn(2))
+			switch rng.Intn(4) {
+			case 0:
+				dtxn.Delete(k)
+				ptxn.Delete(k)
+			case 1:
+				dtxn.DeletePrefix(k[:1])
+				ptxn.DeletePrefix(k[:1])
+			default:
+				dtxn.Insert(k, i)
+				ptxn.Insert(k, i)
+			}
+		}
+		dense, plain = dtxn.Commit(), ptxn.Commit()
+		checkDenseIndexes(t, dense)
+		if !dense.Equal(plain, func(a, b int) bool { return a == b }) {
+			t.Fatalf("round %d: trees differ", round)
+		}
+		for i := 0; i < 100; i++ {
+			k := randomBytes(1 + rng.Intn(2))
+			dv, dok := dense.Get(k)
+			pv, pok := plain.Get(k)
+			if dv != pv || dok != pok {
+				t.Fatalf("round %d: bad lookup of %v", round, k)
+			}
+		}
+	}
+
+	// Batch builds index wide nodes too.
+	var entries []Entry[byte, int]
+	for i := 0; i < 256; i++ {
+		entries = append(entries, Entry[byte, int]{Key: []byte{byte(i), 1}, Value: i})
+	}
+	txn := New[byte, int](WithDenseEdgeIndex()).Txn()
+	txn.InsertBatch(entries)
+	r := txn.Commit()
+	if r.root.dense == nil {
+		t.Fatalf("root isn't indexed")
+	}
+	checkDenseIndexes(t, r)
+
+	// Keys of wider elements aren't indexed.
+	wide := New[int, int](WithDenseEdgeIndex())
+	for i := 0; i < 100; i++ {
+		wide, _, _ = wide.Insert([]int{i}, i)
+	}
+	if wide.root.dense != nil {
+		t.Fatalf("index built for int keys")
+	}
+}
+
+func TestNodeUniqueCompletion(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"foo", "foobar", "foobaz", "zip", "zipper", "hello"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		prefix string
+		key    string
+		ok     bool
+	}{
+		{"h", "hello", true},
+		{"hel", "hello", true},
+		{"hello", "hello", true},
+		{"zipp", "zipper", true},
+		{"foobaz", "foobaz", true},
+		{"", "", false},
+		{"f", "", false},
+		{"fooba", "", false},
+		{"zip", "", false},
+		{"x", "", false},
+		{"hellos", "", false},
+		{"hex", "", false},
+	}
+	for _, tc := range cases {
+		k, v, ok := r.Root().UniqueCompletion([]byte(tc.prefix))
+		if ok != tc.ok || string(k) != tc.key {
+			t.Fatalf("%q: got %q %v, want %q %v", tc.prefix, k, ok, tc.key, tc.ok)
+		}
+		if ok {
+			if expected, _ := r.Get(k); v != expected {
+				t.Fatalf("%q: bad value %d", tc.prefix, v)
+			}
+		}
+	}
+}
+
+func TestNodeFloorCeiling(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "ba"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		key            string
+		floor, ceiling string
+		noFloor        bool
+		noCeiling      bool
+	}{
+		{key: "", floor: "", ceiling: ""},
+		{key: "a", floor: "a", ceiling: "a"},
+		{key: "aa", floor: "a", ceiling: "ab"},
+		{key: "abcd", floor: "abc", ceiling: "abd"},
+		{key: "abz", floor: "abd", ceiling: "b"},
+		{key: "b", floor: "b", ceiling: "b"},
+		{key: "bb", floor: "ba", noCeiling: true},
+	}
+	for _, tc := range cases {
+		k, v, ok := r.Root().Floor([]byte(tc.key))
+		if ok == tc.noFloor || string(k) != tc.floor || (ok && keys[v] != tc.floor) {
+			t.Fatalf("%q: bad floor: %q %v %v", tc.key, k, v, ok)
+		}
+		k, v, ok = r.Root().Ceiling([]byte(tc.key))
+		if ok == tc.noCeiling || string(k) != tc.ceiling || (ok && keys[v] != tc.ceiling) {
+			t.Fatalf("%q: bad ceiling: %q %v %v", tc.key, k, v, ok)
+		}
+	}
+
+	// Without the empty key, nothing is less than or equal to it.
+	r, _, _ = r.Delete(nil)
+	if k, _, ok := r.Root().Floor(nil); ok {
+		t.Fatalf("unexpected floor: %q", k)
+	}
+}
+
+func TestNodeMinimumMaximumWatch(t *testing.T) {
+	r := New[byte, int]()
+	for i, k := range []string{"b", "bb", "c"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	write := func(fn func(txn *Txn[byte, int])) {
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		fn(txn)
+		r = txn.Commit()
+	}
+	fired := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// Inserting a smaller or larger key fires.
+	minWatch, k, v, ok := r.Root().MinimumWatch()
+	if !ok || string(k) != "b" || v != 0 {
+		t.Fatalf("bad minimum: %q %d %v", k, v, ok)
+	}
+	write(func(txn *Txn[byte, int]) { txn.Insert([]byte("a"), 3) })
+	if !fired(minWatch) {
+		t.Fatalf("minimum watch didn't fire")
+	}
+	maxWatch, k, v, ok := r.Root().MaximumWatch()
+	if !ok || string(k) != "c" || v != 2 {
+		t.Fatalf("bad maximum: %q %d %v", k, v, ok)
+	}
+	write(func(txn *Txn[byte, int]) { txn.Insert([]byte("cc"), 4) })
+	if !fired(maxWatch) {
+		t.Fatalf("maximum watch didn't fire")
+	}
+
+	// With the empty key as the minimum, only its own changes fire.
+	write(func(txn *Txn[byte, int]) { txn.Insert(nil, 5) })
+	minWatch, k, _, _ = r.Root().MinimumWatch()
+	if string(k) != "" {
+		t.Fatalf("bad minimum: %q", k)
+	}
+	write(func(txn *Txn[byte, int]) { txn.Delete([]byte("a")) })
+	if fired(minWatch) {
+		t.Fatalf("minimum watch fired for another key")
+	}
+	write(func(txn *Txn[byte, int]) { txn.Delete(nil) })
+	if !fired(minWatch) {
+		t.Fatalf("minimum watch didn't fire")
+	}
+
+	// An empty tree fires on the first insert.
+	empty := New[byte, int]()
+	minWatch, _, _, ok = empty.Root().MinimumWatch()
+	maxWatch, _, _, _ = empty.Root().MaximumWatch()
+	if ok {
+		t.Fatalf("empty tree has a minimum")
+	}
+	txn := empty.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("x"), 1)
+	txn.Commit()
+	if !fired(minWatch) || !fired(maxWatch) {
+		t.Fatalf("empty tree watches didn't fire")
+	}
+}
+
+func TestNodeGetOrLongestPrefix(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"", "foo", "foobar", "zip"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		key   string
+		match string
+		exact bool
+	}{
+		{key: "", match: "", exact: true},
+		{key: "f", match: ""},
+		{key: "foo", match: "foo", exact: true},
+		{key: "foob", match: "foo"},
+		{key: "foobar", match: "foobar", exact: true},
+		{key: "foobarbaz", match: "foobar"},
+		{key: "zi", match: ""},
+		{key: "zip", match: "zip", exact: true},
+	}
+	for _, tc := range cases {
+		k, v, ok, exact := r.Root().GetOrLongestPrefix([]byte(tc.key))
+		if !ok || string(k) != tc.match || keys[v] != tc.match || exact != tc.exact {
+			t.Fatalf("%q: got %q %d %v %v", tc.key, k, v, ok, exact)
+		}
+	}
+
+	r, _, _ = r.Delete(nil)
+	if k, _, ok, exact := r.Root().GetOrLongestPrefix([]byte("f")); ok || exact {
+		t.Fatalf("unexpected match: %q", k)
+	}
+}
+
+func TestNodeLongestPrefixWithin(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"", "tenants/", "tenants/a/", "tenants/a/x", "tenants/ab/", "tenants/b/y/"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		scope, key string
+		match      string
+		ok         bool
+	}{
+		{"tenants/a/", "", "", true},
+		{"tenants/a/", "xyz", "x", true},
+		{"tenants/a/", "y", "", true},
+		{"tenants/a", "/x", "/x", true},
+		{"tenants/a", "b/z", "b/", true},
+		// The keys above the scope don't match.
+		{"tenants/b/", "z", "", false},
+		{"tenants/b/", "y/z", "y/", true},
+		{"tenants/b", "/", "", false},
+		{"tenants/c/", "x", "", false},
+		{"tenants/ab", "c", "", false},
+		{"", "tenants/c", "tenants/", true},
+		{"", "x", "", true},
+	}
+	for _, tc := range cases {
+		k, v, ok := r.Root().LongestPrefixWithin([]byte(tc.scope), []byte(tc.key))
+		if ok != tc.ok || string(k) != tc.match || ok && keys[v] != tc.scope+tc.match {
+			t.Fatalf("%q %q: got %q %d %v", tc.scope, tc.key, k, v, ok)
+		}
+	}
+}
+
+func TestNodeWalkContext(t *testing.T) {
+	txn := New[byte, int]().Txn()
+	for i := 0; i < 10000; i++ {
+		txn.Insert([]byte(fmt.Sprintf("%05d", i)), i)
+	}
+	r := txn.Commit()
+
+	visited := 0
+	if err := r.Root().WalkContext(context.Background(), func([]byte, int) bool {
+		visited++
+		return true
+	}); err != nil || visited != r.Len() {
+		t.Fatalf("bad walk: %v %d", err, visited)
+	}
+
+	// Canceling stops the walk at the next check.
+	ctx, cancel := context.WithCancel(context.Background())
+	visited = 0
+	err := r.Root().WalkContext(ctx, func([]byte, int) bool {
+		if visited++; visited == 3000 {
+			cancel()
+		}
+		return true
+	})
+	if err != context.Canceled || visited != 3*walkContextInterval-1 {
+		t.Fatalf("bad canceled walk: %v %d", err, visited)
+	}
+	if err := r.Root().WalkContext(ctx, func([]byte, int) bool {
+		t.Fatalf("walked with a done context")
+		return true
+	}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// Stopping the walk isn't an error.
+	if err := r.Root().WalkContext(context.Background(), func([]byte, int) bool { return false }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNodeExt(t *testing.T) {
+	keys := []string{"", "foo", "foobar", "foozip", "zip"}
+	build := func(opts ...Option) *Tree[byte, int] {
+		r := New[byte, int](opts...)
+		for i, k := range keys {
+			r, _, _ = r.Insert([]byte(k), i)
+		}
+		return r
+	}
+	exts := func(r *Tree[byte, int]) (nodes map[*nodeExt[byte]]int, leaves map[*leafExt]int) {
+		nodes, leaves = make(map[*nodeExt[byte]]int), make(map[*leafExt]int)
+		r.Root().RawWalk(func(_ []byte, n *Node[byte, int]) bool {
+			nodes[n.ext]++
+			if n.leaf != nil {
+				leaves[n.leaf.ext]++
+			}
+			return true
+		})
+		return nodes, leaves
+	}
+
+	// Trees without options needing them don't allocate extensions.
+	nodes, leaves := exts(build())
+	if len(nodes) != 1 || nodes[nil] == 0 || len(leaves) != 1 || leaves[nil] != len(keys) {
+		t.Fatalf("unexpected extensions: %v %v", nodes, leaves)
+	}
+
+	// Nodes share the comparator's extension, and leaves share the one of
+	// WithCloneKeys.
+	nodes, leaves = exts(build(WithComparator(func(a, b byte) int { return int(a) - int(b) }), WithCloneKeys()))
+	if len(nodes) != 1 || nodes[nil] != 0 || len(leaves) != 1 || leaves[cloneKeyExt] != len(keys) {
+		t.Fatalf("unexpected extensions: %v %v", nodes, leaves)
+	}
+
+	// Aggregates and access ticks are per node and per leaf.
+	nodes, leaves = exts(build(WithAggregator(0, func(a, v int) int { return a + v }, func(a, b int) int { return a + b }),
+		WithAccessTracking(func() int64 { return 1 })))
+	for ext, count := range nodes {
+		if ext == nil || count != 1 {
+			t.Fatalf("unexpected extensions: %v", nodes)
+		}
+	}
+	if len(leaves) != len(keys) {
+		t.Fatalf("unexpected extensions: %v", leaves)
+	}
+}
@@ -0,0 +1,22 @@
+package iradix
+
+// Number is a constraint for value types that support addition.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Add adds delta to the value stored under k, starting from zero if k doesn't
+// exist, and returns the new total. Unlike a Get followed by an Insert, this
+// walks the tree once. It's a function rather than a Txn method because
+// methods can't constrain the value type further than the Txn does.
+func Add[K keyT, T Number](t *Txn[K, T], k []K, delta T) T {
+	var total T
+	t.upsert(k, delta, func(old T, _ bool) (T, bool) {
+		// old is zero for new keys
+		total = old + delta
+		return total, true
+	})
+	return total
+}
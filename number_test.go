@@ -0,0 +1,41 @@
+package iradix
+
+import (
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	txn := New[byte, int]().Txn()
+	if total := Add(txn, []byte("hits"), 3); total != 3 {
+		t.Fatalf("bad total: %d", total)
+	}
+	if total := Add(txn, []byte("hits"), 4); total != 7 {
+		t.Fatalf("bad total: %d", total)
+	}
+	if total := Add(txn, []byte("misses"), -2); total != -2 {
+		t.Fatalf("bad total: %d", total)
+	}
+	r := txn.Commit()
+	if v, _ := r.Get([]byte("hits")); v != 7 || r.Len() != 2 {
+		t.Fatalf("bad: %d %d", v, r.Len())
+	}
+
+	ftxn := New[byte, float64]().Txn()
+	Add(ftxn, []byte("load"), 0.5)
+	if total := Add(ftxn, []byte("load"), 0.25); total != 0.75 {
+		t.Fatalf("bad total: %v", total)
+	}
+	if v, ok := ftxn.Get([]byte("load")); !ok || v != 0.75 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+
+	// Stored zero values count as existing keys.
+	utxn := New[byte, uint8]().Txn()
+	utxn.Insert([]byte("z"), 0)
+	if total := Add(utxn, []byte("z"), 255); total != 255 {
+		t.Fatalf("bad total: %d", total)
+	}
+	if utxn.size != 1 {
+		t.Fatalf("bad size: %d", utxn.size)
+	}
+}
@@ -11,8 +11,23 @@ var defaultOptions = options{
 }
 
 type options struct {
-	cacheProvider CacheProvider
-	channelLimit  int
+	cacheProvider  CacheProvider
+	channelLimit   int
+	lazyChannels   bool
+	noWatch        bool
+	frequencyHint  bool
+	aggregator     any
+	keyRange       any
+	rejectEmptyKey bool
+	bloomN         int
+	bloomFP        float64
+	notifyTrace    func(closed, compared int)
+	accessNow      func() int64
+	comparator     any
+	denseEdges     bool
+	valueCodec     any
+	tracer         Tracer
+	cloneKeys      bool
 }
 
 type Option func(o *options)
@@ -28,3 +43,99 @@ func WithChannelLimit(limit int) Option {
 		o.channelLimit = limit
 	}
 }
+
+// WithLazyChannels defers allocation of watch channels until they are first
+// requested via GetWatch or SeekPrefixWatch, or tracked by a transaction with
+// TrackMutate enabled. This saves two allocations per created node when
+// building trees that are never watched, at the cost of an allocation on the
+// first watch of every node.
+func WithLazyChannels() Option {
+	return func(o *options) {
+		o.lazyChannels = true
+	}
+}
+
+// WithoutWatch disables watching. Every node and leaf shares a single,
+// already closed channel instead of allocating its own, so GetWatch and
+// SeekPrefixWatch return a channel that is always closed, meaning the result
+// may be stale at any time. TrackMutate has nothing to notify in such trees.
+// This saves the same allocations as WithLazyChannels without any cost on
+// the first watch, and takes precedence over it.
+func WithoutWatch() Option {
+	return func(o *options) {
+		o.noWatch = true
+	}
+}
+
+// WithFrequencyHint makes Tree.Get cache the most followed edges of wide
+// nodes and check them before searching the edges. Every Get pays for a
+// random draw and some of them for recording samples, so this only helps
+// heavily skewed lookups on very wide nodes; measure before enabling it.
+func WithFrequencyHint() Option {
+	return func(o *options) {
+		o.frequencyHint = true
+	}
+}
+
+// WithNotifyTrace calls fn at the end of every Txn.Notify on a transaction
+// with TrackMutate enabled, reporting the number of watch channels closed. If
+// the transaction tracked more channels than the channel limit, Notify falls
+// back to comparing the old and new trees, and compared is the number of node
+// paths it compared; it's zero otherwise. This is meant for debugging watches
+// that don't fire, and costs nothing when not set.
+func WithNotifyTrace(fn func(closed, compared int)) Option {
+	return func(o *options) {
+		o.notifyTrace = fn
+	}
+}
+
+// WithAccessTracking makes Tree.Get record the tick returned by now on the
+// leaf of every key it finds, which Node.LastAccess reports. This is meant
+// for caching layers looking for cold entries to evict. Leaves are shared by
+// all versions of a tree holding the same value, so a read through any of
+// them counts for all, and writing a key resets its tick to zero. Ticks are
+// stored atomically, so concurrent reads stay safe, but now is called from
+// every reading goroutine and must be safe for concurrent use. Reads other
+// than Get, such as iteration, aren't tracked.
+func WithAccessTracking(now func() int64) Option {
+	return func(o *options) {
+		o.accessNow = now
+	}
+}
+
+// WithComparator orders key elements with cmp instead of their natural order,
+// e.g. to order signed integers by their unsigned interpretation. It must
+// return a negative number, zero or a positive number if a is less than,
+// equal to or greater than b, and zero only for equal elements. It applies to
+// edge order, and with it iteration, seeking, Minimum and Maximum, and to all
+// comparisons of keys. Every node of the tree carries it, so trees created
+// with different comparators must never be merged or compared with each
+// other, and all trees passed to functions taking several trees must use the
+// same one. The key type K must match the tree's, otherwise New panics.
+func WithComparator[K keyT](cmp func(a, b K) int) Option {
+	return func(o *options) {
+		o.comparator = cmp
+	}
+}
+
+// WithCloneKeys makes the tree copy keys when they're inserted and when
+// they're returned, so callers may keep and modify them. This costs an
+// allocation for every key inserted or returned.
+func WithCloneKeys() Option {
+	return func(o *options) {
+		o.cloneKeys = true
+	}
+}
+
+// WithDenseEdgeIndex gives nodes with many edges a direct index from the
+// label to the edge, which replaces the binary search over the edges on
+// lookups. This only applies to keys of single byte elements, such as
+// []byte, and is a no-op otherwise. Every indexed node takes an extra 512
+// bytes, and adding or removing one of its edges rebuilds the index, so this
+// pays off for read-heavy trees with wide nodes, see BenchmarkWideGet in the
+// benchmark module.
+func WithDenseEdgeIndex() Option {
+	return func(o *options) {
+		o.denseEdges = true
+	}
+}
@@ -28,7 +28,7 @@ func (i *PathIterator[K, T]) Next() ([]K, T, bool) {
 	}
 
 	if leaf != nil {
-		return leaf.key, leaf.val, true
+		return leaf.publicKey(), leaf.val, true
 	}
 
 	return nil, zero, false
@@ -57,3 +57,26 @@ func (i *PathIterator[K, T]) iterate() {
 		i.node = nil
 	}
 }
+
+// ReversePathIterator is used to iterate over the same values as
+// PathIterator, but from the given path up to the root, so the longest key
+// comes first. This will iterate over the same values that the
+// Node.WalkPathReverse method will.
+type ReversePathIterator[K keyT, T any] struct {
+	// leaves holds the leaves on the path from the root down, which are
+	// returned from the end.
+	leaves []*leafNode[K, T]
+}
+
+// Previous returns the previous value, that is the leaf of the next node up
+// the path.
+func (i *ReversePathIterator[K, T]) Previous() ([]K, T, bool) {
+	var zero T
+	n := len(i.leaves)
+	if n == 0 {
+		return nil, zero, false
+	}
+	leaf := i.leaves[n-1]
+	i.leaves = i.leaves[:n-1]
+	return leaf.publicKey(), leaf.val, true
+}
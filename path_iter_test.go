@@ -5,6 +5,7 @@ package iradix
 
 import (
 	"reflect"
+	"slices"
 	"sort"
 	"testing"
 )
@@ -105,3 +106,63 @@ func TestPathIterator(t *testing.T) {
 		}
 	}
 }
+
+func TestReversePathIterator(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{
+		"",
+		"foo",
+		"foo/bar",
+		"foo/bar/baz",
+		"foo/baz/bar",
+		"zipzap",
+	}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	root := r.Root()
+	for _, path := range []string{"", "f", "foo", "foo/", "foo/bar", "foo/bar/bazoo", "foo/baz/bar", "z", "zipzap!"} {
+		// The reverse iterator returns what the path iterator does, backwards.
+		var expected []string
+		iter := root.PathIterator([]byte(path))
+		for k, _, ok := iter.Next(); ok; k, _, ok = iter.Next() {
+			expected = append(expected, string(k))
+		}
+		slices.Reverse(expected)
+
+		var out []string
+		riter := root.ReversePathIterator([]byte(path))
+		for k, v, ok := riter.Previous(); ok; k, v, ok = riter.Previous() {
+			if keys[v] != string(k) {
+				t.Fatalf("%q: bad value for %q: %d", path, k, v)
+			}
+			out = append(out, string(k))
+		}
+		if !slices.Equal(out, expected) {
+			t.Fatalf("%q: got %q, want %q", path, out, expected)
+		}
+		if _, _, ok := riter.Previous(); ok {
+			t.Fatalf("%q: iteration returned a value after it was complete", path)
+		}
+
+		out = nil
+		root.WalkPathReverse([]byte(path), func(k []byte, _ int) bool {
+			out = append(out, string(k))
+			return false
+		})
+		if !slices.Equal(out, expected) {
+			t.Fatalf("%q: walk got %q, want %q", path, out, expected)
+		}
+	}
+
+	// The most specific entry comes first, and returning true stops the walk.
+	var out []string
+	root.WalkPathReverse([]byte("foo/bar/baz"), func(k []byte, _ int) bool {
+		out = append(out, string(k))
+		return true
+	})
+	if !slices.Equal(out, []string{"foo/bar/baz"}) {
+		t.Fatalf("bad: %q", out)
+	}
+}
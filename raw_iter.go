@@ -82,3 +82,14 @@ func (i *rawIterator[K, T]) Next() {
 	i.pos = nil
 	i.path = i.path[:0]
 }
+
+// SkipChildren prevents the iterator from descending into the children of the
+// current node, so the next call to Next moves on to its next sibling.
+func (i *rawIterator[K, T]) SkipChildren() {
+	if i.pos == nil || len(i.pos.edges) == 0 {
+		return
+	}
+	// Next pushes the children of the current node onto the top of the stack,
+	// so we only need to pop them off.
+	i.stack = i.stack[:len(i.stack)-1]
+}
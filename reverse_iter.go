@@ -22,7 +22,7 @@ type ReverseIterator[K keyT, T any] struct {
 // NewReverseIterator returns a new ReverseIterator at a node
 func NewReverseIterator[K keyT, T any](n *Node[K, T]) *ReverseIterator[K, T] {
 	return &ReverseIterator[K, T]{
-		i: &Iterator[K, T]{node: n},
+		i: &Iterator[K, T]{node: n, cmp: n.comparator()},
 	}
 }
 
@@ -34,7 +34,7 @@ func (ri *ReverseIterator[K, T]) SeekPrefixWatch(prefix []K) (watch <-chan struc
 
 // SeekPrefix is used to seek the iterator to a given prefix
 func (ri *ReverseIterator[K, T]) SeekPrefix(prefix []K) {
-	ri.i.SeekPrefixWatch(prefix)
+	ri.i.SeekPrefix(prefix)
 }
 
 // SeekReverseLowerBound is used to seek the iterator to the largest key that is
@@ -76,9 +76,9 @@ func (ri *ReverseIterator[K, T]) SeekReverseLowerBound(key []K) {
 		// Compare current prefix with the search key's same-length prefix.
 		var prefixCmp int
 		if len(n.prefix) < len(search) {
-			prefixCmp = keyCompare(n.prefix, search[0:len(n.prefix)])
+			prefixCmp = n.compareKeys(n.prefix, search[0:len(n.prefix)])
 		} else {
-			prefixCmp = keyCompare(n.prefix, search)
+			prefixCmp = n.compareKeys(n.prefix, search)
 		}
 
 		if prefixCmp < 0 {
@@ -219,7 +219,7 @@ func (ri *ReverseIterator[K, T]) Previous() ([]K, T, bool) {
 
 		// If this is a leaf, return it
 		if elem.leaf != nil {
-			return elem.leaf.key, elem.leaf.val, true
+			return elem.leaf.publicKey(), elem.leaf.val, true
 		}
 
 		// it's not a leaf so keep walking the stack to find the previous leaf
@@ -0,0 +1,125 @@
+package iradix
+
+import "math/rand"
+
+// weightSum is the aggregator installed by WithWeightSum. It's a type of its
+// own so WeightedSample can tell that the aggregates are weight sums.
+type weightSum[T any] struct {
+	weight func(T) float64
+}
+
+func (w *weightSum[T]) aggregate(leaf *T, children func(yield func(agg any))) any {
+	var sum float64
+	if leaf != nil {
+		sum = positiveWeight(w.weight(*leaf))
+	}
+	children(func(agg any) {
+		sum += agg.(float64)
+	})
+	return sum
+}
+
+// WithWeightSum is an aggregator, see WithAggregator, maintaining the sum of
+// the weights of all values under every node as a float64, with weights
+// below zero counting as zero. It makes WeightedSample take O(depth) instead
+// of O(n). It replaces any aggregator set with WithAggregator, and vice
+// versa. The value type T must match the tree's, otherwise New panics.
+func WithWeightSum[T any](weight func(T) float64) Option {
+	return func(o *options) {
+		o.aggregator = &weightSum[T]{weight: weight}
+	}
+}
+
+// positiveWeight returns w, or zero if it's not positive.
+func positiveWeight(w float64) float64 {
+	if w > 0 {
+		return w
+	}
+	return 0
+}
+
+// WeightedSample picks an entry of t at random, with a probability
+// proportional to the weight of its value. Entries weighing zero or less are
+// never picked, and false is returned if there are no others. The same seed
+// picks the same entry from the same tree. If t was created with
+// WithWeightSum, the subtree sums it maintains are used to descend to the
+// entry in O(depth), and weight must be the same function. Otherwise all
+// entries are weighed in a single O(n) pass.
+func WeightedSample[K keyT, T any](t *Tree[K, T], weight func(T) float64, seed uint64) ([]K, T, bool) {
+	rng := rand.New(rand.NewSource(int64(seed)))
+	if _, ok := t.aggregator.(*weightSum[T]); ok {
+		return sampleWeightSum(t.root, weight, rng)
+	}
+
+	// Keep every entry with the probability of its share of the weight seen
+	// so far, so each is finally kept with its share of the total.
+	var picked *leafNode[K, T]
+	var total float64
+	for iter := t.root.rawIterator(); iter.Front() != nil; iter.Next() {
+		leaf := iter.Front().leaf
+		if leaf == nil {
+			continue
+		}
+		w := positiveWeight(weight(leaf.val))
+		if w == 0 {
+			continue
+		}
+		total += w
+		if rng.Float64()*total < w {
+			picked = leaf
+		}
+	}
+	if picked == nil {
+		var zero T
+		return nil, zero, false
+	}
+	return picked.publicKey(), picked.val, true
+}
+
+// sampleWeightSum descends from n to a random entry, following children with
+// a probability proportional to their weight sums.
+func sampleWeightSum[K keyT, T any](n *Node[K, T], weight func(T) float64, rng *rand.Rand) ([]K, T, bool) {
+	var zero T
+	total, _ := AggregateOf[float64](n)
+	if total <= 0 {
+		return nil, zero, false
+	}
+	r := rng.Float64() * total
+	for {
+		var w float64
+		if n.leaf != nil {
+			w = positiveWeight(weight(n.leaf.val))
+			if r < w {
+				return n.leaf.publicKey(), n.leaf.val, true
+			}
+			r -= w
+		}
+
+		// Rounding can leave r beyond the last sum, so fall back to the
+		// last child weighing anything.
+		var next, last *Node[K, T]
+		for _, e := range n.edges {
+			sum, _ := AggregateOf[float64](e.node)
+			if sum <= 0 {
+				continue
+			}
+			last = e.node
+			if r < sum {
+				next = e.node
+				break
+			}
+			r -= sum
+		}
+		if next == nil {
+			next = last
+			r = 0
+		}
+		if next == nil {
+			if w > 0 {
+				return n.leaf.publicKey(), n.leaf.val, true
+			}
+			return nil, zero, false
+		}
+		n = next
+	}
+}
@@ -0,0 +1,88 @@
+package iradix
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestWeightedSample(t *testing.T) {
+	weight := func(v int) float64 { return float64(v) }
+	for _, opts := range [][]Option{nil, {WithWeightSum(weight)}} {
+		r := New[byte, int](opts...)
+		txn := r.Txn()
+		// Values are the weights, spread over leaves and internal nodes.
+		txn.Insert([]byte(""), 1)
+		txn.Insert([]byte("a"), 2)
+		txn.Insert([]byte("ab"), 3)
+		txn.Insert([]byte("abc"), 4)
+		txn.Insert([]byte("b"), 0)
+		txn.Insert([]byte("c"), -5)
+		txn.Insert([]byte("d"), 10)
+		r = txn.Commit()
+		const total = 20
+
+		const n = 20000
+		counts := make(map[string]int)
+		for seed := uint64(0); seed < n; seed++ {
+			k, v, ok := WeightedSample(r, weight, seed)
+			if !ok {
+				t.Fatalf("nothing picked")
+			}
+			if got, _ := r.Get(k); got != v {
+				t.Fatalf("bad value for %q: %d", k, v)
+			}
+			counts[string(k)]++
+		}
+		for _, k := range []string{"", "a", "ab", "abc", "b", "c", "d"} {
+			w, _ := r.Get([]byte(k))
+			expected := n * positiveWeight(float64(w)) / total
+			// Allow five standard deviations of the binomial distribution.
+			p := expected / n
+			tolerance := 5 * math.Sqrt(n*p*(1-p))
+			if got := float64(counts[k]); math.Abs(got-expected) > tolerance {
+				t.Fatalf("%v: %q picked %v times, expected %v±%v", opts != nil, k, got, expected, tolerance)
+			}
+		}
+
+		// The same seed picks the same entry.
+		k1, _, _ := WeightedSample(r, weight, 42)
+		k2, _, _ := WeightedSample(r, weight, 42)
+		if string(k1) != string(k2) {
+			t.Fatalf("same seed picked %q and %q", k1, k2)
+		}
+	}
+}
+
+func TestWeightedSample_Nothing(t *testing.T) {
+	weight := func(v int) float64 { return float64(v) }
+	for _, opts := range [][]Option{nil, {WithWeightSum(weight)}} {
+		r := New[byte, int](opts...)
+		if _, _, ok := WeightedSample(r, weight, 1); ok {
+			t.Fatalf("picked from an empty tree")
+		}
+		for i := 0; i < 10; i++ {
+			r, _, _ = r.Insert([]byte(fmt.Sprint(i)), -i)
+		}
+		if k, _, ok := WeightedSample(r, weight, 1); ok {
+			t.Fatalf("picked %q weighing nothing", k)
+		}
+	}
+}
+
+func TestWithWeightSum(t *testing.T) {
+	r := New[byte, float64](WithWeightSum(func(v float64) float64 { return v }))
+	for i, k := range []string{"foo", "foobar", "zip"} {
+		r, _, _ = r.Insert([]byte(k), float64(i+1))
+	}
+	if agg := r.Root().Aggregate(); agg != 6.0 {
+		t.Fatalf("bad root aggregate: %v", agg)
+	}
+	if agg := r.AggregatePrefix([]byte("foo")); agg != 3.0 {
+		t.Fatalf("bad prefix aggregate: %v", agg)
+	}
+	r, _, _ = r.Delete([]byte("foo"))
+	if agg := r.Root().Aggregate(); agg != 5.0 {
+		t.Fatalf("bad root aggregate: %v", agg)
+	}
+}
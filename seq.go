@@ -0,0 +1,80 @@
+//go:build go1.23
+
+package iradix
+
+import (
+	"iter"
+)
+
+// All returns an iterator over all keys under the node and their values, in
+// key order, for use with range-over-func.
+func (n *Node[K, T]) All() iter.Seq2[[]K, T] {
+	return func(yield func([]K, T) bool) {
+		n.Walk(yield)
+	}
+}
+
+// Prefix returns an iterator over the keys under the node starting with
+// prefix and their values, in key order.
+func (n *Node[K, T]) Prefix(prefix []K) iter.Seq2[[]K, T] {
+	return func(yield func([]K, T) bool) {
+		n.WalkPrefix(prefix, yield)
+	}
+}
+
+// Backwards returns an iterator over all keys under the node and their values,
+// in reverse key order.
+func (n *Node[K, T]) Backwards() iter.Seq2[[]K, T] {
+	return func(yield func([]K, T) bool) {
+		n.WalkBackwards(yield)
+	}
+}
+
+// Range returns an iterator over the keys under the node from start,
+// inclusive, to end, exclusive, and their values, in key order. An empty end
+// means there's no upper bound.
+func (n *Node[K, T]) Range(start, end []K) iter.Seq2[[]K, T] {
+	return func(yield func([]K, T) bool) {
+		it := n.Iterator()
+		it.SeekLowerBound(start)
+		it.SetUpperBound(end)
+		for k, v, ok := it.Next(); ok; k, v, ok = it.Next() {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over all keys in the tree and their values, in key
+// order, for use with range-over-func.
+func (t *Tree[K, T]) All() iter.Seq2[[]K, T] {
+	return t.root.All()
+}
+
+// Prefix returns an iterator over the keys in the tree starting with prefix
+// and their values, in key order.
+func (t *Tree[K, T]) Prefix(prefix []K) iter.Seq2[[]K, T] {
+	return t.root.Prefix(prefix)
+}
+
+// Backwards returns an iterator over all keys in the tree and their values,
+// in reverse key order.
+func (t *Tree[K, T]) Backwards() iter.Seq2[[]K, T] {
+	return t.root.Backwards()
+}
+
+// Range returns an iterator over the keys in the tree from start, inclusive,
+// to end, exclusive, and their values, in key order. An empty end means
+// there's no upper bound.
+func (t *Tree[K, T]) Range(start, end []K) iter.Seq2[[]K, T] {
+	return t.root.Range(start, end)
+}
+
+// All returns an iterator over every value in the tree along with its key, in
+// key order, and for the values of a key in insertion order.
+func (m *MultiTree[K, T]) All() iter.Seq2[[]K, T] {
+	return func(yield func([]K, T) bool) {
+		m.Walk(yield)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build go1.23
+
+package iradix
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSeq(t *testing.T) {
+	r := New[byte, int]()
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "ba"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	collect := func(seq func(func([]byte, int) bool)) []string {
+		var out []string
+		for k, v := range seq {
+			if keys[v] != string(k) {
+				t.Fatalf("bad value for %q: %d", k, v)
+			}
+			out = append(out, string(k))
+		}
+		return out
+	}
+
+	if out := collect(r.All()); !slices.Equal(out, keys) {
+		t.Fatalf("bad all: %v", out)
+	}
+	reversed := slices.Clone(keys)
+	slices.Reverse(reversed)
+	if out := collect(r.Backwards()); !slices.Equal(out, reversed) {
+		t.Fatalf("bad backwards: %v", out)
+	}
+	if out := collect(r.Prefix([]byte("ab"))); !slices.Equal(out, []string{"ab", "abc", "abd"}) {
+		t.Fatalf("bad prefix: %v", out)
+	}
+	if out := collect(r.Root().Prefix([]byte("x"))); out != nil {
+		t.Fatalf("bad prefix: %v", out)
+	}
+
+	if out := collect(r.Range([]byte("ab"), []byte("b"))); !slices.Equal(out, []string{"ab", "abc", "abd"}) {
+		t.Fatalf("bad range: %v", out)
+	}
+	if out := collect(r.Root().Range([]byte("abd"), nil)); !slices.Equal(out, []string{"abd", "b", "ba"}) {
+		t.Fatalf("bad range: %v", out)
+	}
+
+	// Breaking out of the loop stops the walk.
+	for _, seq := range []func(func([]byte, int) bool){r.All(), r.Backwards(), r.Prefix(nil), r.Range(nil, nil)} {
+		n := 0
+		for range seq {
+			n++
+			if n == 2 {
+				break
+			}
+		}
+		if n != 2 {
+			t.Fatalf("bad: %d", n)
+		}
+	}
+}
+
+func TestMultiTreeSeq(t *testing.T) {
+	m := NewMultiTree[byte, int]()
+	for i, k := range []string{"b", "a", "b"} {
+		m = m.InsertMulti([]byte(k), i)
+	}
+	var out []string
+	for k, v := range m.All() {
+		out = append(out, string(k)+string(rune('0'+v)))
+	}
+	if !slices.Equal(out, []string{"a1", "b0", "b2"}) {
+		t.Fatalf("bad all: %v", out)
+	}
+}
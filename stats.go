@@ -0,0 +1,59 @@
+package iradix
+
+// TreeStats describes the shape of a tree, see Tree.Stats.
+type TreeStats struct {
+	// LeafCount is the number of nodes holding an entry, which is the
+	// number of entries in the tree.
+	LeafCount int
+
+	// InternalNodeCount is the number of nodes only joining other nodes,
+	// including the root if there's no entry for the empty key. Together
+	// with LeafCount it's the number of nodes in the tree.
+	InternalNodeCount int
+
+	// MaxDepth is the most edges between the root and any node, which bounds
+	// the number of nodes a lookup visits.
+	MaxDepth int
+
+	// AvgPrefixLen is the average length of the edge prefixes, that is of
+	// the prefixes of all nodes but the root. It's zero if there are none.
+	// Short prefixes under a large MaxDepth mean long shared key prefixes,
+	// which lookups have to walk one node at a time.
+	AvgPrefixLen float64
+}
+
+// Stats returns statistics on the shape of the tree, computed in a single
+// O(n) pass over its nodes, without allocating.
+func (t *Tree[K, T]) Stats() TreeStats {
+	var c statsCounter
+	visitStats(&c, t.root, 0)
+	s := c.TreeStats
+	if c.edges > 0 {
+		s.AvgPrefixLen = float64(c.prefixLen) / float64(c.edges)
+	}
+	return s
+}
+
+// statsCounter accumulates the statistics of the nodes it visits.
+type statsCounter struct {
+	TreeStats
+	prefixLen, edges int
+}
+
+// visitStats counts n, which is depth edges below the root, and all nodes under
+// it. Unlike the iterators this keeps no paths, which Stats doesn't need.
+func visitStats[K keyT, T any](c *statsCounter, n *Node[K, T], depth int) {
+	if n.isLeaf() {
+		c.LeafCount++
+	} else {
+		c.InternalNodeCount++
+	}
+	if depth > 0 {
+		c.prefixLen += len(n.prefix)
+		c.edges++
+	}
+	c.MaxDepth = max(c.MaxDepth, depth)
+	for _, e := range n.edges {
+		visitStats(c, e.node, depth+1)
+	}
+}
@@ -0,0 +1,49 @@
+package iradix
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTreeStats(t *testing.T) {
+	if s := New[byte, int]().Stats(); s != (TreeStats{InternalNodeCount: 1}) {
+		t.Fatalf("bad empty stats: %+v", s)
+	}
+
+	r := New[byte, int]()
+	for i, k := range []string{"", "foo", "foobar", "foobaz", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	// The root holds "", with edges "foo" and "zip", and "foo" joins "ba",
+	// which has edges "r" and "z".
+	want := TreeStats{
+		LeafCount:         5,
+		InternalNodeCount: 1,
+		MaxDepth:          3,
+		AvgPrefixLen:      float64(3+3+2+1+1) / 5,
+	}
+	if s := r.Stats(); s != want {
+		t.Fatalf("got %+v, want %+v", s, want)
+	}
+
+	txn := New[byte, int]().Txn()
+	for i := 0; i < 10000; i++ {
+		txn.Insert([]byte(fmt.Sprintf("%x", i*7919)), i)
+	}
+	r = txn.Commit()
+	s := r.Stats()
+	if s.LeafCount != r.Len() {
+		t.Fatalf("bad leaf count: %d", s.LeafCount)
+	}
+	nodes := 0
+	r.Root().RawWalk(func([]byte, *Node[byte, int]) bool {
+		nodes++
+		return true
+	})
+	if s.LeafCount+s.InternalNodeCount != nodes {
+		t.Fatalf("bad node counts: %+v for %d nodes", s, nodes)
+	}
+	if allocs := testing.AllocsPerRun(10, func() { r.Stats() }); allocs != 0 {
+		t.Fatalf("Stats allocated %v times", allocs)
+	}
+}
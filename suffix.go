@@ -0,0 +1,61 @@
+package iradix
+
+import (
+	"slices"
+)
+
+// SuffixTree is an immutable tree matching keys by suffix rather than prefix,
+// e.g. to route domain names by their top-level domains. It stores keys
+// reversed in a Tree, but takes and returns them in their original order.
+// Suffixes are matched element-wise, so a stored "example.com" also matches
+// "notexample.com"; store ".example.com" to only match subdomains.
+type SuffixTree[K keyT, T any] struct {
+	tree *Tree[K, T]
+}
+
+// NewSuffixTree returns an empty SuffixTree backed by a tree created with the
+// given options.
+func NewSuffixTree[K keyT, T any](opts ...Option) *SuffixTree[K, T] {
+	return &SuffixTree[K, T]{tree: New[K, T](opts...)}
+}
+
+// reverseKey returns a reversed copy of k.
+func reverseKey[K keyT](k []K) []K {
+	r := slices.Clone(k)
+	slices.Reverse(r)
+	return r
+}
+
+// Len returns the number of keys in the tree.
+func (t *SuffixTree[K, T]) Len() int {
+	return t.tree.Len()
+}
+
+// Insert adds or updates a key, returning the new tree, the previous value
+// and whether there was one.
+func (t *SuffixTree[K, T]) Insert(k []K, v T) (*SuffixTree[K, T], T, bool) {
+	tree, old, ok := t.tree.Insert(reverseKey(k), v)
+	return &SuffixTree[K, T]{tree: tree}, old, ok
+}
+
+// Delete removes a key, returning the new tree, the old value and whether the
+// key existed.
+func (t *SuffixTree[K, T]) Delete(k []K) (*SuffixTree[K, T], T, bool) {
+	tree, old, ok := t.tree.Delete(reverseKey(k))
+	return &SuffixTree[K, T]{tree: tree}, old, ok
+}
+
+// Get looks up an exact key.
+func (t *SuffixTree[K, T]) Get(k []K) (T, bool) {
+	return t.tree.Get(reverseKey(k))
+}
+
+// LongestSuffix returns the longest stored key that is a suffix of k, in its
+// original order, and its value.
+func (t *SuffixTree[K, T]) LongestSuffix(k []K) ([]K, T, bool) {
+	match, v, ok := t.tree.Root().LongestPrefix(reverseKey(k))
+	if !ok {
+		return nil, v, false
+	}
+	return reverseKey(match), v, true
+}
@@ -0,0 +1,48 @@
+package iradix
+
+import (
+	"testing"
+)
+
+func TestSuffixTree(t *testing.T) {
+	r := NewSuffixTree[byte, string]()
+	for _, k := range []string{"com", "example.com", ".org"} {
+		r, _, _ = r.Insert([]byte(k), "route:"+k)
+	}
+	if r.Len() != 3 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+
+	cases := map[string]string{
+		"api.example.com": "example.com",
+		"example.com":     "example.com",
+		"other.com":       "com",
+		"com":             "com",
+		"golang.org":      ".org",
+		"org":             "",
+		"example.net":     "",
+	}
+	for k, expected := range cases {
+		match, v, ok := r.LongestSuffix([]byte(k))
+		if ok != (expected != "") || string(match) != expected {
+			t.Fatalf("%q: got %q %v, want %q", k, match, ok, expected)
+		}
+		if ok && v != "route:"+expected {
+			t.Fatalf("%q: bad value %q", k, v)
+		}
+	}
+
+	if v, ok := r.Get([]byte("example.com")); !ok || v != "route:example.com" {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	r2, _, ok := r.Delete([]byte("example.com"))
+	if !ok {
+		t.Fatalf("expected key to be deleted")
+	}
+	if match, _, _ := r2.LongestSuffix([]byte("api.example.com")); string(match) != "com" {
+		t.Fatalf("bad match after delete: %q", match)
+	}
+	if match, _, _ := r.LongestSuffix([]byte("api.example.com")); string(match) != "example.com" {
+		t.Fatalf("original tree was modified")
+	}
+}
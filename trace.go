@@ -0,0 +1,29 @@
+package iradix
+
+import "time"
+
+// Tracer receives the duration of tree operations, e.g. to export latency
+// histograms. Its methods are called synchronously at the end of every
+// operation, so they must be cheap, and safe for concurrent use if the tree is
+// read concurrently.
+type Tracer interface {
+	// OnGet is called at the end of Tree.Get and Txn.Get.
+	OnGet(dur time.Duration)
+	// OnInsert is called at the end of Txn.Insert and the other single key
+	// writes, GetOrInsert, InsertIfAbsent and Update, and with them of
+	// Tree.Insert.
+	OnInsert(dur time.Duration)
+	// OnDelete is called at the end of Txn.Delete, and with it of Tree.Delete.
+	OnDelete(dur time.Duration)
+}
+
+// WithTracer reports the duration of every Get, insert and Delete on the tree
+// and its transactions to tr. Operations built on top of them, such as
+// Reindex or InsertBatch into a non-empty tree, report each of their inserts
+// and deletes. Without a tracer the cost is a single nil check per
+// operation.
+func WithTracer(tr Tracer) Option {
+	return func(o *options) {
+		o.tracer = tr
+	}
+}
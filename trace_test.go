@@ -0,0 +1,69 @@
+package iradix
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingTracer struct {
+	mu                  sync.Mutex
+	gets, inserts, dels []time.Duration
+}
+
+func (r *recordingTracer) OnGet(dur time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gets = append(r.gets, dur)
+}
+
+func (r *recordingTracer) OnInsert(dur time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inserts = append(r.inserts, dur)
+}
+
+func (r *recordingTracer) OnDelete(dur time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dels = append(r.dels, dur)
+}
+
+func TestTracer(t *testing.T) {
+	tr := &recordingTracer{}
+	start := time.Now()
+	r := New[byte, int](WithTracer(tr))
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	txn := r.Txn()
+	txn.Insert([]byte("bar"), 2)
+	txn.GetOrInsert([]byte("bar"), 3)
+	txn.Get([]byte("bar"))
+	txn.Delete([]byte("missing"))
+	r = txn.Commit()
+	r.Get([]byte("foo"))
+	r.Get([]byte("missing"))
+	r, _, _ = r.Delete([]byte("foo"))
+	elapsed := time.Since(start)
+
+	if len(tr.gets) != 3 || len(tr.inserts) != 3 || len(tr.dels) != 2 {
+		t.Fatalf("bad counts: %d gets, %d inserts, %d deletes", len(tr.gets), len(tr.inserts), len(tr.dels))
+	}
+	var total time.Duration
+	for _, durs := range [][]time.Duration{tr.gets, tr.inserts, tr.dels} {
+		for _, d := range durs {
+			if d < 0 {
+				t.Fatalf("negative duration: %v", d)
+			}
+			total += d
+		}
+	}
+	if total > elapsed {
+		t.Fatalf("reported %v in total, but only %v elapsed", total, elapsed)
+	}
+
+	// The tracer is carried by new versions of the tree.
+	r.Get([]byte("bar"))
+	if len(tr.gets) != 4 {
+		t.Fatalf("bad gets: %d", len(tr.gets))
+	}
+}
@@ -0,0 +1,71 @@
+package iradix
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// closedCh is an already closed channel. It stands in for watch channels that
+// were never allocated but need to be reported as closed, and for all watch
+// channels of trees created with WithoutWatch.
+var closedCh = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// newWatch returns a watch channel for a newly created node or leaf. With lazy
+// channels enabled it returns nil and the channel is allocated on first use.
+// With watching disabled it returns the shared closedCh.
+func (o *options) newWatch() chan struct{} {
+	if o.noWatch {
+		return closedCh
+	}
+	if o.lazyChannels {
+		return nil
+	}
+	return make(chan struct{})
+}
+
+// watch returns the mutation channel of the node, allocating it if needed.
+func (n *Node[K, T]) watch() chan struct{} {
+	return loadWatch(&n.mutateCh)
+}
+
+// watch returns the mutation channel of the leaf, allocating it if needed.
+func (l *leafNode[K, T]) watch() chan struct{} {
+	return loadWatch(&l.mutateCh)
+}
+
+// loadWatch atomically loads the watch channel stored at p, allocating it
+// first if it's nil. Channels are only nil in trees created with
+// WithLazyChannels, so this never allocates otherwise. Since nodes are shared
+// between goroutines once committed, the channel is installed with a CAS, and
+// all concurrent callers observe the same channel.
+func loadWatch(p *chan struct{}) chan struct{} {
+	ptr := (*unsafe.Pointer)(unsafe.Pointer(p))
+	if v := atomic.LoadPointer(ptr); v != nil {
+		return *(*chan struct{})(unsafe.Pointer(&v))
+	}
+	ch := make(chan struct{})
+	if atomic.CompareAndSwapPointer(ptr, nil, *(*unsafe.Pointer)(unsafe.Pointer(&ch))) {
+		return ch
+	}
+	v := atomic.LoadPointer(ptr)
+	return *(*chan struct{})(unsafe.Pointer(&v))
+}
+
+// closeWatch closes the watch channel stored at p. If the channel was never
+// allocated, nobody is waiting on it, so it's replaced with closedCh instead,
+// which makes any late watcher of the stale node return immediately. closedCh
+// itself is left alone.
+func closeWatch(p *chan struct{}) {
+	ptr := (*unsafe.Pointer)(unsafe.Pointer(p))
+	if atomic.CompareAndSwapPointer(ptr, nil, *(*unsafe.Pointer)(unsafe.Pointer(&closedCh))) {
+		return
+	}
+	v := atomic.LoadPointer(ptr)
+	if ch := *(*chan struct{})(unsafe.Pointer(&v)); ch != closedCh {
+		close(ch)
+	}
+}
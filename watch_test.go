@@ -0,0 +1,212 @@
+package iradix
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLazyChannels(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		r := New[byte, int](WithLazyChannels())
+		txn := r.Txn()
+		for j, k := range []string{"foo", "foobar", "foozip", "zipzap"} {
+			txn.Insert([]byte(k), j)
+		}
+		r = txn.Commit()
+
+		// Nothing has been watched yet, so nothing should be allocated.
+		for iter := r.root.rawIterator(); iter.Front() != nil; iter.Next() {
+			n := iter.Front()
+			if n.mutateCh != nil || n.isLeaf() && n.leaf.mutateCh != nil {
+				t.Fatalf("channel allocated eagerly for %q", iter.Path())
+			}
+		}
+
+		// Plain reads must not allocate channels either.
+		r.Get([]byte("foo"))
+		r.Get([]byte("nope"))
+		r.Root().Iterator().SeekPrefix([]byte("foo"))
+		if r.root.mutateCh != nil {
+			t.Fatalf("channel allocated by a read")
+		}
+
+		leafWatch, _, ok := r.Root().GetWatch([]byte("foobar"))
+		if !ok {
+			t.Fatalf("missing key")
+		}
+		missingWatch, _, _ := r.Root().GetWatch([]byte("foobaz"))
+		otherWatch, _, _ := r.Root().GetWatch([]byte("zipzap"))
+		prefixWatch := r.Root().Iterator().SeekPrefixWatch([]byte("foo"))
+
+		// Watching the same thing twice yields the same channel.
+		again, _, _ := r.Root().GetWatch([]byte("foobar"))
+		if again != leafWatch {
+			t.Fatalf("expected the same channel")
+		}
+
+		txn = r.Txn()
+		txn.TrackMutate(true)
+		txn.Insert([]byte("foobar"), 100)
+		switch i {
+		case 0:
+			r = txn.Commit()
+		case 1:
+			r = txn.CommitOnly()
+			txn.Notify()
+		default:
+			r = txn.CommitOnly()
+			txn.slowNotify()
+		}
+		if hasAnyClosedMutateCh(r) {
+			t.Fatalf("bad")
+		}
+
+		for name, ch := range map[string]<-chan struct{}{
+			"leaf":    leafWatch,
+			"missing": missingWatch,
+			"prefix":  prefixWatch,
+		} {
+			select {
+			case <-ch:
+			default:
+				t.Fatalf("%s watch was not triggered", name)
+			}
+		}
+		select {
+		case <-otherWatch:
+			t.Fatalf("unrelated watch was triggered")
+		default:
+		}
+	}
+}
+
+func TestLazyChannels_SlowNotifyUnwatched(t *testing.T) {
+	r := New[byte, int](WithLazyChannels())
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	old := r
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("foo"), 2)
+	txn.CommitOnly()
+	txn.slowNotify()
+
+	// The stale leaf was never watched, but watching it now must report the
+	// change right away.
+	ch, _, _ := old.Root().GetWatch([]byte("foo"))
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("stale leaf watch is not closed")
+	}
+}
+
+func TestLazyChannels_ConcurrentWatch(t *testing.T) {
+	r := New[byte, int](WithLazyChannels())
+	r, _, _ = r.Insert([]byte("foo"), 1)
+
+	const n = 8
+	var wg sync.WaitGroup
+	chans := make([]<-chan struct{}, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			chans[i], _, _ = r.Root().GetWatch([]byte("foo"))
+		}(i)
+	}
+	wg.Wait()
+	for i := 1; i < n; i++ {
+		if chans[i] != chans[0] {
+			t.Fatalf("concurrent watchers got different channels")
+		}
+	}
+}
+
+func TestWithoutWatch(t *testing.T) {
+	for _, limit := range []int{defaultChannelLimit, 1} {
+		r := New[byte, int](WithoutWatch(), WithChannelLimit(limit))
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		for j, k := range []string{"foo", "foobar", "foozip", "zipzap"} {
+			txn.Insert([]byte(k), j)
+		}
+		r = txn.Commit()
+
+		// No node or leaf has a channel of its own.
+		for iter := r.root.rawIterator(); iter.Front() != nil; iter.Next() {
+			n := iter.Front()
+			if n.mutateCh != closedCh || n.isLeaf() && n.leaf.mutateCh != closedCh {
+				t.Fatalf("channel allocated for %q", iter.Path())
+			}
+		}
+
+		// Watches are always closed.
+		leafWatch, _, _ := r.Root().GetWatch([]byte("foobar"))
+		missingWatch, _, _ := r.Root().GetWatch([]byte("nope"))
+		prefixWatch := r.Root().Iterator().SeekPrefixWatch([]byte("foo"))
+		for name, ch := range map[string]<-chan struct{}{
+			"leaf":    leafWatch,
+			"missing": missingWatch,
+			"prefix":  prefixWatch,
+		} {
+			select {
+			case <-ch:
+			default:
+				t.Fatalf("%s watch is not closed", name)
+			}
+		}
+
+		// Tracked transactions don't try to close the shared channel, whether
+		// they notify from the tracked channels or by comparing trees.
+		txn = r.Txn()
+		txn.TrackMutate(true)
+		txn.Insert([]byte("foobar"), 100)
+		txn.Delete([]byte("zipzap"))
+		r = txn.Commit()
+		if v, _ := r.Get([]byte("foobar")); v != 100 || r.Len() != 3 {
+			t.Fatalf("bad: %d %d", v, r.Len())
+		}
+	}
+}
+
+func TestNotifyTrace(t *testing.T) {
+	for _, limit := range []int{defaultChannelLimit, 1} {
+		var closed, compared, calls int
+		r := New[byte, int](WithChannelLimit(limit), WithNotifyTrace(func(c, n int) {
+			closed, compared = c, n
+			calls++
+		}))
+		for i, k := range []string{"foo", "foobar", "zip"} {
+			r, _, _ = r.Insert([]byte(k), i)
+		}
+		old := r
+
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		txn.Insert([]byte("foobar"), 100)
+		txn.Commit()
+		if calls != 1 {
+			t.Fatalf("limit %d: expected a single trace, got %d", limit, calls)
+		}
+
+		// Count the channels of the old tree that were actually closed: the
+		// root, the "foo" and "foobar" nodes and the "foobar" leaf.
+		expected := 0
+		for iter := old.root.rawIterator(); iter.Front() != nil; iter.Next() {
+			n := iter.Front()
+			if isClosed(n.mutateCh) {
+				expected++
+			}
+			if n.leaf != nil && isClosed(n.leaf.mutateCh) {
+				expected++
+			}
+		}
+		if expected != 4 || closed != expected {
+			t.Fatalf("limit %d: traced %d closed channels, %d were closed", limit, closed, expected)
+		}
+		if limit == 1 && compared != 4 || limit != 1 && compared != 0 {
+			t.Fatalf("limit %d: bad compared count %d", limit, compared)
+		}
+	}
+}